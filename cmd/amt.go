@@ -0,0 +1,205 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// The alternative minimum tax parameters for a single filer in 2025, used
+// unless the config gives others.
+const (
+	amtExemption   = 88100
+	amtPhaseout    = 626350
+	amtRateBreak   = 239100
+	amtLowRate     = 0.26
+	amtHighRate    = 0.28
+	amtPhaseoutCut = 0.25
+)
+
+var amtShares float64
+
+// amtCmd represents the amt command
+var amtCmd = &cobra.Command{
+	Use:   "amt",
+	Short: "Estimate the AMT impact of exercising your ISOs.",
+	Long: `Estimate the alternative minimum tax created by exercising your vested
+incentive stock options (type: iso) today and holding the shares. The
+spread over the strike price is an AMT preference item; added to the
+tax income setting, it's compared against the regular federal tax on
+that income to show any AMT owed, or how much you could
+exercise before it kicks in.
+
+This is a rough estimate: it ignores other AMT adjustments and doesn't
+apply the $100K ISO limit (see worth iso). The exemption, its phase-out
+and the 28% rate threshold default to the 2025 single filer amounts and
+can be set as tax amt-exemption, amt-phaseout and amt-rate-break.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := loadValuation(time.Now())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !v.Tax.Set || v.Tax.Country != "us" {
+			fmt.Println("amt: only applies to US taxes; set tax income (and optionally federal-brackets) to compare against regular tax")
+			os.Exit(1)
+		}
+
+		var isos []grantValuation
+		for _, g := range v.Grants {
+			if g.Type == "iso" {
+				isos = append(isos, g)
+			}
+		}
+		a := newAMTEstimate(v, exerciseLots(isos, amtShares), loadAMTParams())
+		if viper.GetString("output") == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(a.json())
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if a.Shares == 0 {
+			fmt.Println("You have no vested ISOs to exercise; set type: iso on grants that are.")
+			return
+		}
+
+		ac := v.money()
+		fmt.Printf("Exercising %s ISOs today adds %s of AMT preference (the spread over the strike price).\n",
+			formatShares(a.Shares), ac.FormatMoney(a.Preference))
+		fmt.Printf("Your tentative minimum tax would be %s against regular tax of %s", ac.FormatMoney(a.Tentative), ac.FormatMoney(a.Regular))
+		if a.AMT > 0 {
+			fmt.Printf(", so you'd owe about %s in AMT.\n", ac.FormatMoney(a.AMT))
+		} else {
+			fmt.Println(", so you wouldn't owe AMT.")
+		}
+		if a.Headroom > 0 {
+			fmt.Printf("You could exercise up to about %s of preference (%s shares at today's spread) without triggering AMT.\n",
+				ac.FormatMoney(a.Headroom), formatShares(math.Floor(a.Headroom/a.Spread)))
+		} else {
+			fmt.Println("You're already over the AMT threshold before exercising anything.")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(amtCmd)
+
+	amtCmd.Flags().Float64Var(&amtShares, "shares", 0, "exercise only this many vested ISOs (default all)")
+}
+
+// amtParams are the AMT exemption and rate settings.
+type amtParams struct {
+	Exemption float64
+	Phaseout  float64
+	RateBreak float64
+}
+
+func loadAMTParams() amtParams {
+	p := amtParams{Exemption: amtExemption, Phaseout: amtPhaseout, RateBreak: amtRateBreak}
+	if viper.IsSet("tax.amt-exemption") {
+		p.Exemption = viper.GetFloat64("tax.amt-exemption")
+	}
+	if viper.IsSet("tax.amt-phaseout") {
+		p.Phaseout = viper.GetFloat64("tax.amt-phaseout")
+	}
+	if viper.IsSet("tax.amt-rate-break") {
+		p.RateBreak = viper.GetFloat64("tax.amt-rate-break")
+	}
+	return p
+}
+
+// tentative returns the tentative minimum tax on AMT income: the exemption,
+// reduced by a quarter of the income over the phase-out, comes off first
+// and the rest is taxed at 26%, then 28% above the rate break.
+func (p amtParams) tentative(income float64) float64 {
+	exemption := math.Max(p.Exemption-amtPhaseoutCut*math.Max(income-p.Phaseout, 0), 0)
+	base := math.Max(income-exemption, 0)
+	if base <= p.RateBreak {
+		return base * amtLowRate
+	}
+	return p.RateBreak*amtLowRate + (base-p.RateBreak)*amtHighRate
+}
+
+// amtEstimate is the AMT effect of exercising some ISOs.
+type amtEstimate struct {
+	Shares     float64
+	Spread     float64
+	Preference float64
+	Regular    float64
+	Tentative  float64
+	AMT        float64
+	Headroom   float64
+}
+
+func newAMTEstimate(v valuation, lots []vestEvent, p amtParams) amtEstimate {
+	var a amtEstimate
+	for _, l := range lots {
+		a.Shares += l.Shares
+		a.Preference += l.Shares * math.Max(v.Price-l.StrikePrice, 0)
+	}
+	if a.Shares > 0 {
+		a.Spread = a.Preference / a.Shares
+	}
+	a.Regular = taxOn(v.Tax.Federal, v.Tax.Income)
+	a.Tentative = p.tentative(v.Tax.Income + a.Preference)
+	a.AMT = math.Max(a.Tentative-a.Regular, 0)
+
+	// tentative minimum tax only grows with income, so search for the most
+	// preference that keeps it at or under the regular tax
+	if p.tentative(v.Tax.Income) < a.Regular {
+		low, high := 0.0, 1.0
+		for p.tentative(v.Tax.Income+high) < a.Regular {
+			high *= 2
+		}
+		for high-low > 0.01 {
+			mid := (low + high) / 2
+			if p.tentative(v.Tax.Income+mid) < a.Regular {
+				low = mid
+			} else {
+				high = mid
+			}
+		}
+		a.Headroom = math.Floor(low*100) / 100
+	}
+	return a
+}
+
+func (a amtEstimate) json() interface{} {
+	return struct {
+		SchemaVersion int     `json:"schema_version"`
+		Shares        float64 `json:"shares"`
+		Preference    float64 `json:"preference"`
+		RegularTax    float64 `json:"regular_tax"`
+		TentativeTax  float64 `json:"tentative_minimum_tax"`
+		AMT           float64 `json:"amt"`
+		Headroom      float64 `json:"headroom"`
+	}{schemaVersion, a.Shares, a.Preference, a.Regular, a.Tentative, a.AMT, a.Headroom}
+}
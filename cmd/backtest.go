@@ -0,0 +1,245 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var backtestBenchmark string
+
+// backtestCmd represents the backtest command
+var backtestCmd = &cobra.Command{
+	Use:   "backtest",
+	Short: "Compare selling at every vest with holding everything.",
+	Long: `Look back over your actual vests and compare two ways they could have
+gone: selling every vest at that day's close (exercising options that were
+in the money), or holding every share to today. With --benchmark, the
+proceeds of each sale are put into that ticker, e.g. an index fund, at its
+close that day; otherwise they're held as cash.
+
+Taxes, fees and the sales in your ledger are left out, so the two are
+compared like for like.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		past, _, err := pastVests(v, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(past) == 0 {
+			fmt.Println("Nothing has vested yet.")
+			return
+		}
+		var benchmark []pricePoint
+		if backtestBenchmark != "" {
+			since := now
+			for _, e := range past {
+				if e.Close > 0 && e.Date.Before(since) {
+					since = e.Date
+				}
+			}
+			benchmark, err = benchmarkPrices(backtestBenchmark, since, now)
+			if err != nil {
+				fmt.Printf("backtest: %s: %s\n", backtestBenchmark, err)
+				os.Exit(1)
+			}
+		}
+		b := runBacktest(v, past, benchmark)
+
+		if viper.GetString("output") == "json" {
+			err = writeBacktestJSON(v, b)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		header := "Vested\tGrant\tShares\tClose\tSold for\tHeld, now\t"
+		if benchmark != nil {
+			header += backtestBenchmark + ", now\t"
+		}
+		fmt.Fprintln(w, header)
+		for _, t := range b.Trades {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t", t.Date.Format("Jan 2, 2006"), t.Grant, formatShares(t.Shares),
+				ac.FormatMoney(t.Close), ac.FormatMoney(t.Proceeds), ac.FormatMoney(t.Held))
+			if benchmark != nil {
+				fmt.Fprintf(w, "%s\t", ac.FormatMoney(t.Invested))
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Total\t\t\t\t%s\t%s\t", ac.FormatMoney(b.Proceeds), ac.FormatMoney(b.Held))
+		if benchmark != nil {
+			fmt.Fprintf(w, "%s\t", ac.FormatMoney(b.Invested))
+		}
+		fmt.Fprintln(w)
+		w.Flush()
+		fmt.Println()
+
+		sold, where := b.Proceeds, "in cash"
+		if benchmark != nil {
+			sold, where = b.Invested, "in "+backtestBenchmark
+		}
+		switch {
+		case b.Held > sold:
+			fmt.Printf("Holding everything came out ahead: %s today, against %s %s from selling at each vest (%s more).\n",
+				ac.FormatMoney(b.Held), ac.FormatMoney(sold), where, ac.FormatMoney(b.Held-sold))
+		case b.Held < sold:
+			fmt.Printf("Selling at each vest came out ahead: %s %s today, against %s from holding everything (%s more).\n",
+				ac.FormatMoney(sold), where, ac.FormatMoney(b.Held), ac.FormatMoney(sold-b.Held))
+		default:
+			fmt.Printf("Selling at each vest and holding everything come out even, at %s.\n", ac.FormatMoney(sold))
+		}
+		if b.Skipped > 0 {
+			fmt.Printf("%d vests before the price history starts are left out.\n", b.Skipped)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backtestCmd)
+
+	backtestCmd.Flags().StringVar(&backtestBenchmark, "benchmark", "", "put the proceeds of each sale into this ticker (e.g. VTI) rather than cash")
+}
+
+// backtestTrade is one vest, sold at the close that day for Proceeds, or
+// held and now worth Held; Invested is what the proceeds put into the
+// benchmark would be worth now.
+type backtestTrade struct {
+	pastVest
+	Proceeds float64
+	Held     float64
+	Invested float64
+}
+
+// backtest totals the trades. Skipped counts the vests without a close.
+type backtest struct {
+	Trades   []backtestTrade
+	Proceeds float64
+	Held     float64
+	Invested float64
+	Skipped  int
+}
+
+// runBacktest sells each past vest at its close. Options out of the money
+// on the day can't be sold, so are held either way.
+func runBacktest(v valuation, past []pastVest, benchmark []pricePoint) backtest {
+	var b backtest
+	for _, e := range past {
+		if e.Close == 0 {
+			b.Skipped++
+			continue
+		}
+		t := backtestTrade{pastVest: e, Held: math.Max(e.Shares*(v.Price-e.StrikePrice), 0)}
+		if e.Close > e.StrikePrice || e.StrikePrice == 0 {
+			t.Proceeds = e.Shares * (e.Close - e.StrikePrice)
+			t.Invested = t.Proceeds
+			if len(benchmark) > 0 {
+				t.Invested = t.Proceeds / closeOn(benchmark, e.Date) * benchmark[len(benchmark)-1].Close
+			}
+		} else {
+			t.Invested = t.Held
+		}
+		b.Trades = append(b.Trades, t)
+		b.Proceeds += t.Proceeds
+		b.Held += t.Held
+		b.Invested += t.Invested
+	}
+	return b
+}
+
+// benchmarkPrices fetches the daily closes of another ticker since a date,
+// as quoted: the splits configured are the stock's, not the benchmark's.
+func benchmarkPrices(symbol string, since, now time.Time) ([]pricePoint, error) {
+	closes, err := dailyCloses(symbol, int(now.Sub(since).Hours()/24)+7)
+	if err != nil {
+		return nil, err
+	}
+	var points []pricePoint
+	for day, closing := range closes {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, pricePoint{Date: date, Close: closing})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	if len(points) == 0 || points[0].Date.After(since) {
+		return nil, fmt.Errorf("no price history back to %s", since.Format("2006-01-02"))
+	}
+	return points, nil
+}
+
+// jsonBacktestTrade is one vest in the backtest JSON output.
+type jsonBacktestTrade struct {
+	Date     time.Time `json:"date"`
+	Grant    string    `json:"grant"`
+	Shares   float64   `json:"shares"`
+	Close    float64   `json:"close"`
+	Proceeds float64   `json:"proceeds"`
+	Held     float64   `json:"held_value"`
+	Invested *float64  `json:"benchmark_value,omitempty"`
+}
+
+func writeBacktestJSON(v valuation, b backtest) error {
+	out := struct {
+		SchemaVersion int                 `json:"schema_version"`
+		Ticker        string              `json:"ticker"`
+		Price         float64             `json:"price"`
+		Benchmark     string              `json:"benchmark,omitempty"`
+		Proceeds      float64             `json:"proceeds"`
+		Held          float64             `json:"held_value"`
+		Invested      *float64            `json:"benchmark_value,omitempty"`
+		Skipped       int                 `json:"skipped"`
+		Vests         []jsonBacktestTrade `json:"vests"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Benchmark: backtestBenchmark,
+		Proceeds: b.Proceeds, Held: b.Held, Skipped: b.Skipped, Vests: []jsonBacktestTrade{}}
+	if backtestBenchmark != "" {
+		out.Invested = &b.Invested
+	}
+	for _, t := range b.Trades {
+		j := jsonBacktestTrade{Date: t.Date, Grant: t.Grant, Shares: t.Shares, Close: t.Close, Proceeds: t.Proceeds, Held: t.Held}
+		if backtestBenchmark != "" {
+			invested := t.Invested
+			j.Invested = &invested
+		}
+		out.Vests = append(out.Vests, j)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// blackout is a trading blackout window, during which vested shares can't
+// be sold. End is the day trading reopens.
+type blackout struct {
+	Start time.Time
+	End   time.Time
+}
+
+// loadBlackouts reads the blackout windows, both those listed explicitly and
+// those around each earnings date, sorted by start date.
+func loadBlackouts() ([]blackout, error) {
+	var raw []struct {
+		Start interface{} `mapstructure:"start"`
+		End   interface{} `mapstructure:"end"`
+	}
+	err := viper.UnmarshalKey("blackouts", &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blackouts: %s", err)
+	}
+
+	var windows []blackout
+	for i, r := range raw {
+		start, err := configDate(r.Start)
+		if err != nil {
+			return nil, fmt.Errorf("blackout %d start: %s", i+1, err)
+		}
+		end, err := configDate(r.End)
+		if err != nil {
+			return nil, fmt.Errorf("blackout %d end: %s", i+1, err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("blackout %d ends before it starts", i+1)
+		}
+		windows = append(windows, blackout{Start: start, End: end.AddDate(0, 0, 1)})
+	}
+
+	var earnings []interface{}
+	err = viper.UnmarshalKey("earnings-dates", &earnings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid earnings-dates: %s", err)
+	}
+	by, bm, bd, err := parseSpan(viper.GetString("blackout-before"))
+	if err != nil {
+		return nil, fmt.Errorf("blackout-before: %s", err)
+	}
+	ay, am, ad, err := parseSpan(viper.GetString("blackout-after"))
+	if err != nil {
+		return nil, fmt.Errorf("blackout-after: %s", err)
+	}
+	for i, e := range earnings {
+		date, err := configDate(e)
+		if err != nil {
+			return nil, fmt.Errorf("earnings date %d: %s", i+1, err)
+		}
+		windows = append(windows, blackout{
+			Start: addSpan(date, -by, -bm, -bd),
+			End:   addSpan(date, ay, am, ad).AddDate(0, 0, 1),
+		})
+	}
+
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows, nil
+}
+
+// tradingOpens returns t, or when trading reopens if t falls in a blackout.
+func tradingOpens(windows []blackout, t time.Time) time.Time {
+	// windows may overlap or abut, so trading resumes after the last of them
+	for _, b := range windows {
+		if !t.Before(b.Start) && t.Before(b.End) {
+			t = b.End
+		}
+	}
+	return t
+}
+
+// applyBlackouts works out how many vested shares could be sold as of the
+// valuation date. Inside a blackout none can, until the window reopens;
+// outside one, all of them can until the next blackout starts.
+func (v *valuation) applyBlackouts(windows []blackout) {
+	v.SharesSellable = v.SharesVestedUnsold
+	v.SellableValue = v.VestedValue
+
+	if open := tradingOpens(windows, v.AsOf); open.After(v.AsOf) {
+		v.SharesSellable, v.SellableValue = 0, 0
+		v.WindowOpens = open
+		return
+	}
+
+	for _, b := range windows {
+		if b.Start.After(v.AsOf) {
+			v.NextBlackout = b.Start
+			return
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// optionModel holds the Black-Scholes inputs for valuing options: the
+// annual volatility of the stock, the risk-free rate and the dividend yield,
+// all continuously compounded.
+type optionModel struct {
+	Volatility float64
+	Rate       float64
+	Yield      float64
+}
+
+// defaultVolatility is assumed for Black-Scholes when volatility isn't set.
+const defaultVolatility = 0.4
+
+// loadOptionModel reads the volatility and risk-free-rate settings for
+// symbol, taking the dividend yield from the quote.
+func loadOptionModel(symbol string, yield float64) (optionModel, error) {
+	m := optionModel{Yield: yield, Volatility: defaultVolatility}
+	var err error
+	if viper.IsSet("volatility") {
+		m.Volatility, _, err = volatilitySetting(symbol, viper.Get("volatility"))
+		if err != nil {
+			return m, fmt.Errorf("volatility: %s", err)
+		}
+		if m.Volatility <= 0 {
+			return m, fmt.Errorf("volatility must be positive")
+		}
+	}
+	m.Rate, err = configPercent(viper.Get("risk-free-rate"))
+	if err != nil {
+		return m, fmt.Errorf("risk-free-rate: %s", err)
+	}
+	return m, nil
+}
+
+// call is the Black-Scholes value of an option to buy a share at strike,
+// years from expiring, when the stock trades at spot. An expired option is
+// worth its spread, if any.
+func (m optionModel) call(spot, strike, years float64) float64 {
+	if years <= 0 || strike <= 0 {
+		return math.Max(spot-strike, 0)
+	}
+	d1, d2 := m.d(spot, strike, years)
+	return spot*math.Exp(-m.Yield*years)*normCDF(d1) - strike*math.Exp(-m.Rate*years)*normCDF(d2)
+}
+
+func (m optionModel) d(spot, strike, years float64) (float64, float64) {
+	sd := m.Volatility * math.Sqrt(years)
+	d1 := (math.Log(spot/strike) + (m.Rate-m.Yield+m.Volatility*m.Volatility/2)*years) / sd
+	return d1, d1 - sd
+}
+
+// greeks returns the sensitivities of an option's Black-Scholes value:
+// delta per dollar the stock moves, theta per day that passes and vega per
+// point of volatility. An expired option's delta is one if it's in the money.
+func (m optionModel) greeks(spot, strike, years float64) (delta, theta, vega float64) {
+	if years <= 0 || strike <= 0 {
+		if spot > strike {
+			return 1, 0, 0
+		}
+		return 0, 0, 0
+	}
+	d1, d2 := m.d(spot, strike, years)
+	discounted := spot * math.Exp(-m.Yield*years)
+	delta = math.Exp(-m.Yield*years) * normCDF(d1)
+	theta = (-discounted*normPDF(d1)*m.Volatility/(2*math.Sqrt(years)) -
+		m.Rate*strike*math.Exp(-m.Rate*years)*normCDF(d2) + m.Yield*discounted*normCDF(d1)) / 365
+	vega = discounted * normPDF(d1) * math.Sqrt(years) / 100
+	return delta, theta, vega
+}
+
+// normCDF is the standard normal cumulative distribution function.
+func normCDF(x float64) float64 {
+	return math.Erfc(-x/math.Sqrt2) / 2
+}
+
+// normPDF is the standard normal density function.
+func normPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// yearsUntil is the time from now to t in years, or zero once it's passed.
+func yearsUntil(now, t time.Time) float64 {
+	return math.Max(t.Sub(now).Hours()/24/365.25, 0)
+}
+
+// applyBlackScholes values option grants with the Black-Scholes model
+// instead of at their spread, so options underwater or years from expiring
+// still count for something. Since they can be exercised early, they're
+// never worth less than the spread, and one worth no more than that moves
+// with the stock like a share. The totals are adjusted to match, and the
+// greeks of the whole position added up.
+func (v *valuation) applyBlackScholes(m optionModel) {
+	v.Model = &m
+	for i := range v.Grants {
+		g := &v.Grants[i]
+		if !g.isOption() {
+			continue
+		}
+		spread := v.Price - g.StrikePrice
+		years := yearsUntil(v.AsOf, g.Expires)
+		value := m.call(v.Price, g.StrikePrice, years)
+		delta, theta, vega := m.greeks(v.Price, g.StrikePrice, years)
+		if value < spread {
+			value, delta, theta, vega = spread, 1, 0, 0
+		}
+		diff := value - spread
+
+		options := g.SharesVestedUnsold + g.SharesUnvested
+		v.Delta += options * delta
+		v.Theta += options * theta
+		v.Vega += options * vega
+
+		v.OptionSpreadValue += options * spread
+		v.OptionValue += options * value
+		g.VestedValue = g.SharesVestedUnsold * value
+		g.UnvestedValue = g.SharesUnvested * value
+		v.VestedValue += g.SharesVestedUnsold * diff
+		v.UnvestedValue += g.SharesUnvested * diff
+		v.TotalValue += g.Shares * diff
+		v.AccelSingleValue += g.AccelSingle * diff
+		v.AccelDoubleValue += g.AccelDouble * diff
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// businessCalendar decides which days vests can be delivered on. Vests due
+// on a weekend or holiday roll forward to the next business day.
+type businessCalendar struct {
+	holidays map[string]bool
+	// nyse adds the New York Stock Exchange's holidays, worked out a year
+	// at a time as dates are checked.
+	nyse  bool
+	years map[int]bool
+}
+
+// loadBusinessCalendar reads the business-day settings, returning nil when
+// vests aren't rolled to business days.
+func loadBusinessCalendar() (*businessCalendar, error) {
+	if !viper.GetBool("business-days") {
+		return nil, nil
+	}
+
+	c := &businessCalendar{holidays: map[string]bool{}, years: map[int]bool{}}
+	switch name := viper.GetString("holiday-calendar"); name {
+	case "", "none":
+	case "nyse":
+		c.nyse = true
+	default:
+		return nil, fmt.Errorf("invalid holiday-calendar %q: expected nyse or none", name)
+	}
+
+	var raw []interface{}
+	err := viper.UnmarshalKey("holidays", &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holidays: %s", err)
+	}
+	for i, r := range raw {
+		date, err := configDate(r)
+		if err != nil {
+			return nil, fmt.Errorf("holiday %d: %s", i+1, err)
+		}
+		c.holidays[date.Format("2006-01-02")] = true
+	}
+	return c, nil
+}
+
+// isBusinessDay reports whether d is a weekday that isn't a holiday.
+func (c *businessCalendar) isBusinessDay(d time.Time) bool {
+	if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+		return false
+	}
+	if c.nyse && !c.years[d.Year()] {
+		for _, h := range nyseHolidays(d.Year()) {
+			c.holidays[h.Format("2006-01-02")] = true
+		}
+		c.years[d.Year()] = true
+	}
+	return !c.holidays[d.Format("2006-01-02")]
+}
+
+// roll returns d, or the next business day after it.
+func (c *businessCalendar) roll(d time.Time) time.Time {
+	for !c.isBusinessDay(d) {
+		d = d.AddDate(0, 0, 1)
+	}
+	return d
+}
+
+// nyseHolidays returns the days the NYSE is closed for holidays in year.
+// Holidays on a Sunday are observed the following Monday and those on a
+// Saturday the Friday before, except New Year's Day, which isn't moved
+// back into the previous year.
+func nyseHolidays(year int) []time.Time {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	// nth returns the nth weekday of month, counting from the end when n
+	// is negative
+	nth := func(month time.Month, weekday time.Weekday, n int) time.Time {
+		if n < 0 {
+			d := date(month+1, 1).AddDate(0, 0, -1)
+			return d.AddDate(0, 0, -int((d.Weekday()-weekday+7)%7))
+		}
+		d := date(month, 1)
+		return d.AddDate(0, 0, int((weekday-d.Weekday()+7)%7)+(n-1)*7)
+	}
+	observed := func(d time.Time) time.Time {
+		switch d.Weekday() {
+		case time.Saturday:
+			return d.AddDate(0, 0, -1)
+		case time.Sunday:
+			return d.AddDate(0, 0, 1)
+		}
+		return d
+	}
+
+	var days []time.Time
+	if newYear := date(time.January, 1); newYear.Weekday() != time.Saturday {
+		days = append(days, observed(newYear))
+	}
+	days = append(days,
+		nth(time.January, time.Monday, 3),
+		nth(time.February, time.Monday, 3),
+		easter(year).AddDate(0, 0, -2),
+		nth(time.May, time.Monday, -1),
+		observed(date(time.July, 4)),
+		nth(time.September, time.Monday, 1),
+		nth(time.November, time.Thursday, 4),
+		observed(date(time.December, 25)),
+	)
+	if year >= 2022 {
+		days = append(days, observed(date(time.June, 19)))
+	}
+	return days
+}
+
+// easter returns the date of Easter Sunday in the Gregorian calendar.
+func easter(year int) time.Time {
+	a, b, c := year%19, year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,124 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var icsFile string
+
+// calendarCmd represents the calendar command
+var calendarCmd = &cobra.Command{
+	Use:   "calendar",
+	Short: "Export your upcoming vest dates to a calendar.",
+	Long: `Write every future vest date to an iCalendar file as an all-day event,
+with the number of shares and their value at today's price in the
+description, ready to import into a calendar app.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if icsFile == "" {
+			fmt.Println("calendar: --ics is required")
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		f, err := os.Create(icsFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		err = writeICS(f, v, v.upcomingVests(now), now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calendarCmd)
+
+	calendarCmd.Flags().StringVar(&icsFile, "ics", "", "write vest events to this iCalendar file")
+}
+
+// writeICS writes the vest events as an RFC 5545 calendar. Event UIDs are
+// derived from the ticker, grant and date so re-importing an updated file
+// replaces the earlier events rather than duplicating them.
+func writeICS(w io.Writer, v valuation, vests []vestEvent, now time.Time) error {
+	ac := v.money()
+	bw := bufio.NewWriter(w)
+	line := func(s string) {
+		// lines longer than 75 octets are folded onto continuation lines
+		for len(s) > 75 {
+			cut := 75
+			for cut > 1 && s[cut]&0xC0 == 0x80 {
+				cut--
+			}
+			bw.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		bw.WriteString(s + "\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//edlitmus//worth//EN")
+	line("CALSCALE:GREGORIAN")
+	for _, e := range vests {
+		value := e.Shares * (v.Price - e.StrikePrice)
+		uid := fmt.Sprintf("%x@worth", sha1.Sum([]byte(v.Ticker+"\x00"+e.Grant+"\x00"+e.Date.Format("20060102"))))
+		summary := fmt.Sprintf("%s vest: %s shares", v.Ticker, formatShares(e.Shares))
+		description := fmt.Sprintf("%s shares of %s vest from %s, worth %s at %s's price of %s.",
+			formatShares(e.Shares), v.Ticker, e.Grant, ac.FormatMoney(value), now.Format("Jan 2, 2006"), ac.FormatMoney(v.Price))
+
+		line("BEGIN:VEVENT")
+		line("UID:" + uid)
+		line("DTSTAMP:" + now.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + e.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + e.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icsText(summary))
+		line("DESCRIPTION:" + icsText(description))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// icsText escapes a value for an iCalendar TEXT property.
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
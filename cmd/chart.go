@@ -0,0 +1,264 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var chartDays int
+var chartHeight int
+var chartWidth int
+var chartSource string
+
+// chartCmd represents the chart command
+var chartCmd = &cobra.Command{
+	Use:   "chart",
+	Short: "Chart the stock price and your vested value.",
+	Long: `Draw terminal line charts of the daily closing price and the value of
+your vested, unsold shares over the last --days days.
+
+With --source history, chart them as recorded in the snapshot history
+instead, one point a day, along with the portfolio's vested value if
+snapshots of it were taken.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if chartWidth < 2 {
+			fmt.Println("chart: --width must be at least 2")
+			os.Exit(1)
+		}
+		positions, err := loadPositions()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		switch chartSource {
+		case "history":
+			err = chartHistory(os.Stdout, positions[0], time.Now())
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		case "prices":
+		default:
+			fmt.Printf("chart: unknown source %q; expected prices or history\n", chartSource)
+			os.Exit(1)
+		}
+		grants := positions[0].Grants
+		sales, err := loadLedger()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		points, err := getDailyPrices(positions[0].Ticker, chartDays)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(points) < 2 {
+			fmt.Println("chart: not enough price history to draw")
+			os.Exit(1)
+		}
+
+		prices := make([]float64, len(points))
+		values := make([]float64, len(points))
+		for i, p := range points {
+			prices[i] = p.Close
+			values[i] = math.Max(valuate(grants, p.Close, p.Date, sales).VestedValue, 0)
+		}
+
+		first := points[0].Date.Format("2006-01-02")
+		last := points[len(points)-1].Date.Format("2006-01-02")
+		color := isTerminal(os.Stdout)
+		ac := currencyMoney(stockCurrency(positions[0], JsonOverview{}))
+		plotLine(os.Stdout, fmt.Sprintf("%s price", positions[0].Ticker), prices, first, last, ac, "\x1b[36m", color)
+		fmt.Println()
+		plotLine(os.Stdout, "Vested value", values, first, last, ac, "\x1b[32m", color)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(chartCmd)
+
+	chartCmd.Flags().IntVar(&chartDays, "days", 90, "number of days of history to chart")
+	chartCmd.Flags().IntVar(&chartHeight, "height", 12, "chart height in lines")
+	chartCmd.Flags().IntVar(&chartWidth, "width", 60, "chart width in columns")
+	chartCmd.Flags().StringVar(&chartSource, "source", "prices", "what to chart from: prices (daily closes) or history (recorded snapshots)")
+}
+
+// chartHistory charts the position's price and vested value, and the
+// portfolio's vested value, from the last snapshot of each day in the
+// history over the last --days days.
+func chartHistory(w io.Writer, p position, now time.Time) error {
+	snapshots, err := loadSnapshots()
+	if err != nil {
+		return err
+	}
+	since := now.AddDate(0, 0, -chartDays)
+	var days, portfolioDays []string
+	var prices, values, portfolio []float64
+	var currency, portfolioCurrency string
+	for _, s := range snapshots {
+		if s.Time.Before(since) {
+			continue
+		}
+		// a later snapshot the same day replaces the earlier one
+		day := s.Time.Local().Format("2006-01-02")
+		if s.Positions != nil {
+			portfolioCurrency = s.Currency
+			if n := len(portfolioDays); n > 0 && portfolioDays[n-1] == day {
+				portfolio[n-1] = s.VestedValue
+			} else {
+				portfolioDays = append(portfolioDays, day)
+				portfolio = append(portfolio, s.VestedValue)
+			}
+		}
+		for _, ps := range append([]snapshot{s}, s.Positions...) {
+			if ps.Position != p.Name || ps.Ticker != normalizeSymbol(p.Ticker) {
+				continue
+			}
+			currency = ps.Currency
+			if n := len(days); n > 0 && days[n-1] == day {
+				prices[n-1], values[n-1] = ps.Price, ps.VestedValue
+			} else {
+				days = append(days, day)
+				prices = append(prices, ps.Price)
+				values = append(values, ps.VestedValue)
+			}
+		}
+	}
+	if len(days) < 2 && len(portfolioDays) < 2 {
+		return fmt.Errorf("chart: fewer than two days of snapshots to draw; see worth snapshot")
+	}
+
+	color := isTerminal(os.Stdout)
+	if len(days) >= 2 {
+		first, last := days[0], days[len(days)-1]
+		ac := currencyMoney(currency)
+		if prices[0] > 0 {
+			plotLine(w, fmt.Sprintf("%s price", p.Name), prices, first, last, ac, "\x1b[36m", color)
+			fmt.Fprintln(w)
+		}
+		plotLine(w, "Vested value", values, first, last, ac, "\x1b[32m", color)
+	}
+	if len(portfolioDays) >= 2 {
+		if len(days) >= 2 {
+			fmt.Fprintln(w)
+		}
+		plotLine(w, "Portfolio vested value", portfolio, portfolioDays[0], portfolioDays[len(portfolioDays)-1],
+			currencyMoney(portfolioCurrency), "\x1b[33m", color)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is attached to a terminal, in which case the
+// chart is drawn with ANSI colours.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// plotLine draws series as a line chart with a labelled y axis, its amounts
+// formatted with ac. Series longer than the chart width are sampled down to
+// fit.
+func plotLine(w io.Writer, title string, series []float64, first, last string, ac moneyFormat, ansi string, color bool) {
+
+	width := chartWidth
+	if len(series) < width {
+		width = len(series)
+	}
+	height := chartHeight
+	if height < 2 {
+		height = 2
+	}
+
+	sampled := make([]float64, width)
+	for x := range sampled {
+		sampled[x] = series[x*(len(series)-1)/int(math.Max(float64(width-1), 1))]
+	}
+
+	lo, hi := sampled[0], sampled[0]
+	for _, v := range sampled {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	grid := make([][]rune, height)
+	for y := range grid {
+		grid[y] = []rune(strings.Repeat(" ", width))
+	}
+	row := func(v float64) int {
+		return int(math.Round((v - lo) / (hi - lo) * float64(height-1)))
+	}
+	prev := row(sampled[0])
+	for x, v := range sampled {
+		r := row(v)
+		// fill the vertical gap from the previous column so the line is continuous
+		for y := min(prev, r) + 1; y < max(prev, r); y++ {
+			grid[y][x] = '|'
+		}
+		grid[r][x] = '*'
+		prev = r
+	}
+
+	labels := []string{ac.FormatMoney(hi), ac.FormatMoney((hi + lo) / 2), ac.FormatMoney(lo)}
+	pad := 0
+	for _, l := range labels {
+		pad = max(pad, len(l))
+	}
+
+	fmt.Fprintf(w, "%s\n", title)
+	for y := height - 1; y >= 0; y-- {
+		label := ""
+		switch y {
+		case height - 1:
+			label = labels[0]
+		case (height - 1) / 2:
+			label = labels[1]
+		case 0:
+			label = labels[2]
+		}
+		line := string(grid[y])
+		if color {
+			line = ansi + line + "\x1b[0m"
+		}
+		fmt.Fprintf(w, "%*s |%s\n", pad, label, line)
+	}
+	fmt.Fprintf(w, "%*s +%s\n", pad, "", strings.Repeat("-", width))
+	gap := width - len(first) - len(last)
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Fprintf(w, "%*s  %s%s%s\n", pad, "", first, strings.Repeat(" ", gap), last)
+}
@@ -0,0 +1,89 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+)
+
+// classConfig is a share class of a position, as written in the config. A
+// class is priced at a fixed price, at its own ticker's quote, or at the
+// position's price, times its multiplier (a class B share convertible into
+// ten common shares has a multiplier of 10).
+type classConfig struct {
+	Name       string  `mapstructure:"name"`
+	Ticker     string  `mapstructure:"ticker"`
+	Price      float64 `mapstructure:"price"`
+	Multiplier float64 `mapstructure:"multiplier"`
+}
+
+// shareClasses checks the position's classes. Without any, all its grants
+// are of the one class.
+func (pc positionConfig) shareClasses() ([]classConfig, error) {
+	seen := map[string]bool{}
+	for i, c := range pc.Classes {
+		if c.Name == "" {
+			return nil, fmt.Errorf("class %d: name is required", i+1)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("there's already a class named %q", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Price < 0 || c.Multiplier < 0 {
+			return nil, fmt.Errorf("class %s: price and multiplier can't be negative", c.Name)
+		}
+		if c.Price > 0 && c.Ticker != "" {
+			return nil, fmt.Errorf("class %s: price and ticker can't both be set", c.Name)
+		}
+	}
+	return pc.Classes, nil
+}
+
+// classIndex returns which of the classes a grant's class names; grants
+// that don't name one are of the first.
+func classIndex(classes []classConfig, name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+	for i, c := range classes {
+		if c.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no class named %q", name)
+}
+
+// withClass returns the part of position p made up of class c's grants.
+func (p position) withClass(c classConfig, grants []grant) position {
+	p.Class = c.Name
+	p.FixedPrice = c.Price
+	p.Multiplier = c.Multiplier
+	if c.Ticker != "" {
+		p.Ticker = normalizeSymbol(c.Ticker)
+		p.Private = nil
+	}
+	p.Grants = grants
+	return p
+}
+
+// quoted reports whether the position's price comes from a quote.
+func (p position) quoted() bool {
+	return p.Private == nil && p.FixedPrice == 0
+}
@@ -0,0 +1,272 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var compareYears int
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare OFFER OFFER",
+	Short: "Compare two offers' equity side by side.",
+	Long: `Compare the equity in two job offers, each described in its own YAML or
+JSON file, year by year: the value of the shares vesting each year at a
+share price growing at the offer's assumed rate. Each file takes a grants
+list, or a single grant's shares, strike-price and vesting settings at the
+top level, as in the config, along with:
+
+  name: Acme          # defaults to the file name
+  ticker: ACME        # priced from a quote, or
+  price: 12.50        # an assumed price, e.g. the last 409A or round
+  growth: 15%         # assumed yearly growth of the share price
+  salary: 200000      # optional, shown alongside
+
+The unvested value you'd forfeit by leaving your current grants (from the
+config, if it has any) is taken off each offer's total.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if compareYears < 1 {
+			fmt.Println("compare: --years must be at least 1")
+			os.Exit(1)
+		}
+		now := time.Now()
+		var offers []offer
+		for _, path := range args {
+			o, err := loadOffer(path, now)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			offers = append(offers, o)
+		}
+
+		forfeit := 0.0
+		ac := configuredMoney()
+		if _, err := loadGrants(); err == nil {
+			v, err := loadValuation(now)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			forfeit = v.UnvestedValue
+			ac = v.money()
+		}
+
+		if viper.GetString("output") == "json" {
+			err := writeCompareJSON(offers, forfeit, now)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		row := func(label string, cell func(o offer) string) {
+			fmt.Fprintf(w, "%s\t", label)
+			for _, o := range offers {
+				fmt.Fprintf(w, "%s\t", cell(o))
+			}
+			fmt.Fprintln(w)
+		}
+		row("", func(o offer) string { return o.Name })
+		row("Share price", func(o offer) string { return ac.FormatMoney(o.Price) })
+		row("Growth", func(o offer) string { return fmt.Sprintf("%.1f%%", o.Growth*100) })
+		row("Shares", func(o offer) string { return formatShares(o.shares()) })
+		for year := 1; year <= compareYears; year++ {
+			row(fmt.Sprintf("Year %d", year), func(o offer) string { return ac.FormatMoney(o.yearValue(year, now)) })
+		}
+		row("Total", func(o offer) string { return ac.FormatMoney(o.total(now)) })
+		if forfeit > 0 {
+			row("Forfeited", func(o offer) string { return ac.FormatMoney(-forfeit) })
+			row("Net", func(o offer) string { return ac.FormatMoney(o.total(now) - forfeit) })
+		}
+		if offers[0].Salary > 0 || offers[1].Salary > 0 {
+			row("Salary", func(o offer) string { return ac.FormatMoney(o.Salary) })
+		}
+		w.Flush()
+		fmt.Println()
+		fmt.Printf("Values are hypothetical, assuming each share price grows at the rate shown.\n")
+		if forfeit > 0 {
+			fmt.Printf("Leaving your current grants would forfeit %s of unvested equity at today's price.\n", ac.FormatMoney(forfeit))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().IntVar(&compareYears, "years", 4, "years to compare")
+}
+
+// offer is a job offer's equity, read from its own file.
+type offer struct {
+	Name   string
+	Price  float64
+	Growth float64
+	Salary float64
+	Grants []grant
+}
+
+// loadOffer reads an offer file, fetching a quote if it names a ticker
+// rather than a price.
+func loadOffer(path string, now time.Time) (offer, error) {
+	full, err := homedir.Expand(path)
+	if err != nil {
+		return offer{}, err
+	}
+	v := viper.New()
+	v.SetConfigFile(full)
+	err = v.ReadInConfig()
+	if err != nil {
+		return offer{}, fmt.Errorf("%s: %s", path, err)
+	}
+
+	o := offer{Name: v.GetString("name"), Price: v.GetFloat64("price"), Salary: v.GetFloat64("salary")}
+	if o.Name == "" {
+		o.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	o.Growth, err = configPercent(v.Get("growth"))
+	if err != nil {
+		return o, fmt.Errorf("%s: growth: %s", path, err)
+	}
+	if o.Price == 0 {
+		ticker := v.GetString("ticker")
+		if ticker == "" {
+			return o, fmt.Errorf("%s: set a price or a ticker", path)
+		}
+		quote, err := getQuote(ticker)
+		if err != nil {
+			return o, err
+		}
+		o.Price, err = strconv.ParseFloat(quote.GlobalQuote.Price, 64)
+		if err != nil {
+			return o, fmt.Errorf("%s: no quote for %s", path, ticker)
+		}
+	}
+
+	months, ok := frequencyMonths[v.GetString("vest-frequency")]
+	if !ok {
+		return o, fmt.Errorf("%s: invalid vest-frequency %q: expected monthly, quarterly or annual", path, v.GetString("vest-frequency"))
+	}
+	var configs []grantConfig
+	if v.IsSet("grants") {
+		err = v.UnmarshalKey("grants", &configs)
+	} else {
+		var gc grantConfig
+		err = v.Unmarshal(&gc)
+		gc.Name = o.Name
+		configs = append(configs, gc)
+	}
+	if err != nil {
+		return o, fmt.Errorf("%s: invalid grants: %s", path, err)
+	}
+	for i, gc := range configs {
+		if gc.Name == "" {
+			gc.Name = fmt.Sprintf("grant %d", i+1)
+		}
+		g, err := newGrant(gc, months)
+		if err != nil {
+			return o, fmt.Errorf("%s: %s: %s", path, gc.Name, err)
+		}
+		o.Grants = append(o.Grants, g)
+	}
+	return o, nil
+}
+
+func (o offer) shares() float64 {
+	total := 0.0
+	for _, g := range o.Grants {
+		total += g.Shares
+	}
+	return total
+}
+
+// yearValue is the value of the shares vesting in the given year from now,
+// at the price grown to the end of that year. Underwater options are worth
+// nothing.
+func (o offer) yearValue(year int, now time.Time) float64 {
+	from, to := now.AddDate(year-1, 0, 0), now.AddDate(year, 0, 0)
+	price := grownPrice(o.Price, o.Growth, now, to)
+	value := 0.0
+	for _, g := range o.Grants {
+		shares := g.vestedShares(to) - g.vestedShares(from)
+		per := price - g.StrikePrice
+		if g.isOption() {
+			per = math.Max(per, 0)
+		}
+		value += shares * per
+	}
+	return value
+}
+
+// total is the value vesting over the years compared.
+func (o offer) total(now time.Time) float64 {
+	total := 0.0
+	for year := 1; year <= compareYears; year++ {
+		total += o.yearValue(year, now)
+	}
+	return total
+}
+
+func writeCompareJSON(offers []offer, forfeit float64, now time.Time) error {
+	type jsonOffer struct {
+		Name       string    `json:"name"`
+		Price      float64   `json:"price"`
+		Growth     float64   `json:"growth"`
+		Shares     float64   `json:"shares"`
+		Salary     float64   `json:"salary,omitempty"`
+		YearValues []float64 `json:"year_values"`
+		Total      float64   `json:"total"`
+		Net        float64   `json:"net"`
+	}
+	out := struct {
+		SchemaVersion int         `json:"schema_version"`
+		Hypothetical  bool        `json:"hypothetical"`
+		Forfeited     float64     `json:"forfeited"`
+		Offers        []jsonOffer `json:"offers"`
+	}{SchemaVersion: schemaVersion, Hypothetical: true, Forfeited: forfeit}
+	for _, o := range offers {
+		jo := jsonOffer{Name: o.Name, Price: o.Price, Growth: o.Growth, Shares: o.shares(), Salary: o.Salary, Total: o.total(now)}
+		for year := 1; year <= compareYears; year++ {
+			jo.YearValues = append(jo.YearValues, o.yearValue(year, now))
+		}
+		jo.Net = jo.Total - forfeit
+		out.Offers = append(out.Offers, jo)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+)
+
+// defaultConcentrationLimit is the share of your net worth in one stock
+// above which worth warns you, unless concentration-warning says otherwise.
+const defaultConcentrationLimit = 0.10
+
+// concentration is how your net worth is configured: either all of it
+// (net-worth, the stock included) or everything besides the stock
+// (other-assets). Limit is the share above which you're warned.
+type concentration struct {
+	NetWorth    float64
+	OtherAssets float64
+	Limit       float64
+}
+
+// loadConcentration reads the net-worth, other-assets and
+// concentration-warning settings.
+func loadConcentration() (concentration, error) {
+	c := concentration{
+		NetWorth:    viper.GetFloat64("net-worth"),
+		OtherAssets: viper.GetFloat64("other-assets"),
+		Limit:       defaultConcentrationLimit,
+	}
+	if viper.IsSet("net-worth") && viper.IsSet("other-assets") {
+		return c, fmt.Errorf("net-worth and other-assets can't both be set")
+	}
+	if viper.IsSet("concentration-warning") {
+		var err error
+		c.Limit, err = configPercent(viper.Get("concentration-warning"))
+		if err != nil {
+			return c, fmt.Errorf("concentration-warning: %s", err)
+		}
+	}
+	return c, nil
+}
+
+// applyConcentration works out the share of your net worth held in the
+// stock's vested shares, which are counted in the home currency when
+// there is one.
+func (v *valuation) applyConcentration(c concentration) {
+	held := v.home(v.VestedValue)
+	netWorth := c.NetWorth
+	if c.OtherAssets > 0 {
+		netWorth = c.OtherAssets + held
+	}
+	if netWorth <= 0 {
+		return
+	}
+	v.Concentration = held / netWorth
+	v.ConcentrationLimit = c.Limit
+}
+
+// printConcentrationWarning warns when too much of your net worth is in
+// the stock.
+func printConcentrationWarning(v valuation) {
+	if v.ConcentrationLimit <= 0 || v.Concentration <= v.ConcentrationLimit {
+		return
+	}
+	line := fmt.Sprintf("WARNING: %d%% of your net worth is in %s, more than the %d%% you'd like. Consider diversifying.",
+		int64(v.Concentration*100), v.Ticker, int64(v.ConcentrationLimit*100))
+	if isTerminal(os.Stdout) {
+		line = "\x1b[1;31m" + line + "\x1b[0m"
+	}
+	fmt.Println(line)
+}
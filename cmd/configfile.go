@@ -0,0 +1,153 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readConfigNode parses the config file into a YAML node tree, so it can be
+// edited and written back without losing comments or key order. An empty
+// or missing file yields an empty mapping.
+func readConfigNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if isEncrypted(path) && err == nil {
+		data, err = decryptData(data, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var doc yaml.Node
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: expected a mapping at the top level", path)
+	}
+	return &doc, nil
+}
+
+// configGrants returns the grants list of a config document, adding an empty
+// one if it has none.
+func configGrants(doc *yaml.Node) (*yaml.Node, error) {
+	return configList(doc, "grants")
+}
+
+// configList returns the list under key in a config document, adding an
+// empty one if it has none.
+func configList(doc *yaml.Node, key string) (*yaml.Node, error) {
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			list := root.Content[i+1]
+			if list.Kind != yaml.SequenceNode {
+				return nil, fmt.Errorf("%s is not a list", key)
+			}
+			return list, nil
+		}
+	}
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, list)
+	return list, nil
+}
+
+// grantName returns the name of a grant in the grants list.
+func grantName(grant *yaml.Node) string {
+	return nodeField(grant, "name")
+}
+
+// nodeField returns the value of key in a mapping node, or "".
+func nodeField(node *yaml.Node, key string) string {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1].Value
+		}
+	}
+	return ""
+}
+
+// writeConfigNode writes the config document back to path. The new contents
+// go to a temporary file in the same directory that then replaces the
+// original, so a failure part way through never leaves a truncated config,
+// and the previous version is kept alongside as path.bak.
+func writeConfigNode(path string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err := enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+	err = enc.Close()
+	if err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if isEncrypted(path) {
+		data, err = encryptData(data, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+		old, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(path+".bak", old, mode)
+		if err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".worth-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,92 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var countdownSeconds bool
+var countdownNext bool
+
+// countdownCmd represents the countdown command
+var countdownCmd = &cobra.Command{
+	Use:   "countdown",
+	Short: "Print the time left until you're fully vested.",
+	Long: `Print just the time remaining until you're fully vested, and with --next
+until the next vest event (such as a cliff or tranche). With --seconds the
+times are printed as whole seconds, one per line, for use in scripts.
+No stock quote is fetched.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		grants, err := loadGrants()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		var end time.Time
+		for _, g := range grants {
+			if g.vestEnd().After(end) {
+				end = g.vestEnd()
+			}
+		}
+		fmt.Println(formatCountdown(now, end))
+
+		if countdownNext {
+			vests := upcomingVests(grants, now)
+			if len(vests) == 0 {
+				return
+			}
+			next := vests[0].Date
+			if countdownSeconds {
+				fmt.Println(formatCountdown(now, next))
+				return
+			}
+			fmt.Printf("next vest: %s (%s)\n", formatCountdown(now, next), next.Format("Jan 2, 2006"))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(countdownCmd)
+
+	countdownCmd.Flags().BoolVar(&countdownSeconds, "seconds", false, "print the time left in seconds")
+	countdownCmd.Flags().BoolVar(&countdownNext, "next", false, "also print the time until the next vest event")
+}
+
+func formatCountdown(now, to time.Time) string {
+	if countdownSeconds {
+		return fmt.Sprint(max(roundTime(to.Sub(now).Seconds()), 0))
+	}
+	if !to.After(now) {
+		return "fully vested"
+	}
+	remaining := strings.TrimSpace(printRemaining(now, to))
+	if remaining == "" {
+		return "less than a day"
+	}
+	return remaining
+}
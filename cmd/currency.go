@@ -0,0 +1,138 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/leekchan/accounting"
+	"github.com/spf13/viper"
+)
+
+// currencySymbols are the symbols money is printed with, by ISO code. Other
+// currencies are printed with their code.
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CAD": "C$",
+	"AUD": "A$",
+	"CHF": "CHF ",
+	"INR": "₹",
+}
+
+// currencySymbol returns the symbol to print amounts in code with.
+func currencySymbol(code string) string {
+	if symbol, ok := currencySymbols[code]; ok {
+		return symbol
+	}
+	return code + " "
+}
+
+// stockCurrency returns the currency a position trades in: its own, its
+// exchange's, the one its company reports, the configured currency, or US
+// dollars.
+func stockCurrency(p position, overview JsonOverview) string {
+	if p.Currency != "" {
+		return p.Currency
+	}
+	if c := symbolExchange(p.Ticker).Currency; c != "" {
+		return c
+	}
+	if overview.Currency != "" {
+		return strings.ToUpper(overview.Currency)
+	}
+	if c := viper.GetString("currency"); c != "" {
+		return strings.ToUpper(c)
+	}
+	return "USD"
+}
+
+// homeCurrency returns the currency values are converted to, or "" to
+// leave them in the stock's.
+func homeCurrency() string {
+	return strings.ToUpper(viper.GetString("home-currency"))
+}
+
+// home converts an amount in the stock's currency to the home currency,
+// leaving it as is when there's none.
+func (v valuation) home(amount float64) float64 {
+	if v.HomeCurrency == "" {
+		return amount
+	}
+	return amount * v.ExchangeRate
+}
+
+// convertTo has the valuation's amounts shown in currency as well, fetching
+// the exchange rate when they aren't already.
+func (v *valuation) convertTo(currency string) error {
+	if v.Currency == currency || v.HomeCurrency == currency {
+		return nil
+	}
+	rate, err := getExchangeRate(v.Currency, currency)
+	if err != nil {
+		return err
+	}
+	v.HomeCurrency, v.ExchangeRate = currency, rate
+	return nil
+}
+
+// moneyFormat prints amounts in the stock's currency and, when a home
+// currency is configured, converted to it alongside.
+type moneyFormat struct {
+	stock accounting.Accounting
+	home  accounting.Accounting
+	rate  float64
+}
+
+// money returns the format for the valuation's amounts.
+func (v valuation) money() moneyFormat {
+	m := currencyMoney(v.Currency)
+	if v.HomeCurrency != "" {
+		m.home = accounting.Accounting{Symbol: currencySymbol(v.HomeCurrency), Precision: 2}
+		m.rate = v.ExchangeRate
+	}
+	return m
+}
+
+// currencyMoney returns the format for amounts in currency, unconverted.
+func currencyMoney(currency string) moneyFormat {
+	return moneyFormat{stock: accounting.Accounting{Symbol: currencySymbol(currency), Precision: 2}}
+}
+
+// configuredMoney returns the format for amounts in the first position's
+// currency, for commands that don't quote the stock.
+func configuredMoney() moneyFormat {
+	var p position
+	if positions, err := loadPositions(); err == nil && len(positions) > 0 {
+		p = positions[0]
+	}
+	return currencyMoney(stockCurrency(p, JsonOverview{}))
+}
+
+// FormatMoney formats amount, e.g. "$1,000.00 / €920.00".
+func (m moneyFormat) FormatMoney(amount float64) string {
+	if m.rate == 0 {
+		return m.stock.FormatMoney(amount)
+	}
+	return fmt.Sprintf("%s / %s", m.stock.FormatMoney(amount), m.home.FormatMoney(amount*m.rate))
+}
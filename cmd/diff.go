@@ -0,0 +1,227 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var diffFrom string
+var diffTo string
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show how your shares' value changed between two dates.",
+	Long: `Compare the share price, your vested shares and their value on --from
+with those on --to (today by default). Each date is taken from the
+snapshot history when a snapshot was recorded that day, or else the
+shares are valued at that day's closing price (today's, for today).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if diffFrom == "" {
+			fmt.Println("diff: --from is required")
+			os.Exit(1)
+		}
+		now := time.Now()
+		from, err := diffDate(diffFrom, now)
+		if err != nil {
+			fmt.Printf("diff: --from: %s\n", err)
+			os.Exit(1)
+		}
+		to, err := diffDate(diffTo, now)
+		if err != nil {
+			fmt.Printf("diff: --to: %s\n", err)
+			os.Exit(1)
+		}
+		if !from.Before(to) {
+			fmt.Println("diff: --from must be before --to")
+			os.Exit(1)
+		}
+		if to.After(now) {
+			fmt.Println("diff: --to can't be in the future")
+			os.Exit(1)
+		}
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sales, err := loadLedger()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		snapshots, err := loadSnapshots()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		var grants []grant
+		for _, gv := range v.Grants {
+			grants = append(grants, gv.grant)
+		}
+		today := v.Price
+		if v.QuotedPrice > 0 {
+			today = v.QuotedPrice
+		}
+		var prices []pricePoint
+		at := func(t time.Time) (valuation, error) {
+			if t.Format("2006-01-02") == now.Format("2006-01-02") {
+				return valuate(grants, today, now, sales), nil
+			}
+			if s, ok := snapshotOn(snapshots, v, t); ok {
+				return valuation{Price: s.Price, SharesVestedUnsold: s.SharesVested, SharesUnvested: s.SharesUnvested,
+					VestedValue: s.VestedValue, UnvestedValue: s.UnvestedValue, TotalValue: s.TotalValue}, nil
+			}
+			if v.Private != nil {
+				return valuation{}, fmt.Errorf("no snapshot on %s, and there's no price history for a private company", t.Format("2006-01-02"))
+			}
+			if prices == nil {
+				prices, err = pricesSince(v.Ticker, from, now)
+				if err != nil {
+					return valuation{}, err
+				}
+				if len(prices) == 0 || prices[0].Date.After(from.AddDate(0, 0, 7)) {
+					return valuation{}, fmt.Errorf("no snapshot or price history back to %s", from.Format("2006-01-02"))
+				}
+			}
+			return valuate(grants, closeOn(prices, t), t, sales), nil
+		}
+		then, err := at(from)
+		if err != nil {
+			fmt.Printf("diff: %s\n", err)
+			os.Exit(1)
+		}
+		later, err := at(to)
+		if err != nil {
+			fmt.Printf("diff: %s\n", err)
+			os.Exit(1)
+		}
+		sold := 0.0
+		for _, r := range sales {
+			if r.Date.After(from) && !r.Date.After(to) {
+				sold += r.Shares
+			}
+		}
+
+		if viper.GetString("output") == "json" {
+			err = writeDiffJSON(v.Ticker, from, to, then, later)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "\t%s\t%s\tChange\t\t\n", from.Format("Jan 2, 2006"), to.Format("Jan 2, 2006"))
+		money := func(label string, a, b float64) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", label, ac.FormatMoney(a), ac.FormatMoney(b),
+				signedMoney(ac.FormatMoney(b-a), b-a), diffPercent(a, b))
+		}
+		shares := func(label string, a, b float64) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%+g\t%s\t\n", label, formatShares(a), formatShares(b), b-a, diffPercent(a, b))
+		}
+		money("Price", then.Price, later.Price)
+		shares("Vested shares", then.SharesVestedUnsold, later.SharesVestedUnsold)
+		money("Vested value", then.VestedValue, later.VestedValue)
+		money("Unvested value", then.UnvestedValue, later.UnvestedValue)
+		money("Total value", then.TotalValue, later.TotalValue)
+		w.Flush()
+		if sold > 0 {
+			fmt.Printf("\n%s shares were sold in between.\n", formatShares(sold))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVar(&diffFrom, "from", "", "the earlier date")
+	diffCmd.Flags().StringVar(&diffTo, "to", "today", "the later date")
+}
+
+// diffDate parses a --from or --to date, which may also be "today", for
+// now.
+func diffDate(s string, now time.Time) (time.Time, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "today") {
+		return now, nil
+	}
+	return configDate(s)
+}
+
+// signedMoney puts a plus sign in front of a formatted increase.
+func signedMoney(formatted string, amount float64) string {
+	if amount > 0 {
+		return "+" + formatted
+	}
+	return formatted
+}
+
+// diffPercent is the change from a to b as a percentage, blank when a is 0.
+func diffPercent(a, b float64) string {
+	if a == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%+.1f%%", (b-a)/a*100)
+}
+
+// jsonDiffPoint is one end of a diff, or the change between them, in the
+// JSON output.
+type jsonDiffPoint struct {
+	Price         float64 `json:"price"`
+	SharesVested  float64 `json:"shares_vested_unsold"`
+	VestedValue   float64 `json:"vested_value"`
+	UnvestedValue float64 `json:"unvested_value"`
+	TotalValue    float64 `json:"total_value"`
+}
+
+func newJsonDiffPoint(v valuation) jsonDiffPoint {
+	return jsonDiffPoint{Price: v.Price, SharesVested: v.SharesVestedUnsold,
+		VestedValue: v.VestedValue, UnvestedValue: v.UnvestedValue, TotalValue: v.TotalValue}
+}
+
+func writeDiffJSON(ticker string, from, to time.Time, then, later valuation) error {
+	out := struct {
+		SchemaVersion int           `json:"schema_version"`
+		Ticker        string        `json:"ticker"`
+		From          time.Time     `json:"from"`
+		To            time.Time     `json:"to"`
+		Start         jsonDiffPoint `json:"start"`
+		End           jsonDiffPoint `json:"end"`
+		Change        jsonDiffPoint `json:"change"`
+	}{SchemaVersion: schemaVersion, Ticker: ticker, From: from, To: to, Start: newJsonDiffPoint(then), End: newJsonDiffPoint(later)}
+	out.Change = jsonDiffPoint{Price: later.Price - then.Price, SharesVested: later.SharesVestedUnsold - then.SharesVestedUnsold,
+		VestedValue: later.VestedValue - then.VestedValue, UnvestedValue: later.UnvestedValue - then.UnvestedValue,
+		TotalValue: later.TotalValue - then.TotalValue}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
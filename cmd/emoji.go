@@ -0,0 +1,103 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// defaultEmojiFields are the fields printed by --emoji unless emoji-fields is
+// configured.
+var defaultEmojiFields = []string{"price", "vested", "remaining"}
+
+// emojiFieldNames lists every field --emoji knows how to render, in the
+// order they are documented.
+var emojiFieldNames = []string{"price", "change", "total", "vested", "unvested", "percent", "remaining", "rate"}
+
+// formatEmoji renders a one-line summary such as
+// "📈 $212 | 💰 $148k vested | ⏳ 1y4m" from the requested fields.
+func formatEmoji(v valuation, fields []string) (string, error) {
+	var parts []string
+	for _, field := range fields {
+		part, err := emojiField(v, strings.TrimSpace(field))
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " | "), nil
+}
+
+func emojiField(v valuation, field string) (string, error) {
+	switch field {
+	case "price":
+		trend := "📈"
+		if v.Change < 0 {
+			trend = "📉"
+		}
+		return fmt.Sprintf("%s %s%.0f", trend, currencySymbol(v.Currency), v.Price), nil
+	case "change":
+		arrow := "🔺"
+		if v.Change < 0 {
+			arrow = "🔻"
+		}
+		return fmt.Sprintf("%s %.1f%%", arrow, math.Abs(v.ChangePercent)), nil
+	case "total":
+		return fmt.Sprintf("🏦 %s total", compactMoney(v.TotalValue, v.Currency)), nil
+	case "vested":
+		return fmt.Sprintf("💰 %s vested", compactMoney(v.VestedValue, v.Currency)), nil
+	case "unvested":
+		return fmt.Sprintf("🔒 %s unvested", compactMoney(v.UnvestedValue, v.Currency)), nil
+	case "percent":
+		return fmt.Sprintf("📊 %d%%", int64(v.PortionDone*100)), nil
+	case "remaining":
+		if v.PortionDone >= 1.0 {
+			return "🎉 fully vested", nil
+		}
+		return fmt.Sprintf("⏳ %s", compactRemaining(v.AsOf, v.VestEnd)), nil
+	case "rate":
+		return fmt.Sprintf("⏱️ %s/day", compactMoney(v.VestingPerDay, v.Currency)), nil
+	}
+	return "", fmt.Errorf("unknown emoji field %q (known fields: %s)", field, strings.Join(emojiFieldNames, ", "))
+}
+
+// compactRemaining formats the time from one date to another tersely, e.g.
+// "1y4m" or "12d".
+func compactRemaining(from, to time.Time) string {
+	years, months, days := calendarDiff(from, to)
+	var b strings.Builder
+	if years > 0 {
+		fmt.Fprintf(&b, "%dy", years)
+	}
+	if months > 0 {
+		fmt.Fprintf(&b, "%dm", months)
+	}
+	if days > 0 && years == 0 {
+		fmt.Fprintf(&b, "%dd", days)
+	}
+	if b.Len() == 0 {
+		return "0d"
+	}
+	return b.String()
+}
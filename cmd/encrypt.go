@@ -0,0 +1,238 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// encryptedSuffix marks a file encrypted with age under a passphrase. The
+// grants-file and the snapshot history are read and written encrypted when
+// their names end in it.
+const encryptedSuffix = ".age"
+
+// cachedPassphrase is the passphrase once it has been asked for, so it's
+// only asked for once a run.
+var cachedPassphrase string
+
+// encryptCmd represents the encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt FILE",
+	Short: "Encrypt a grants file or snapshot history with a passphrase.",
+	Long: `Encrypt FILE with age under a passphrase, writing FILE.age beside it.
+Point grants-file or snapshots at the new file and delete the original, and
+worth reads and writes it encrypted from then on.
+
+The passphrase is taken from the WORTH_PASSPHRASE environment variable, or
+the output of passphrase-command, which can look it up in your keyring, e.g.
+"secret-tool lookup service worth" or "security find-generic-password -s
+worth -w"; otherwise it's asked for.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		plain, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sealed, err := encryptData(plain, true)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		path := args[0] + encryptedSuffix
+		err = writeFileAtomic(path, sealed)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s; delete %s once your config points at it.\n", path, args[0])
+	},
+}
+
+// decryptCmd represents the decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt FILE.age",
+	Short: "Decrypt a file encrypted by worth encrypt.",
+	Long:  `Decrypt FILE.age, writing FILE beside it.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !strings.HasSuffix(args[0], encryptedSuffix) {
+			fmt.Printf("decrypt: %s doesn't end in %s\n", args[0], encryptedSuffix)
+			os.Exit(1)
+		}
+		plain, err := readFileEncrypted(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		path := strings.TrimSuffix(args[0], encryptedSuffix)
+		err = writeFileAtomic(path, plain)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %s.\n", path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+}
+
+// isEncrypted reports whether a file's name says it's encrypted.
+func isEncrypted(path string) bool {
+	return strings.HasSuffix(path, encryptedSuffix)
+}
+
+// passphrase returns the passphrase from WORTH_PASSPHRASE, the
+// passphrase-command, or the terminal; confirm asks for it twice there, for
+// a new one.
+func passphrase(confirm bool) (string, error) {
+	if cachedPassphrase != "" {
+		return cachedPassphrase, nil
+	}
+	if p := os.Getenv("WORTH_PASSPHRASE"); p != "" {
+		cachedPassphrase = p
+		return p, nil
+	}
+	if command := viper.GetString("passphrase-command"); command != "" {
+		out, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			return "", fmt.Errorf("passphrase-command: %s", err)
+		}
+		cachedPassphrase = strings.TrimRight(string(out), "\r\n")
+		if cachedPassphrase == "" {
+			return "", fmt.Errorf("passphrase-command printed no passphrase")
+		}
+		return cachedPassphrase, nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no passphrase: set WORTH_PASSPHRASE or passphrase-command")
+	}
+	defer tty.Close()
+	ask := func(prompt string) (string, error) {
+		fmt.Fprint(tty, prompt)
+		p, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		return string(p), err
+	}
+	p, err := ask("Passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", fmt.Errorf("no passphrase given")
+	}
+	if confirm {
+		again, err := ask("Again: ")
+		if err != nil {
+			return "", err
+		}
+		if again != p {
+			return "", fmt.Errorf("the passphrases don't match")
+		}
+	}
+	cachedPassphrase = p
+	return p, nil
+}
+
+// encryptData encrypts plain under the passphrase.
+func encryptData(plain []byte, confirm bool) ([]byte, error) {
+	p, err := passphrase(confirm)
+	if err != nil {
+		return nil, err
+	}
+	// age's default work factor, which makes guessing the passphrase
+	// costly at the price of a second or so each time a file is written
+	recipient, err := age.NewScryptRecipient(p)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, recipient)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(plain)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	return buf.Bytes(), err
+}
+
+// decryptData decrypts what encryptData encrypted; name says what it is
+// in errors.
+func decryptData(sealed []byte, name string) ([]byte, error) {
+	p, err := passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := age.NewScryptIdentity(p)
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(sealed), identity)
+	if _, ok := err.(*age.NoIdentityMatchError); ok {
+		return nil, fmt.Errorf("%s: wrong passphrase", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return io.ReadAll(r)
+}
+
+// readFileEncrypted reads and decrypts an encrypted file.
+func readFileEncrypted(path string) ([]byte, error) {
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decryptData(sealed, path)
+}
+
+// writeFileAtomic replaces path with data by way of a temporary file, so a
+// failure never leaves it half written.
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,255 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// esppCmd represents the espp command
+var esppCmd = &cobra.Command{
+	Use:   "espp",
+	Short: "Show the shares your ESPP grants buy and their bargain element.",
+	Long: `Show each purchase of your ESPP grants (type: espp): the offering and
+purchase date prices, the discounted price paid, the shares the period's
+contribution bought and their bargain element, the discount taxed as income
+when they're sold. Past purchases use the closing prices then; those still
+to come are estimated at today's price.
+
+With lookback, the discount applies to the lower of the price at the start
+of the offering and the price on the purchase date. Purchased shares show up
+as lots in worth lots, qualifying for the favourable tax treatment once held
+two years past the offering start and a year past the purchase.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		purchases, err := loadESPPPurchases(v, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if viper.GetString("output") == "json" {
+			err = writeESPPJSON(v, purchases)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(purchases) == 0 {
+			fmt.Println("No ESPP grants are configured; add one to grants with type: espp.")
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Grant\tPurchase\tOffering price\tFMV\tPrice paid\tShares\tBargain element\t")
+		var shares, paid, bargain float64
+		for _, p := range purchases {
+			date := p.Date.Format("Jan 2, 2006")
+			if p.Estimated {
+				date += " (est.)"
+			} else {
+				shares += p.Shares
+				paid += p.Contribution
+				bargain += p.Bargain
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", p.Grant, date, ac.FormatMoney(p.OfferingPrice), ac.FormatMoney(p.FMV),
+				ac.FormatMoney(p.Price), formatShares(p.Shares), ac.FormatMoney(p.Bargain))
+		}
+		w.Flush()
+
+		fmt.Println()
+		if shares > 0 {
+			fmt.Printf("Bought %s shares for %s so far, now worth %s, with a bargain element of %s.\n",
+				formatShares(shares), ac.FormatMoney(paid), ac.FormatMoney(shares*v.Price), ac.FormatMoney(bargain))
+		}
+		for _, p := range purchases {
+			if p.Estimated {
+				fmt.Printf("The next purchase, on %s, would buy about %s shares at %s.\n",
+					p.Date.Format("Jan 2, 2006"), formatShares(p.Shares), ac.FormatMoney(p.Price))
+				break
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(esppCmd)
+}
+
+// useESPP reads the settings of an employee stock purchase plan grant. Its
+// shares aren't known up front but bought with each period's contribution,
+// so it never vests any on its own.
+func (g *grant) useESPP(gc grantConfig) error {
+	if gc.Shares != 0 || len(gc.Tranches) > 0 {
+		return fmt.Errorf("espp grants buy shares with their contribution; shares and tranches can't be set")
+	}
+	if gc.Contribution <= 0 {
+		return fmt.Errorf("espp grants need a contribution per purchase period")
+	}
+	g.Contribution = gc.Contribution
+	g.Lookback = gc.Lookback
+
+	var err error
+	g.Discount = 0.15
+	if gc.Discount != nil {
+		g.Discount, err = configPercent(gc.Discount)
+		if err != nil {
+			return fmt.Errorf("discount: %s", err)
+		}
+	}
+	if g.Discount < 0 || g.Discount >= 1 {
+		return fmt.Errorf("discount %.0f%% is outside 0%%-100%%", g.Discount*100)
+	}
+
+	g.PurchasePeriod = gc.PurchaseEvery
+	if g.PurchasePeriod == "" {
+		g.PurchasePeriod = "6m"
+	}
+	years, months, days, err := parseSpan(g.PurchasePeriod)
+	if err != nil || years+months+days <= 0 {
+		return fmt.Errorf("invalid purchase-period %q: expected a span such as 6m", g.PurchasePeriod)
+	}
+	g.TargetShares, g.Multiplier = 0, 1
+	return nil
+}
+
+func (g grant) isESPP() bool {
+	return g.Type == "espp"
+}
+
+// purchaseDates returns the ends of the grant's purchase periods, from one
+// period after the offering starts up to the end of the offering.
+func (g grant) purchaseDates() []time.Time {
+	years, months, days, _ := parseSpan(g.PurchasePeriod)
+	var dates []time.Time
+	for n := 1; ; n++ {
+		date := addSpan(g.Start, years*n, months*n, days*n)
+		if date.After(g.End) {
+			return dates
+		}
+		dates = append(dates, date)
+	}
+}
+
+// esppPurchase is one purchase of an ESPP grant. Bargain is the discount
+// below the purchase date's price, taxed as income when the shares are sold.
+type esppPurchase struct {
+	Grant         string
+	Date          time.Time
+	OfferingPrice float64
+	FMV           float64
+	Price         float64
+	Contribution  float64
+	Shares        float64
+	Bargain       float64
+	Estimated     bool
+	QualifiesOn   time.Time
+}
+
+// esppPurchases works out the purchases of an ESPP grant, using the closing
+// prices for those up to now and price for the rest.
+func esppPurchases(g grant, prices []pricePoint, price float64, now time.Time) []esppPurchase {
+	offering := price
+	if !g.Start.After(now) {
+		offering = closeOn(prices, g.Start)
+	}
+	var purchases []esppPurchase
+	for _, date := range g.purchaseDates() {
+		p := esppPurchase{Grant: g.Name, Date: date, OfferingPrice: offering, FMV: price, Contribution: g.Contribution,
+			Estimated: date.After(now), QualifiesOn: qualifyingDate(g.Start, date)}
+		if !p.Estimated {
+			p.FMV = closeOn(prices, date)
+		}
+		p.Price = p.FMV
+		if g.Lookback && offering < p.Price {
+			p.Price = offering
+		}
+		p.Price *= 1 - g.Discount
+		if p.Price > 0 {
+			p.Shares = g.Contribution / p.Price
+		}
+		p.Bargain = p.Shares * (p.FMV - p.Price)
+		purchases = append(purchases, p)
+	}
+	return purchases
+}
+
+// loadESPPPurchases fetches the closing prices since the earliest offering
+// and works out the purchases of every ESPP grant.
+func loadESPPPurchases(v valuation, now time.Time) ([]esppPurchase, error) {
+	var since time.Time
+	for _, g := range v.Grants {
+		if g.isESPP() && g.Start.Before(now) && (since.IsZero() || g.Start.Before(since)) {
+			since = g.Start
+		}
+	}
+	prices, err := pricesSince(v.Ticker, since, now)
+	if err != nil {
+		return nil, err
+	}
+	var purchases []esppPurchase
+	for _, g := range v.Grants {
+		if g.isESPP() {
+			purchases = append(purchases, esppPurchases(g.grant, prices, v.Price, now)...)
+		}
+	}
+	return purchases, nil
+}
+
+func writeESPPJSON(v valuation, purchases []esppPurchase) error {
+	type jsonPurchase struct {
+		Grant         string    `json:"grant"`
+		Date          time.Time `json:"date"`
+		OfferingPrice float64   `json:"offering_price"`
+		FMV           float64   `json:"fmv"`
+		Price         float64   `json:"price_paid"`
+		Contribution  float64   `json:"contribution"`
+		Shares        float64   `json:"shares"`
+		Bargain       float64   `json:"bargain_element"`
+		Estimated     bool      `json:"estimated"`
+		QualifiesOn   time.Time `json:"qualifies_on"`
+	}
+	out := struct {
+		SchemaVersion int            `json:"schema_version"`
+		Ticker        string         `json:"ticker"`
+		Price         float64        `json:"price"`
+		Purchases     []jsonPurchase `json:"purchases"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Purchases: []jsonPurchase{}}
+	for _, p := range purchases {
+		out.Purchases = append(out.Purchases, jsonPurchase(p))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
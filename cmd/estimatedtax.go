@@ -0,0 +1,247 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// safeHarbor is the share of the year's tax that must be paid through
+// withholding and estimated payments to avoid an underpayment penalty.
+const safeHarbor = 0.9
+
+var estimatedPaid float64
+
+// estimatedTaxCmd represents the estimated-tax command
+var estimatedTaxCmd = &cobra.Command{
+	Use:   "estimated-tax",
+	Short: "Estimate the quarterly tax payments your vests call for.",
+	Long: `Add up the income from this year's vests (at each vest date's closing
+price) and option exercises, estimate the tax on it with the tax settings,
+and compare that with what supplemental withholding has covered. Whatever
+falls short of 90% of the tax is split across the estimated tax due dates
+still to come. Use --paid for estimated payments already made this year.
+
+The prior-year safe harbor (100% or 110% of last year's tax) isn't
+considered; if it applies to you, you may need to pay less.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !v.Tax.Set || v.Tax.Country != "us" {
+			fmt.Println("estimated-tax: only applies to US taxes; set tax income and supplemental-withholding")
+			os.Exit(1)
+		}
+		e, err := loadEstimatedTax(v, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if viper.GetString("output") == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(e.json())
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(e.Events) == 0 {
+			fmt.Printf("Nothing has vested or been exercised yet in %d.\n", now.Year())
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Date\tGrant\tShares\tPrice\tIncome\tWithheld\t")
+		for _, ev := range e.Events {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", ev.Date.Format("Jan 2, 2006"), ev.Grant, formatShares(ev.Shares),
+				ac.FormatMoney(ev.Price), ac.FormatMoney(ev.Income), ac.FormatMoney(v.Tax.withheld(ev.Income)))
+		}
+		w.Flush()
+
+		fmt.Println()
+		fmt.Printf("Equity income so far in %d is %s, with an estimated %s in tax on it.\n", now.Year(), ac.FormatMoney(e.Income), ac.FormatMoney(e.Tax))
+		fmt.Printf("Withholding has covered %s", ac.FormatMoney(e.Withheld))
+		if e.Paid > 0 {
+			fmt.Printf(" and you've paid %s in estimated tax", ac.FormatMoney(e.Paid))
+		}
+		fmt.Println(".")
+		if e.Due <= 0 {
+			fmt.Println("That's enough to meet the 90% safe harbor; no estimated payment is needed.")
+			return
+		}
+		if len(e.Dates) == 1 {
+			fmt.Printf("To meet the 90%% safe harbor, pay about %s more by %s.\n", ac.FormatMoney(e.Due), e.Dates[0].Format("Jan 2, 2006"))
+			return
+		}
+		var dates []string
+		for _, d := range e.Dates {
+			dates = append(dates, d.Format("Jan 2"))
+		}
+		fmt.Printf("To meet the 90%% safe harbor, pay about %s more: %s on each of %s.\n",
+			ac.FormatMoney(e.Due), ac.FormatMoney(e.Due/float64(len(e.Dates))), strings.Join(dates, ", "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(estimatedTaxCmd)
+
+	estimatedTaxCmd.Flags().Float64Var(&estimatedPaid, "paid", 0, "estimated tax already paid this year")
+}
+
+// incomeEvent is a vest or exercise that created taxable income.
+type incomeEvent struct {
+	Date   time.Time
+	Grant  string
+	Shares float64
+	Price  float64
+	Income float64
+}
+
+// estimatedTax is the year's equity income so far and the estimated tax
+// payments still needed for it.
+type estimatedTax struct {
+	Events   []incomeEvent
+	Income   float64
+	Tax      float64
+	Withheld float64
+	Paid     float64
+	Due      float64
+	Dates    []time.Time
+}
+
+// loadEstimatedTax values this year's vests and exercises at their closing
+// prices and works out the payments due.
+func loadEstimatedTax(v valuation, now time.Time) (estimatedTax, error) {
+	var grants []grant
+	for _, g := range v.Grants {
+		grants = append(grants, g.grant)
+	}
+	exercises, err := loadExercises(grants)
+	if err != nil {
+		return estimatedTax{}, err
+	}
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	prices, err := pricesSince(v.Ticker, yearStart, now)
+	if err != nil {
+		return estimatedTax{}, err
+	}
+	return newEstimatedTax(v.Tax, grants, exercises, prices, now), nil
+}
+
+func newEstimatedTax(t taxRates, grants []grant, exercises []exercise, prices []pricePoint, now time.Time) estimatedTax {
+	yearStart := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+	e := estimatedTax{Paid: estimatedPaid}
+	for _, g := range grants {
+		if g.isOption() {
+			continue
+		}
+		for _, vest := range g.pastVests(now) {
+			if vest.Date.Before(yearStart) {
+				continue
+			}
+			price := closeOn(prices, vest.Date)
+			e.Events = append(e.Events, incomeEvent{Date: vest.Date, Grant: g.Name, Shares: vest.Shares, Price: price, Income: vest.Shares * price})
+		}
+	}
+	for _, ex := range exercises {
+		if ex.Date.Before(yearStart) || ex.Date.After(now) {
+			continue
+		}
+		for _, g := range grants {
+			if g.Name == ex.Grant {
+				price := closeOn(prices, ex.Date)
+				income := t.optionIncome(g, ex.Shares*math.Max(price-g.StrikePrice, 0))
+				e.Events = append(e.Events, incomeEvent{Date: ex.Date, Grant: g.Name, Shares: ex.Shares, Price: price, Income: income})
+			}
+		}
+	}
+	sort.SliceStable(e.Events, func(i, j int) bool { return e.Events[i].Date.Before(e.Events[j].Date) })
+
+	for _, ev := range e.Events {
+		e.Income += ev.Income
+		e.Withheld += t.withheld(ev.Income)
+	}
+	e.Tax = t.owed(e.Income)
+	e.Due = math.Max(safeHarbor*e.Tax-e.Withheld-e.Paid, 0)
+	e.Dates = estimatedTaxDates(now)
+	return e
+}
+
+// estimatedTaxDates returns the US estimated tax due dates for now's tax
+// year that haven't passed: April 15, June 15, September 15 and January 15
+// of the following year.
+func estimatedTaxDates(now time.Time) []time.Time {
+	y := now.Year()
+	today := time.Date(y, now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var dates []time.Time
+	for _, d := range []time.Time{
+		time.Date(y, time.April, 15, 0, 0, 0, 0, now.Location()),
+		time.Date(y, time.June, 15, 0, 0, 0, 0, now.Location()),
+		time.Date(y, time.September, 15, 0, 0, 0, 0, now.Location()),
+		time.Date(y+1, time.January, 15, 0, 0, 0, 0, now.Location()),
+	} {
+		if !d.Before(today) {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+func (e estimatedTax) json() interface{} {
+	type jsonIncome struct {
+		Date   time.Time `json:"date"`
+		Grant  string    `json:"grant"`
+		Shares float64   `json:"shares"`
+		Price  float64   `json:"price"`
+		Income float64   `json:"income"`
+	}
+	out := struct {
+		SchemaVersion int          `json:"schema_version"`
+		Income        float64      `json:"income"`
+		Tax           float64      `json:"tax"`
+		Withheld      float64      `json:"withheld"`
+		Paid          float64      `json:"paid"`
+		Due           float64      `json:"due"`
+		DueDates      []time.Time  `json:"due_dates"`
+		Events        []jsonIncome `json:"events"`
+	}{SchemaVersion: schemaVersion, Income: e.Income, Tax: e.Tax, Withheld: e.Withheld, Paid: e.Paid, Due: e.Due,
+		DueDates: e.Dates, Events: []jsonIncome{}}
+	for _, ev := range e.Events {
+		out.Events = append(out.Events, jsonIncome(ev))
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"strings"
+	"time"
+	_ "time/tzdata"
+)
+
+// exchange is a stock exchange outside the US, told apart by the suffix on
+// its symbols (SHOP.TRT, SAP.DEX). Prices are in Currency; LSE prices are
+// quoted in pence (GBX).
+type exchange struct {
+	Name     string
+	Currency string
+	Location string
+	Opens    string
+	Closes   string
+}
+
+// exchanges are keyed by AlphaVantage's symbol suffix. Symbols without one
+// trade in New York.
+var exchanges = map[string]exchange{
+	"":    {Name: "NYSE", Location: "America/New_York", Opens: "09:30", Closes: "16:00"},
+	"TRT": {Name: "Toronto", Currency: "CAD", Location: "America/Toronto", Opens: "09:30", Closes: "16:00"},
+	"TRV": {Name: "TSX Venture", Currency: "CAD", Location: "America/Toronto", Opens: "09:30", Closes: "16:00"},
+	"LON": {Name: "London", Currency: "GBX", Location: "Europe/London", Opens: "08:00", Closes: "16:30"},
+	"DEX": {Name: "XETRA", Currency: "EUR", Location: "Europe/Berlin", Opens: "09:00", Closes: "17:30"},
+	"FRK": {Name: "Frankfurt", Currency: "EUR", Location: "Europe/Berlin", Opens: "08:00", Closes: "22:00"},
+	"BSE": {Name: "Bombay", Currency: "INR", Location: "Asia/Kolkata", Opens: "09:15", Closes: "15:30"},
+	"SHH": {Name: "Shanghai", Currency: "CNY", Location: "Asia/Shanghai", Opens: "09:30", Closes: "15:00"},
+	"SHZ": {Name: "Shenzhen", Currency: "CNY", Location: "Asia/Shanghai", Opens: "09:30", Closes: "15:00"},
+}
+
+// symbolSuffixes maps the suffixes other data sources use (SHOP.TO,
+// SAP.DE) to AlphaVantage's.
+var symbolSuffixes = map[string]string{
+	"TO": "TRT",
+	"V":  "TRV",
+	"L":  "LON",
+	"DE": "DEX",
+	"F":  "FRK",
+	"BO": "BSE",
+	"SS": "SHH",
+	"SZ": "SHZ",
+}
+
+// normalizeSymbol upper-cases a ticker and rewrites its exchange suffix the
+// way AlphaVantage spells it.
+func normalizeSymbol(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	i := strings.LastIndex(symbol, ".")
+	if i < 0 {
+		return symbol
+	}
+	if suffix, ok := symbolSuffixes[symbol[i+1:]]; ok {
+		return symbol[:i+1] + suffix
+	}
+	return symbol
+}
+
+// symbolExchange returns the exchange a symbol trades on. A suffix that
+// isn't an exchange's, like the class in BRK.B, means New York.
+func symbolExchange(symbol string) exchange {
+	symbol = normalizeSymbol(symbol)
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		if e, ok := exchanges[symbol[i+1:]]; ok {
+			return e
+		}
+	}
+	return exchanges[""]
+}
+
+// isOpen reports whether the exchange is trading at t, going by its hours
+// and weekends (not its holidays).
+func (e exchange) isOpen(t time.Time) bool {
+	loc, err := time.LoadLocation(e.Location)
+	if err != nil {
+		return true
+	}
+	local := t.In(loc)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return false
+	}
+	clock := local.Format("15:04")
+	return clock >= e.Opens && clock < e.Closes
+}
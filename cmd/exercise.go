@@ -0,0 +1,410 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var election83b bool
+var exerciseShares float64
+
+// exerciseCmd represents the exercise command
+var exerciseCmd = &cobra.Command{
+	Use:   "exercise",
+	Short: "Show the cash needed to exercise your vested options.",
+	Long: `Show how much cash it would take to exercise your vested options today,
+the strike price times the shares, and the paper gain on the shares you'd
+then hold. With --shares, exercise only that many, taking them from your
+option grants in the order they're configured.
+
+The break-even price is the lowest at which exercising and selling the same
+day would leave you anything after the fees set under fees in the config and
+the estimated tax on the spread.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		v, err := loadValuation(time.Now())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fees, err := loadFees()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		lots := exerciseLots(v.Grants, exerciseShares)
+		if viper.GetString("output") == "json" {
+			err = writeExerciseJSON(v, fees, lots)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(lots) == 0 {
+			fmt.Println("You have no vested options to exercise.")
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Grant\tOptions\tStrike\tCost\tShare value\tPaper gain\tBreak-even\t")
+		var shares, cost, value float64
+		for _, l := range lots {
+			shares += l.Shares
+			cost += l.Shares * l.StrikePrice
+			value += l.Shares * v.Price
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", l.Grant, formatShares(l.Shares), ac.FormatMoney(l.StrikePrice),
+				ac.FormatMoney(l.Shares*l.StrikePrice), ac.FormatMoney(l.Shares*v.Price), ac.FormatMoney(l.Shares*(v.Price-l.StrikePrice)),
+				ac.FormatMoney(lotBreakEven(v, fees, l)))
+		}
+		if len(lots) > 1 {
+			fmt.Fprintf(w, "Total\t%s\t\t%s\t%s\t%s\t\t\n", formatShares(shares), ac.FormatMoney(cost), ac.FormatMoney(value), ac.FormatMoney(value-cost))
+		}
+		w.Flush()
+		if v.Tax.Set && value > cost {
+			tax := v.Tax.owed(optionIncome(v, lots))
+			fmt.Printf("Exercising and selling the same day would leave %s after an estimated %s in taxes on the spread.\n",
+				ac.FormatMoney(value-cost-tax), ac.FormatMoney(tax))
+		}
+		if exerciseShares > shares {
+			fmt.Printf("You only have %s vested options, so that's all of them.\n", formatShares(shares))
+		}
+	},
+}
+
+// earlyExerciseCmd represents the early-exercise command
+var earlyExerciseCmd = &cobra.Command{
+	Use:   "early-exercise",
+	Short: "Model exercising your options before they vest.",
+	Long: `For option grants whose plan allows early exercise, show what exercising
+every remaining option today would cost, how many of the shares would be
+subject to repurchase at the strike price if you left, and how that
+shrinks on each upcoming vest date.
+
+With --83b, compare the income recognized with an 83(b) election (all of it
+at exercise, at the fair market value then) against without one (as each
+vest lands, estimated at today's price). Set exercise-fmv on a grant when
+its fair market value differs from the current price.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		var grants []grantValuation
+		for _, g := range v.Grants {
+			if g.EarlyExercise {
+				grants = append(grants, g)
+			}
+		}
+		if viper.GetString("output") == "json" {
+			err = writeEarlyExerciseJSON(v, grants, now, election83b)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(grants) == 0 {
+			fmt.Println("None of your grants allow early exercise; set early-exercise: true on those that do.")
+			return
+		}
+
+		ac := v.money()
+		for i, g := range grants {
+			if i > 0 {
+				fmt.Println()
+			}
+			options := g.SharesVestedUnsold + g.SharesUnvested
+			fmt.Printf("%s: exercising all %s options today would cost %s, with a spread of %s over the strike price.\n",
+				g.Name, formatShares(options), ac.FormatMoney(options*g.StrikePrice), ac.FormatMoney(options*(v.Price-g.StrikePrice)))
+			if g.SharesUnvested == 0 {
+				fmt.Println("They're all vested, so none would be subject to repurchase.")
+				continue
+			}
+			fmt.Printf("%s of the shares are unvested and would be subject to repurchase at %s (%s) if you left.\n",
+				formatShares(g.SharesUnvested), ac.FormatMoney(g.StrikePrice), ac.FormatMoney(g.SharesUnvested*g.StrikePrice))
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(w, "Date\tVested\tRepurchasable\tRepurchase amount\t")
+			for _, date := range g.vestDates() {
+				if !date.After(now) {
+					continue
+				}
+				vested := g.vestedShares(date)
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", date.Format("Jan 2, 2006"), formatShares(vested),
+					formatShares(g.Shares-vested), ac.FormatMoney((g.Shares-vested)*g.StrikePrice))
+			}
+			w.Flush()
+
+			if election83b {
+				fmt.Println()
+				print83b(v, g, now)
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exerciseCmd)
+	rootCmd.AddCommand(earlyExerciseCmd)
+
+	exerciseCmd.Flags().Float64Var(&exerciseShares, "shares", 0, "exercise only this many vested options (default all)")
+
+	earlyExerciseCmd.Flags().BoolVar(&election83b, "83b", false, "compare the income recognized with and without an 83(b) election")
+}
+
+// exerciseLots picks the vested options to exercise: all of them, or the
+// first shares of them with grants taken in config order.
+func exerciseLots(grants []grantValuation, shares float64) []vestEvent {
+	var lots []vestEvent
+	for _, g := range grants {
+		if !g.isOption() || g.SharesVestedUnsold <= 0 {
+			continue
+		}
+		n := g.SharesVestedUnsold
+		if shares > 0 {
+			remaining := shares
+			for _, l := range lots {
+				remaining -= l.Shares
+			}
+			if remaining <= 0 {
+				break
+			}
+			n = math.Min(n, remaining)
+		}
+		lots = append(lots, vestEvent{Shares: n, Grant: g.Name, StrikePrice: g.StrikePrice})
+	}
+	return lots
+}
+
+// lotBreakEven is the break-even price for exercising and selling a lot.
+func lotBreakEven(v valuation, fees saleFees, l vestEvent) float64 {
+	for _, g := range v.Grants {
+		if g.Name == l.Grant {
+			return breakEven(v, fees, g.grant, l.Shares)
+		}
+	}
+	return 0
+}
+
+// optionIncome is the income taxed on exercising lots, under the configured
+// country's treatment of each grant's spread.
+func optionIncome(v valuation, lots []vestEvent) float64 {
+	income := 0.0
+	for _, l := range lots {
+		for _, g := range v.Grants {
+			if g.Name == l.Grant {
+				income += v.Tax.optionIncome(g.grant, l.Shares*math.Max(v.Price-l.StrikePrice, 0))
+			}
+		}
+	}
+	return income
+}
+
+func writeExerciseJSON(v valuation, fees saleFees, lots []vestEvent) error {
+	type jsonLot struct {
+		Grant       string  `json:"grant"`
+		Options     float64 `json:"options"`
+		StrikePrice float64 `json:"strike_price"`
+		Cost        float64 `json:"cost"`
+		ShareValue  float64 `json:"share_value"`
+		PaperGain   float64 `json:"paper_gain"`
+		BreakEven   float64 `json:"break_even"`
+	}
+	out := struct {
+		SchemaVersion int       `json:"schema_version"`
+		Ticker        string    `json:"ticker"`
+		Price         float64   `json:"price"`
+		Options       float64   `json:"options"`
+		Cost          float64   `json:"cost"`
+		ShareValue    float64   `json:"share_value"`
+		PaperGain     float64   `json:"paper_gain"`
+		Tax           *float64  `json:"tax,omitempty"`
+		AfterTax      *float64  `json:"after_tax,omitempty"`
+		Grants        []jsonLot `json:"grants"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Grants: []jsonLot{}}
+	for _, l := range lots {
+		jl := jsonLot{
+			Grant:       l.Grant,
+			Options:     l.Shares,
+			StrikePrice: l.StrikePrice,
+			Cost:        l.Shares * l.StrikePrice,
+			ShareValue:  l.Shares * v.Price,
+			PaperGain:   l.Shares * (v.Price - l.StrikePrice),
+			BreakEven:   lotBreakEven(v, fees, l),
+		}
+		out.Options += jl.Options
+		out.Cost += jl.Cost
+		out.ShareValue += jl.ShareValue
+		out.PaperGain += jl.PaperGain
+		out.Grants = append(out.Grants, jl)
+	}
+	if v.Tax.Set {
+		tax := v.Tax.owed(optionIncome(v, lots))
+		afterTax := out.PaperGain - tax
+		out.Tax, out.AfterTax = &tax, &afterTax
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// scenario83b is the income recognized on exercising a grant early, with
+// and without an 83(b) election.
+type scenario83b struct {
+	FMV float64
+	// With the election, all of the spread at exercise is income at once.
+	Election float64
+	// Without it, only the vested shares' spread is income at exercise, and
+	// each later vest's spread is income when it lands.
+	Now   float64
+	Vests []vestEvent
+	Later float64
+}
+
+func newScenario83b(g grantValuation, price float64, now time.Time) scenario83b {
+	s := scenario83b{FMV: g.ExerciseFMV}
+	if s.FMV == 0 {
+		s.FMV = price
+	}
+	spread := math.Max(s.FMV-g.StrikePrice, 0)
+	s.Election = (g.SharesVestedUnsold + g.SharesUnvested) * spread
+	s.Now = g.SharesVestedUnsold * spread
+	s.Vests = g.upcomingVests(now)
+	for _, e := range s.Vests {
+		s.Later += e.Shares * math.Max(price-g.StrikePrice, 0)
+	}
+	return s
+}
+
+func print83b(v valuation, g grantValuation, now time.Time) {
+	ac := v.money()
+	price := v.Price
+	s := newScenario83b(g, price, now)
+
+	fmt.Printf("With an 83(b) election you'd recognize %s of income now, at a fair market value of %s, and none as the shares vest.\n",
+		ac.FormatMoney(s.Election), ac.FormatMoney(s.FMV))
+	fmt.Printf("Without one you'd recognize %s now on the vested shares, then about %s more as the rest vest, at today's price:\n",
+		ac.FormatMoney(s.Now), ac.FormatMoney(s.Later))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Date\tShares\tIncome\t")
+	for _, e := range s.Vests {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", e.Date.Format("Jan 2, 2006"), formatShares(e.Shares),
+			ac.FormatMoney(e.Shares*math.Max(price-g.StrikePrice, 0)))
+	}
+	w.Flush()
+	fmt.Println("The income is ordinary income for NSOs, and an AMT adjustment for ISOs.")
+}
+
+// jsonRepurchase is how many exercised shares remain subject to repurchase
+// after a vest date.
+type jsonRepurchase struct {
+	Date          time.Time `json:"date"`
+	SharesVested  float64   `json:"shares_vested"`
+	Repurchasable float64   `json:"shares_repurchasable"`
+}
+
+// json83b compares the income recognized with and without an 83(b)
+// election.
+type json83b struct {
+	FMV            float64      `json:"fmv"`
+	ElectionIncome float64      `json:"election_income"`
+	IncomeNow      float64      `json:"income_now"`
+	IncomeLater    float64      `json:"income_later"`
+	Vests          []jsonIncome `json:"vests"`
+}
+
+// jsonIncome is the income recognized on a vest without an 83(b) election.
+type jsonIncome struct {
+	Date   time.Time `json:"date"`
+	Shares float64   `json:"shares"`
+	Income float64   `json:"income"`
+}
+
+func writeEarlyExerciseJSON(v valuation, grants []grantValuation, now time.Time, with83b bool) error {
+	type jsonEarlyExercise struct {
+		Name          string           `json:"name"`
+		Options       float64          `json:"options"`
+		StrikePrice   float64          `json:"strike_price"`
+		ExerciseCost  float64          `json:"exercise_cost"`
+		Spread        float64          `json:"spread"`
+		Repurchasable float64          `json:"shares_repurchasable"`
+		Schedule      []jsonRepurchase `json:"schedule"`
+		Election83b   *json83b         `json:"election_83b,omitempty"`
+	}
+	out := struct {
+		SchemaVersion int                 `json:"schema_version"`
+		Ticker        string              `json:"ticker"`
+		Price         float64             `json:"price"`
+		Grants        []jsonEarlyExercise `json:"grants"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Grants: []jsonEarlyExercise{}}
+	for _, g := range grants {
+		options := g.SharesVestedUnsold + g.SharesUnvested
+		je := jsonEarlyExercise{
+			Name:          g.Name,
+			Options:       options,
+			StrikePrice:   g.StrikePrice,
+			ExerciseCost:  options * g.StrikePrice,
+			Spread:        options * (v.Price - g.StrikePrice),
+			Repurchasable: g.SharesUnvested,
+			Schedule:      []jsonRepurchase{},
+		}
+		for _, date := range g.vestDates() {
+			if date.After(now) {
+				vested := g.vestedShares(date)
+				je.Schedule = append(je.Schedule, jsonRepurchase{Date: date, SharesVested: vested, Repurchasable: g.Shares - vested})
+			}
+		}
+		if with83b {
+			s := newScenario83b(g, v.Price, now)
+			je.Election83b = &json83b{
+				FMV:            s.FMV,
+				ElectionIncome: s.Election,
+				IncomeNow:      s.Now,
+				IncomeLater:    s.Later,
+				Vests:          []jsonIncome{},
+			}
+			for _, e := range s.Vests {
+				je.Election83b.Vests = append(je.Election83b.Vests, jsonIncome{
+					Date:   e.Date,
+					Shares: e.Shares,
+					Income: e.Shares * math.Max(v.Price-g.StrikePrice, 0),
+				})
+			}
+		}
+		out.Grants = append(out.Grants, je)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
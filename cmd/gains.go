@@ -0,0 +1,182 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var gainsYear int
+
+// gainsCmd represents the gains command
+var gainsCmd = &cobra.Command{
+	Use:   "gains",
+	Short: "Summarize your realized and unrealized capital gains.",
+	Long: `Summarize the capital gains realized by the sales in your ledger during a
+year (this year, or --year), lot by lot and split into short-term and
+long-term, as a preview of Schedule D, along with the unrealized gains on
+the lots you still hold. With tax settings, the tax on the realized gains
+is estimated too.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		held, realized, err := loadLots(v, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		year := gainsYear
+		if year == 0 {
+			year = now.Year()
+		}
+		g := newGainsReport(v, held, realized, year, now)
+
+		if viper.GetString("output") == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(g.json(v))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		ac := v.money()
+		if len(g.Realized) == 0 {
+			fmt.Printf("No sales recorded in %d.\n", year)
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+			fmt.Fprintln(w, "Lot\tAcquired\tSold\tShares\tProceeds\tCost basis\tGain\tTerm\t")
+			for _, r := range g.Realized {
+				id, basis, gain := r.ID, ac.FormatMoney(r.Shares*r.Basis), ac.FormatMoney(r.realizedGain())
+				if id == "" {
+					id, basis, gain = "unmatched", "?", "?"
+				}
+				term := "short"
+				if r.longTerm(r.Sold) {
+					term = "long"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", id, r.Acquired.Format("Jan 2, 2006"), r.Sold.Format("Jan 2, 2006"),
+					formatShares(r.Shares), ac.FormatMoney(r.proceeds()), basis, gain, term)
+			}
+			w.Flush()
+			fmt.Println()
+			fmt.Printf("Realized in %d: %s short-term and %s long-term.\n", year, ac.FormatMoney(g.RealizedShort), ac.FormatMoney(g.RealizedLong))
+			if g.Unmatched > 0 {
+				fmt.Printf("%s shares sold couldn't be matched to a lot, so their gain isn't counted; list option exercises under exercises.\n",
+					formatShares(g.Unmatched))
+			}
+			if v.Tax.Set {
+				fmt.Printf("Estimated tax on them: %s.\n", ac.FormatMoney(g.Tax))
+			}
+		}
+		fmt.Printf("Unrealized today: %s short-term and %s long-term.\n", ac.FormatMoney(g.UnrealizedShort), ac.FormatMoney(g.UnrealizedLong))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(gainsCmd)
+
+	gainsCmd.Flags().IntVar(&gainsYear, "year", 0, "tax year to report realized gains for (default this year)")
+}
+
+// gainsReport is a year's realized gains and today's unrealized gains.
+type gainsReport struct {
+	Year            int
+	Realized        []realizedLot
+	RealizedShort   float64
+	RealizedLong    float64
+	Unmatched       float64
+	Tax             float64
+	UnrealizedShort float64
+	UnrealizedLong  float64
+}
+
+func newGainsReport(v valuation, held []lot, realized []realizedLot, year int, now time.Time) gainsReport {
+	g := gainsReport{Year: year}
+	for _, r := range realized {
+		if r.Sold.Year() != year {
+			continue
+		}
+		g.Realized = append(g.Realized, r)
+		switch {
+		case r.ID == "":
+			g.Unmatched += r.Shares
+		case r.longTerm(r.Sold):
+			g.RealizedLong += r.realizedGain()
+		default:
+			g.RealizedShort += r.realizedGain()
+		}
+	}
+	if v.Tax.Set {
+		g.Tax = v.Tax.gainsTax(g.RealizedShort, g.RealizedLong)
+	}
+	g.UnrealizedShort, g.UnrealizedLong = termGains(held, v.Price, now)
+	return g
+}
+
+func (g gainsReport) json(v valuation) interface{} {
+	type jsonRealized struct {
+		Lot      string    `json:"lot,omitempty"`
+		Acquired time.Time `json:"acquired"`
+		Sold     time.Time `json:"sold"`
+		Shares   float64   `json:"shares"`
+		Proceeds float64   `json:"proceeds"`
+		Basis    *float64  `json:"cost_basis,omitempty"`
+		Gain     *float64  `json:"gain,omitempty"`
+		LongTerm bool      `json:"long_term"`
+	}
+	out := struct {
+		SchemaVersion   int            `json:"schema_version"`
+		Year            int            `json:"year"`
+		RealizedShort   float64        `json:"realized_short_term"`
+		RealizedLong    float64        `json:"realized_long_term"`
+		UnmatchedShares float64        `json:"unmatched_shares,omitempty"`
+		Tax             *float64       `json:"tax,omitempty"`
+		UnrealizedShort float64        `json:"unrealized_short_term"`
+		UnrealizedLong  float64        `json:"unrealized_long_term"`
+		Sales           []jsonRealized `json:"sales"`
+	}{SchemaVersion: schemaVersion, Year: g.Year, RealizedShort: g.RealizedShort, RealizedLong: g.RealizedLong,
+		UnmatchedShares: g.Unmatched, UnrealizedShort: g.UnrealizedShort, UnrealizedLong: g.UnrealizedLong, Sales: []jsonRealized{}}
+	if v.Tax.Set {
+		out.Tax = &g.Tax
+	}
+	for _, r := range g.Realized {
+		jr := jsonRealized{Lot: r.ID, Acquired: r.Acquired, Sold: r.Sold, Shares: r.Shares, Proceeds: r.proceeds(), LongTerm: r.longTerm(r.Sold)}
+		if r.ID != "" {
+			basis, gain := r.Shares*r.Basis, r.realizedGain()
+			jr.Basis, jr.Gain = &basis, &gain
+		}
+		out.Sales = append(out.Sales, jr)
+	}
+	return out
+}
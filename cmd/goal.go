@@ -0,0 +1,279 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var goalAmount float64
+var goalGrowth string
+var goalBy string
+
+// goalHorizonYears is how far ahead a growing price is followed once everything
+// has vested.
+const goalHorizonYears = 30
+
+// goalCmd represents the goal command
+var goalCmd = &cobra.Command{
+	Use:   "goal",
+	Short: "Find when your vested shares will be worth an amount.",
+	Long: `Find the date the value of your vested, unsold shares first reaches
+--amount, and how many more vest events it takes to get there. The price is
+taken to stay where it is, or with --growth (or the growth setting) to
+compound at that yearly rate.
+
+With --by, turn the question around: find the share price at which the
+shares vested by that date would be worth --amount then.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if goalAmount <= 0 {
+			fmt.Println("goal: --amount is required")
+			os.Exit(1)
+		}
+		rate, err := configPercent(viper.Get("growth"))
+		if err != nil {
+			fmt.Printf("goal: invalid --growth: %s\n", err)
+			os.Exit(1)
+		}
+		now := time.Now()
+		var by time.Time
+		if goalBy != "" {
+			by, err = configDate(goalBy)
+			if err != nil {
+				fmt.Printf("goal: --by: %s\n", err)
+				os.Exit(1)
+			}
+			if !by.After(now) {
+				fmt.Println("goal: --by must be in the future")
+				os.Exit(1)
+			}
+		}
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sales, err := loadLedger()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !by.IsZero() {
+			requiredPrice(v, sales, goalAmount, by, now)
+			return
+		}
+		g := findGoal(v, sales, goalAmount, rate, now)
+
+		if viper.GetString("output") == "json" {
+			err = writeGoalJSON(v, g)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		ac := v.money()
+		switch {
+		case g.Date.IsZero():
+			fmt.Printf("Your vested shares won't reach %s", ac.FormatMoney(g.Amount))
+			if g.Growth > 0 {
+				fmt.Printf(" within %d years at %.1f%% a year.\n", goalHorizonYears, g.Growth*100)
+			} else {
+				fmt.Printf(" unless %s rises; at today's price they'll top out at %s.\n", v.Ticker, ac.FormatMoney(g.Value))
+			}
+		case !g.Date.After(now):
+			fmt.Printf("Your vested shares are already worth %s, past %s.\n", ac.FormatMoney(g.Value), ac.FormatMoney(g.Amount))
+		default:
+			fmt.Printf("Your vested shares reach %s on %s (in %d days), after %d more vest events", ac.FormatMoney(g.Amount),
+				g.Date.Format("Mon Jan 2, 2006"), calendarDays(now, g.Date), g.Vests)
+			if g.Growth != 0 {
+				fmt.Printf(", with %s at %s", v.Ticker, ac.FormatMoney(g.Price))
+			}
+			fmt.Println(".")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(goalCmd)
+
+	goalCmd.Flags().Float64Var(&goalAmount, "amount", 0, "the value your vested shares should reach")
+	goalCmd.Flags().StringVar(&goalGrowth, "growth", "", "compound the price at this yearly rate (e.g. 8%) rather than holding it flat")
+	viper.BindPFlag("growth", goalCmd.Flags().Lookup("growth"))
+	goalCmd.Flags().StringVar(&goalBy, "by", "", "instead, find the price needed to reach --amount by this date")
+}
+
+// goal is when the vested, unsold shares are first worth Amount, with the
+// price compounding at Growth: on Date, after Vests more vest events, at
+// Price. Date is zero when that never happens, and Value is the most they
+// come to.
+type goal struct {
+	Amount float64
+	Growth float64
+	Date   time.Time
+	Vests  int
+	Price  float64
+	Value  float64
+}
+
+// findGoal finds when the vested value reaches amount. Vests add to it in
+// steps and a growing price continuously, so each stretch between vests is
+// searched, to the day, for the crossing.
+func findGoal(v valuation, sales []saleRecord, amount, rate float64, now time.Time) goal {
+	var grants []grant
+	for _, gv := range v.Grants {
+		grants = append(grants, gv.grant)
+	}
+	g := goal{Amount: amount, Growth: rate}
+	worth := func(t time.Time) float64 {
+		return valuate(grants, grownPrice(v.Price, rate, now, t), t, sales).VestedValue
+	}
+	reached := func(t time.Time, vests int) goal {
+		g.Date, g.Vests = t, vests
+		g.Price = grownPrice(v.Price, rate, now, t)
+		g.Value = worth(t)
+		return g
+	}
+	if worth(now) >= amount {
+		return reached(now, 0)
+	}
+
+	var dates []time.Time
+	for _, e := range v.upcomingVests(now) {
+		if len(dates) == 0 || !e.Date.Equal(dates[len(dates)-1]) {
+			dates = append(dates, e.Date)
+		}
+	}
+	vests := len(dates)
+	if rate > 0 {
+		dates = append(dates, now.AddDate(goalHorizonYears, 0, 0))
+	}
+	from := now
+	for i, d := range dates {
+		if worth(d.Add(-time.Nanosecond)) >= amount {
+			// crossed on price growth alone before this vest
+			for d.Sub(from) > 24*time.Hour {
+				mid := from.Add(d.Sub(from) / 2)
+				if worth(mid) >= amount {
+					d = mid
+				} else {
+					from = mid
+				}
+			}
+			return reached(d, i)
+		}
+		if worth(d) >= amount {
+			return reached(d, min(i+1, vests))
+		}
+		from = d
+	}
+	g.Value = worth(from)
+	return g
+}
+
+// requiredPrice prints the price at which the shares vested by the date by
+// would be worth amount.
+func requiredPrice(v valuation, sales []saleRecord, amount float64, by, now time.Time) {
+	var grants []grant
+	for _, gv := range v.Grants {
+		grants = append(grants, gv.grant)
+	}
+	then := valuate(grants, v.Price, by, sales)
+	price, ok := searchPrice(0, func(price float64) bool {
+		return valuate(grants, price, by, sales).VestedValue >= amount
+	})
+	growth := 0.0
+	if ok && v.Price > 0 {
+		growth = math.Pow(price/v.Price, 1/yearsUntil(now, by)) - 1
+	}
+
+	if viper.GetString("output") == "json" {
+		out := struct {
+			SchemaVersion int       `json:"schema_version"`
+			Ticker        string    `json:"ticker"`
+			Price         float64   `json:"price"`
+			Amount        float64   `json:"amount"`
+			By            time.Time `json:"by"`
+			Shares        float64   `json:"shares_vested_unsold"`
+			Reachable     bool      `json:"reachable"`
+			Required      float64   `json:"required_price,omitempty"`
+			Growth        float64   `json:"yearly_growth,omitempty"`
+		}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Amount: amount, By: by,
+			Shares: then.SharesVestedUnsold, Reachable: ok}
+		if ok {
+			out.Required, out.Growth = price, growth*100
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(out)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ac := v.money()
+	when := by.Format("Jan 2, 2006")
+	if !ok {
+		fmt.Printf("No share price would make the %s shares you'll have vested by %s worth %s.\n",
+			formatShares(then.SharesVestedUnsold), when, ac.FormatMoney(amount))
+		return
+	}
+	change := (price - v.Price) / v.Price * 100
+	if change <= 0 {
+		fmt.Printf("By %s your %s vested shares will be worth %s at today's price; %s could fall to %s, down %.1f%%, and still get there.\n",
+			when, formatShares(then.SharesVestedUnsold), ac.FormatMoney(then.VestedValue), v.Ticker, ac.FormatMoney(price), -change)
+		return
+	}
+	fmt.Printf("To be worth %s on %s, your %s vested shares need %s at %s, up %.1f%% from %s (%.1f%% a year).\n",
+		ac.FormatMoney(amount), when, formatShares(then.SharesVestedUnsold), v.Ticker, ac.FormatMoney(price), change,
+		ac.FormatMoney(v.Price), growth*100)
+}
+
+func writeGoalJSON(v valuation, g goal) error {
+	out := struct {
+		SchemaVersion int        `json:"schema_version"`
+		Ticker        string     `json:"ticker"`
+		Price         float64    `json:"price"`
+		Amount        float64    `json:"amount"`
+		Growth        float64    `json:"growth"`
+		Reached       bool       `json:"reached"`
+		Date          *time.Time `json:"date,omitempty"`
+		Vests         int        `json:"vests"`
+		PriceThen     float64    `json:"price_then,omitempty"`
+		Value         float64    `json:"value"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Amount: g.Amount, Growth: g.Growth * 100,
+		Reached: !g.Date.IsZero(), Vests: g.Vests, PriceThen: g.Price, Value: g.Value}
+	if !g.Date.IsZero() {
+		out.Date = &g.Date
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,719 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// grant is a single equity award and its vesting schedule.
+type grant struct {
+	Name        string
+	Ticker      string
+	Type        string
+	Shares      float64
+	StrikePrice float64
+	Start       time.Time
+	End         time.Time
+	Cliff       time.Time
+	Months      int
+	YearWeights []float64
+	Tranches    []tranche
+	Refresher   bool
+	Projected   bool
+	WholeShares bool
+	Leaves      []leave
+	Calendar    *businessCalendar
+	// PTEP is how long vested options can still be exercised after leaving,
+	// as a span such as 90d.
+	PTEP string
+	// Expires is when an option grant's unexercised options lapse.
+	Expires time.Time
+	// GrantDate is when the grant was made, if different from vest-start.
+	GrantDate time.Time
+	// ExerciseFMV is the fair market value per share when exercising early,
+	// if different from the current price.
+	ExerciseFMV float64
+	// EarlyExercise is set when the plan allows exercising unvested options,
+	// leaving the shares subject to repurchase until they vest.
+	EarlyExercise bool
+	// SingleTrigger and DoubleTrigger are the fractions of unvested shares
+	// accelerated on a change of control, and on a change of control
+	// followed by termination.
+	SingleTrigger float64
+	DoubleTrigger float64
+	// For performance stock units, Shares is the target count scaled by the
+	// assumed Multiplier; the payout can range from Threshold to Maximum.
+	TargetShares float64
+	Multiplier   float64
+	Threshold    float64
+	Maximum      float64
+	// For ESPP grants, vest-start to vest-end is the offering period, and
+	// Contribution is bought in shares every PurchasePeriod at Discount off
+	// the price then or, with Lookback, at the start if that was lower.
+	Contribution   float64
+	Discount       float64
+	Lookback       bool
+	PurchasePeriod string
+}
+
+// grantConfig is a grant as written in the config file, either as an entry
+// in the grants list or as the top-level settings.
+type grantConfig struct {
+	Name          string          `mapstructure:"name"`
+	Ticker        string          `mapstructure:"ticker"`
+	Class         string          `mapstructure:"class"`
+	Shares        float64         `mapstructure:"shares"`
+	StrikePrice   float64         `mapstructure:"strike-price"`
+	VestStart     interface{}     `mapstructure:"vest-start"`
+	VestEnd       interface{}     `mapstructure:"vest-end"`
+	Duration      string          `mapstructure:"vest-duration"`
+	Cliff         interface{}     `mapstructure:"cliff"`
+	YearWeights   []float64       `mapstructure:"year-weights"`
+	Tranches      []trancheConfig `mapstructure:"tranches"`
+	Refresher     bool            `mapstructure:"refresher"`
+	Frequency     string          `mapstructure:"vest-frequency"`
+	PTEP          string          `mapstructure:"ptep"`
+	Expiration    interface{}     `mapstructure:"expiration"`
+	EarlyExercise bool            `mapstructure:"early-exercise"`
+	GrantDate     interface{}     `mapstructure:"grant-date"`
+	ExerciseFMV   float64         `mapstructure:"exercise-fmv"`
+	Type          string          `mapstructure:"type"`
+	Multiplier    interface{}     `mapstructure:"multiplier"`
+	Threshold     interface{}     `mapstructure:"threshold"`
+	Maximum       interface{}     `mapstructure:"maximum"`
+	Contribution  float64         `mapstructure:"contribution"`
+	Discount      interface{}     `mapstructure:"discount"`
+	Lookback      bool            `mapstructure:"lookback"`
+	PurchaseEvery string          `mapstructure:"purchase-period"`
+	Acceleration  struct {
+		SingleTrigger interface{} `mapstructure:"single-trigger"`
+		DoubleTrigger interface{} `mapstructure:"double-trigger"`
+	} `mapstructure:"acceleration"`
+}
+
+// trancheConfig is one entry in a tranches list. A tranche either gives its
+// shares outright or a weight, a share of the grant's shares; it vests on
+// date or a span after vest-start, and with every and count is split equally
+// into count vests, every span apart.
+type trancheConfig struct {
+	Date   interface{} `mapstructure:"date"`
+	After  string      `mapstructure:"after"`
+	Shares float64     `mapstructure:"shares"`
+	Weight interface{} `mapstructure:"weight"`
+	Every  string      `mapstructure:"every"`
+	Count  int         `mapstructure:"count"`
+}
+
+// grantDefaults are the global settings every grant shares.
+type grantDefaults struct {
+	Months   int
+	Leaves   []leave
+	Calendar *businessCalendar
+}
+
+// loadGrantDefaults reads the settings shared by all grants.
+func loadGrantDefaults() (grantDefaults, error) {
+	var d grantDefaults
+	err := loadTimezone()
+	if err != nil {
+		return d, err
+	}
+
+	d.Leaves, err = loadLeaves()
+	if err != nil {
+		return d, err
+	}
+
+	d.Calendar, err = loadBusinessCalendar()
+	if err != nil {
+		return d, err
+	}
+
+	frequency := viper.GetString("vest-frequency")
+	var ok bool
+	d.Months, ok = frequencyMonths[frequency]
+	if !ok {
+		return d, fmt.Errorf("invalid vest-frequency %q: expected monthly, quarterly or annual", frequency)
+	}
+	return d, nil
+}
+
+// newGrant builds a grant from its config with the shared settings applied.
+func (d grantDefaults) newGrant(gc grantConfig) (grant, error) {
+	g, err := newGrant(gc, d.Months)
+	if err != nil {
+		return g, err
+	}
+	g.WholeShares = viper.GetBool("whole-shares")
+	g.Leaves = d.Leaves
+	g.Calendar = d.Calendar
+	splits, err := loadSplits(g.Ticker)
+	if err != nil {
+		return g, err
+	}
+	g.applySplits(splits)
+	return g, nil
+}
+
+// loadGrants reads the grants from the config: those of every position when
+// there are positions, the grants list when present, otherwise a single
+// grant described by the top-level settings. Grants are written as they
+// were made and restated in today's shares after any splits.
+func loadGrants() ([]grant, error) {
+	if viper.IsSet("positions") {
+		positions, err := loadPositions()
+		if err != nil {
+			return nil, err
+		}
+		var grants []grant
+		for _, p := range positions {
+			if !p.Holding {
+				grants = append(grants, p.Grants...)
+			}
+		}
+		return grants, nil
+	}
+
+	defaults, err := loadGrantDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	if !viper.IsSet("grants") && viper.GetString("grants-file") == "" {
+		gc := grantConfig{
+			Name:          viper.GetString("ticker"),
+			Shares:        viper.GetFloat64("shares"),
+			StrikePrice:   viper.GetFloat64("strike-price"),
+			VestStart:     viper.Get("vest-start"),
+			VestEnd:       viper.Get("vest-end"),
+			Duration:      viper.GetString("vest-duration"),
+			Cliff:         viper.Get("cliff"),
+			Refresher:     viper.GetBool("refresher"),
+			Type:          viper.GetString("type"),
+			Multiplier:    viper.Get("multiplier"),
+			Threshold:     viper.Get("threshold"),
+			Maximum:       viper.Get("maximum"),
+			Expiration:    viper.Get("expiration"),
+			EarlyExercise: viper.GetBool("early-exercise"),
+			GrantDate:     viper.Get("grant-date"),
+			ExerciseFMV:   viper.GetFloat64("exercise-fmv"),
+		}
+		err = viper.UnmarshalKey("acceleration", &gc.Acceleration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid acceleration: %s", err)
+		}
+		err = viper.UnmarshalKey("year-weights", &gc.YearWeights)
+		if err != nil {
+			return nil, fmt.Errorf("invalid year-weights: %s", err)
+		}
+		err = viper.UnmarshalKey("tranches", &gc.Tranches)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tranches: %s", err)
+		}
+		g, err := defaults.newGrant(gc)
+		if err != nil {
+			return nil, err
+		}
+		return []grant{g}, nil
+	}
+
+	var configs []grantConfig
+	if file := viper.GetString("grants-file"); file != "" {
+		configs, err = readGrantsFile(file)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err = viper.UnmarshalKey("grants", &configs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grants: %s", err)
+		}
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("grants is empty")
+	}
+	var grants []grant
+	for i, gc := range configs {
+		if gc.Name == "" {
+			gc.Name = fmt.Sprintf("grant %d", i+1)
+		}
+		g, err := defaults.newGrant(gc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", gc.Name, err)
+		}
+		grants = append(grants, g)
+	}
+	return grants, nil
+}
+
+// readGrantsFile reads the grants list from a standalone YAML or JSON file,
+// which keeps the equity details apart from the rest of the config.
+func readGrantsFile(path string) ([]grantConfig, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	v := viper.New()
+	if isEncrypted(path) {
+		var data []byte
+		data, err = readFileEncrypted(path)
+		if err != nil {
+			return nil, fmt.Errorf("grants-file: %s", err)
+		}
+		v.SetConfigType(strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(path, encryptedSuffix)), "."))
+		err = v.ReadConfig(bytes.NewReader(data))
+	} else {
+		v.SetConfigFile(path)
+		err = v.ReadInConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("grants-file: %s", err)
+	}
+	var configs []grantConfig
+	err = v.UnmarshalKey("grants", &configs)
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid grants: %s", path, err)
+	}
+	return configs, nil
+}
+
+// grantsFilePath is the file holding the grants list: the grants-file if
+// one is configured, otherwise the config file itself.
+func grantsFilePath() (string, error) {
+	if file := viper.GetString("grants-file"); file != "" {
+		return homedir.Expand(file)
+	}
+	return viper.ConfigFileUsed(), nil
+}
+
+// newGrant validates a configured grant. A grant either lists its tranches
+// explicitly, or vests from vest-start to vest-end (or for vest-duration)
+// shaped by the optional cliff, frequency and year weights; the cliff may be
+// a span after vest-start or an absolute date. A grant's own vest-frequency
+// overrides the global one passed in as months.
+func newGrant(gc grantConfig, months int) (grant, error) {
+	g := grant{Name: gc.Name, Ticker: normalizeSymbol(gc.Ticker), Shares: gc.Shares, StrikePrice: gc.StrikePrice, Refresher: gc.Refresher, Type: gc.Type}
+	if g.Ticker == "" {
+		g.Ticker = normalizeSymbol(viper.GetString("ticker"))
+	}
+
+	err := g.usePerformance(gc)
+	if err != nil {
+		return g, err
+	}
+	if gc.GrantDate != nil && gc.GrantDate != "" {
+		g.GrantDate, err = configDate(gc.GrantDate)
+		if err != nil {
+			return g, fmt.Errorf("grant-date: %s", err)
+		}
+	}
+	g.EarlyExercise = gc.EarlyExercise
+	g.ExerciseFMV = gc.ExerciseFMV
+	if g.EarlyExercise && !g.isOption() {
+		return g, fmt.Errorf("early-exercise only applies to options")
+	}
+	g.PTEP = gc.PTEP
+	if g.PTEP == "" {
+		g.PTEP = viper.GetString("ptep")
+	}
+	if _, _, _, err := parseSpan(g.PTEP); err != nil {
+		return g, fmt.Errorf("ptep: %s", err)
+	}
+	g.SingleTrigger, err = configPercent(gc.Acceleration.SingleTrigger)
+	if err != nil {
+		return g, fmt.Errorf("acceleration single-trigger: %s", err)
+	}
+	g.DoubleTrigger, err = configPercent(gc.Acceleration.DoubleTrigger)
+	if err != nil {
+		return g, fmt.Errorf("acceleration double-trigger: %s", err)
+	}
+
+	if len(gc.Tranches) > 0 {
+		err = g.useTranches(gc)
+		if err != nil {
+			return g, err
+		}
+		g.TargetShares = g.Shares
+		g.Shares = g.TargetShares * g.Multiplier
+		return g, g.useExpiration(gc)
+	}
+
+	hasEnd := gc.VestEnd != nil && gc.VestEnd != ""
+	if gc.VestStart == nil || gc.VestStart == "" || (!hasEnd && gc.Duration == "") {
+		return g, fmt.Errorf("vest-start and either vest-end or vest-duration are required")
+	}
+	if hasEnd && gc.Duration != "" {
+		return g, fmt.Errorf("vest-end and vest-duration can't both be set")
+	}
+	g.Start, err = configDate(gc.VestStart)
+	if err != nil {
+		return g, fmt.Errorf("vest-start: %s", err)
+	}
+	if gc.Duration != "" {
+		years, months, days, err := parseSpan(gc.Duration)
+		if err != nil {
+			return g, fmt.Errorf("vest-duration: %s", err)
+		}
+		g.End = addSpan(g.Start, years, months, days)
+	} else {
+		g.End, err = configDate(gc.VestEnd)
+		if err != nil {
+			return g, fmt.Errorf("vest-end: %s", err)
+		}
+	}
+	if !g.End.After(g.Start) {
+		return g, fmt.Errorf("vest-end must be after vest-start")
+	}
+	g.Months = months
+	if gc.Frequency != "" {
+		var ok bool
+		g.Months, ok = frequencyMonths[gc.Frequency]
+		if !ok {
+			return g, fmt.Errorf("invalid vest-frequency %q: expected monthly, quarterly or annual", gc.Frequency)
+		}
+	}
+
+	g.YearWeights = gc.YearWeights
+	if len(g.YearWeights) > 0 && !addMonths(g.Start, len(g.YearWeights)*12).Equal(g.End) {
+		return g, fmt.Errorf("year-weights has %d entries but vest-end is not %d years after vest-start", len(g.YearWeights), len(g.YearWeights))
+	}
+
+	switch c := gc.Cliff.(type) {
+	case nil:
+	case time.Time:
+		g.Cliff = vestDay(c)
+	case string:
+		if c == "" {
+			break
+		}
+		if years, months, days, err := parseSpan(c); err == nil {
+			g.Cliff = addSpan(g.Start, years, months, days)
+		} else {
+			g.Cliff, err = configDate(c)
+			if err != nil {
+				return g, fmt.Errorf("invalid cliff %q: expected a span (1y, 6m) or a date", c)
+			}
+		}
+	default:
+		return g, fmt.Errorf("invalid cliff %v", c)
+	}
+	return g, g.useExpiration(gc)
+}
+
+// useExpiration works out when an option grant expires: on its configured
+// expiration date, or option-term after the grant date.
+func (g *grant) useExpiration(gc grantConfig) error {
+	if !g.isOption() {
+		return nil
+	}
+	if gc.Expiration != nil && gc.Expiration != "" {
+		var err error
+		g.Expires, err = configDate(gc.Expiration)
+		if err != nil {
+			return fmt.Errorf("expiration: %s", err)
+		}
+		return nil
+	}
+	years, months, days, err := parseSpan(viper.GetString("option-term"))
+	if err != nil {
+		return fmt.Errorf("option-term: %s", err)
+	}
+	g.Expires = addSpan(g.grantDate(), years, months, days)
+	return nil
+}
+
+// usePerformance reads the payout settings of performance stock units. Other
+// grant types always pay out exactly their shares.
+func (g *grant) usePerformance(gc grantConfig) error {
+	g.TargetShares, g.Multiplier = g.Shares, 1
+	switch g.Type {
+	case "", "rsu", "option", "iso", "nso":
+		return nil
+	case "espp":
+		return g.useESPP(gc)
+	case "psu":
+	default:
+		return fmt.Errorf("invalid type %q: expected rsu, option, iso, nso, psu or espp", g.Type)
+	}
+
+	var err error
+	g.Threshold, g.Maximum = 0.5, 2
+	if gc.Threshold != nil {
+		g.Threshold, err = configPercent(gc.Threshold)
+		if err != nil {
+			return fmt.Errorf("threshold: %s", err)
+		}
+	}
+	if gc.Maximum != nil {
+		g.Maximum, err = configPercent(gc.Maximum)
+		if err != nil {
+			return fmt.Errorf("maximum: %s", err)
+		}
+	}
+	if gc.Multiplier != nil {
+		g.Multiplier, err = configPercent(gc.Multiplier)
+		if err != nil {
+			return fmt.Errorf("multiplier: %s", err)
+		}
+	}
+	if g.Multiplier < 0 || g.Multiplier > g.Maximum {
+		return fmt.Errorf("multiplier %.0f%% is outside 0%%-%.0f%%", g.Multiplier*100, g.Maximum*100)
+	}
+	g.Shares = g.TargetShares * g.Multiplier
+	return nil
+}
+
+// isOption reports whether the grant is of stock options, which must be
+// exercised at the strike price. Grants without a type are taken to be
+// options when they have a strike price.
+func (g grant) isOption() bool {
+	switch g.Type {
+	case "option", "iso", "nso":
+		return true
+	case "":
+		return g.StrikePrice > 0
+	}
+	return false
+}
+
+// grantDate is when the grant was made, taken to be vest-start unless
+// configured.
+func (g grant) grantDate() time.Time {
+	if g.GrantDate.IsZero() {
+		return g.Start
+	}
+	return g.GrantDate
+}
+
+// exerciseDeadline is the last day vested options can be exercised after
+// leaving on terminated.
+func (g grant) exerciseDeadline(terminated time.Time) time.Time {
+	years, months, days, _ := parseSpan(g.PTEP)
+	return addSpan(terminated, years, months, days)
+}
+
+// valueAt is the whole grant's value at price if it paid out at multiplier.
+func (g grant) valueAt(price, multiplier float64) float64 {
+	return g.TargetShares * multiplier * (price - g.StrikePrice)
+}
+
+// useTranches derives the grant from an explicit list of tranches. The
+// vesting period follows from the list, and so does the size unless the
+// tranches are weighted, so the settings that would otherwise shape vesting
+// can't be combined with it; vest-frequency, being a global setting, is
+// simply ignored.
+func (g *grant) useTranches(gc grantConfig) error {
+	if gc.Cliff != nil && gc.Cliff != "" {
+		return fmt.Errorf("cliff can't be combined with tranches")
+	}
+	if len(gc.YearWeights) > 0 {
+		return fmt.Errorf("year-weights can't be combined with tranches")
+	}
+
+	var start time.Time
+	if gc.VestStart != nil && gc.VestStart != "" {
+		var err error
+		start, err = configDate(gc.VestStart)
+		if err != nil {
+			return fmt.Errorf("vest-start: %s", err)
+		}
+	}
+
+	weighted := gc.Tranches[0].Weight != nil
+	if weighted && gc.Shares <= 0 {
+		return fmt.Errorf("weighted tranches need the grant's shares")
+	}
+	g.Shares = 0
+	totalWeight := 0.0
+	for i, tc := range gc.Tranches {
+		date, err := tc.date(start)
+		if err != nil {
+			return fmt.Errorf("tranche %d: %s", i+1, err)
+		}
+		if (tc.Weight != nil) != weighted {
+			return fmt.Errorf("tranche %d: tranches must all give either shares or a weight", i+1)
+		}
+		shares := tc.Shares
+		if weighted {
+			if tc.Shares != 0 {
+				return fmt.Errorf("tranche %d: shares and weight can't both be set", i+1)
+			}
+			weight, err := configPercent(tc.Weight)
+			if err != nil || weight <= 0 {
+				return fmt.Errorf("tranche %d: invalid weight %v", i+1, tc.Weight)
+			}
+			totalWeight += weight
+			shares = gc.Shares * weight
+		}
+
+		count := 1
+		years, months, days := 0, 0, 0
+		if tc.Every != "" || tc.Count != 0 {
+			if tc.Every == "" || tc.Count < 1 {
+				return fmt.Errorf("tranche %d: every and count must be set together", i+1)
+			}
+			count = tc.Count
+			years, months, days, err = parseSpan(tc.Every)
+			if err != nil {
+				return fmt.Errorf("tranche %d: every: %s", i+1, err)
+			}
+		}
+		for n := 0; n < count; n++ {
+			g.Tranches = append(g.Tranches, tranche{Date: addSpan(date, years*n, months*n, days*n), Shares: shares / float64(count)})
+		}
+		g.Shares += shares
+	}
+	if weighted && math.Abs(totalWeight-1) > 1e-9 {
+		return fmt.Errorf("tranche weights add up to %g%%, not 100%%", totalWeight*100)
+	}
+	sort.Slice(g.Tranches, func(i, j int) bool { return g.Tranches[i].Date.Before(g.Tranches[j].Date) })
+
+	g.Start = g.Tranches[0].Date
+	if !start.IsZero() {
+		g.Start = start
+	}
+	g.End = g.Tranches[len(g.Tranches)-1].Date
+	return nil
+}
+
+// date returns when the tranche (or its first vest) happens: its date, or
+// its after span past vest-start.
+func (tc trancheConfig) date(start time.Time) (time.Time, error) {
+	if tc.After == "" {
+		return configDate(tc.Date)
+	}
+	if tc.Date != nil && tc.Date != "" {
+		return time.Time{}, fmt.Errorf("date and after can't both be set")
+	}
+	if start.IsZero() {
+		return time.Time{}, fmt.Errorf("after needs vest-start")
+	}
+	years, months, days, err := parseSpan(tc.After)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("after: %s", err)
+	}
+	return addSpan(start, years, months, days), nil
+}
+
+// shifted returns a copy of the grant with its whole schedule moved by the
+// given number of years.
+func (g grant) shifted(years int) grant {
+	s := g
+	s.Start = addMonths(g.Start, years*12)
+	s.End = addMonths(g.End, years*12)
+	if !g.Cliff.IsZero() {
+		s.Cliff = addMonths(g.Cliff, years*12)
+	}
+	s.Tranches = nil
+	for _, tr := range g.Tranches {
+		s.Tranches = append(s.Tranches, tranche{Date: addMonths(tr.Date, years*12), Shares: tr.Shares})
+	}
+	return s
+}
+
+// projectRefreshers returns the refresher grants expected over the next
+// years: each grant marked as a refresher is assumed to be granted again,
+// with the same size and schedule, on every anniversary of its vest-start.
+// These are projections, not grants that exist yet.
+func projectRefreshers(grants []grant, now time.Time, years int) []grant {
+	var projected []grant
+	horizon := now.AddDate(years, 0, 0)
+	for _, g := range grants {
+		if !g.Refresher {
+			continue
+		}
+		for k := 1; !addMonths(g.Start, k*12).After(horizon); k++ {
+			p := g.shifted(k)
+			if !p.Start.After(now) {
+				continue
+			}
+			p.Name = fmt.Sprintf("%s (projected %d)", g.Name, p.Start.Year())
+			p.Refresher, p.Projected = false, true
+			projected = append(projected, p)
+		}
+	}
+	return projected
+}
+
+// loadLeaves reads the leaves of absence, which pause vesting on every
+// grant, sorted by start date.
+func loadLeaves() ([]leave, error) {
+	var raw []struct {
+		Start interface{} `mapstructure:"start"`
+		End   interface{} `mapstructure:"end"`
+	}
+	err := viper.UnmarshalKey("leaves", &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid leaves: %s", err)
+	}
+
+	var leaves []leave
+	for i, r := range raw {
+		start, err := configDate(r.Start)
+		if err != nil {
+			return nil, fmt.Errorf("leave %d start: %s", i+1, err)
+		}
+		end, err := configDate(r.End)
+		if err != nil {
+			return nil, fmt.Errorf("leave %d end: %s", i+1, err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("leave %d ends before it starts", i+1)
+		}
+		leaves = append(leaves, leave{Start: start, End: end})
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].Start.Before(leaves[j].Start) })
+	return leaves, nil
+}
+
+// configPercent converts a percentage from the config, written either as a
+// number (50) or a string ("50%"), into a fraction. A missing value is zero.
+func configPercent(v interface{}) (float64, error) {
+	switch p := v.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return float64(p) / 100, nil
+	case float64:
+		return p / 100, nil
+	case string:
+		if p == "" {
+			return 0, nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(p, "%")), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", p)
+		}
+		return f / 100, nil
+	}
+	return 0, fmt.Errorf("invalid percentage %v", v)
+}
@@ -0,0 +1,312 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// grantsCmd represents the grants command
+var grantsCmd = &cobra.Command{
+	Use:   "grants",
+	Short: "List, add and remove grants.",
+	Long: `Manage the grants list without hand-editing YAML. Changes are written
+back to the config file (or the grants-file, if set), keeping its comments,
+with the previous version saved alongside as a .bak file.`,
+}
+
+var grantsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List your grants with their IDs.",
+	Run: func(cmd *cobra.Command, args []string) {
+		grants, err := loadGrants()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		ac := configuredMoney()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tName\tType\tShares\tStrike\tVesting\t")
+		for i, g := range grants {
+			kind := g.Type
+			if kind == "" {
+				kind = "rsu"
+				if g.isOption() {
+					kind = "option"
+				}
+			}
+			if g.Refresher {
+				kind += ", refresher"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s - %s\t\n", i+1, g.Name, kind, formatShares(g.Shares),
+				ac.FormatMoney(g.StrikePrice), g.Start.Format("Jan 2, 2006"), g.vestEnd().Format("Jan 2, 2006"))
+		}
+		w.Flush()
+	},
+}
+
+var grantsAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a grant, prompting for its details.",
+	Run: func(cmd *cobra.Command, args []string) {
+		path, doc, list := openGrantsList()
+
+		entry, err := promptGrant(bufio.NewReader(os.Stdin), os.Stdout)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, existing := range list.Content {
+			if grantName(existing) == entry.Name {
+				fmt.Printf("there's already a grant named %q\n", entry.Name)
+				os.Exit(1)
+			}
+		}
+
+		var node yaml.Node
+		err = node.Encode(entry)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		list.Content = append(list.Content, &node)
+		err = writeConfigNode(path, doc)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added %s to %s.\n", entry.Name, path)
+	},
+}
+
+var grantsRemoveCmd = &cobra.Command{
+	Use:   "remove ID|NAME",
+	Short: "Remove a grant, by the ID shown by grants list or by name.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, doc, list := openGrantsList()
+
+		index := -1
+		if id, err := strconv.Atoi(args[0]); err == nil && id >= 1 && id <= len(list.Content) {
+			index = id - 1
+		} else {
+			for i, existing := range list.Content {
+				if grantName(existing) == args[0] {
+					index = i
+				}
+			}
+		}
+		if index < 0 {
+			fmt.Printf("no grant %q; see worth grants list\n", args[0])
+			os.Exit(1)
+		}
+
+		name := grantName(list.Content[index])
+		if name == "" {
+			name = fmt.Sprintf("grant %d", index+1)
+		}
+		list.Content = append(list.Content[:index], list.Content[index+1:]...)
+		err := writeConfigNode(path, doc)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %s from %s.\n", name, path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(grantsCmd)
+	grantsCmd.AddCommand(grantsListCmd)
+	grantsCmd.AddCommand(grantsAddCmd)
+	grantsCmd.AddCommand(grantsRemoveCmd)
+}
+
+// openGrantsList reads the file holding the grants list, exiting if the
+// config describes its only grant with top-level settings, which a grants
+// list would silently override.
+func openGrantsList() (string, *yaml.Node, *yaml.Node) {
+	if viper.IsSet("positions") {
+		fmt.Println("your config lists its grants under positions; edit them there")
+		os.Exit(1)
+	}
+	if viper.GetString("grants-file") == "" && !viper.IsSet("grants") && (viper.IsSet("shares") || viper.IsSet("tranches")) {
+		fmt.Println("your config describes its grant with top-level settings; move them into a grants list first")
+		os.Exit(1)
+	}
+	path, err := grantsFilePath()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	doc, err := readConfigNode(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	list, err := configGrants(doc)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return path, doc, list
+}
+
+// grantEntry is a grant as written to the grants list by grants add.
+type grantEntry struct {
+	Name        string  `yaml:"name"`
+	Type        string  `yaml:"type,omitempty"`
+	Refresher   bool    `yaml:"refresher,omitempty"`
+	Shares      float64 `yaml:"shares"`
+	StrikePrice float64 `yaml:"strike-price,omitempty"`
+	VestStart   string  `yaml:"vest-start"`
+	VestEnd     string  `yaml:"vest-end,omitempty"`
+	Duration    string  `yaml:"vest-duration,omitempty"`
+	Cliff       string  `yaml:"cliff,omitempty"`
+	Frequency   string  `yaml:"vest-frequency,omitempty"`
+}
+
+// promptGrant asks for a new grant's details, re-asking for any answer that
+// doesn't parse, and checks the result is a valid grant.
+func promptGrant(r *bufio.Reader, w io.Writer) (grantEntry, error) {
+	ask := func(question, def string, valid func(string) error) (string, error) {
+		for {
+			if def != "" {
+				fmt.Fprintf(w, "%s [%s]: ", question, def)
+			} else {
+				fmt.Fprintf(w, "%s: ", question)
+			}
+			line, err := r.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				return "", fmt.Errorf("grants add: %s", err)
+			}
+			answer := strings.TrimSpace(line)
+			if answer == "" {
+				answer = def
+			}
+			if err := valid(answer); err != nil {
+				fmt.Fprintln(w, err)
+				continue
+			}
+			return answer, nil
+		}
+	}
+	required := func(s string) error {
+		if s == "" {
+			return fmt.Errorf("an answer is required")
+		}
+		return nil
+	}
+	number := func(s string) error {
+		_, err := strconv.ParseFloat(s, 64)
+		return err
+	}
+	date := func(s string) error {
+		_, err := parseDate(s)
+		return err
+	}
+	optional := func(string) error { return nil }
+
+	var e grantEntry
+	var err error
+	answers := []struct {
+		question, def string
+		valid         func(string) error
+		set           func(string)
+	}{
+		{"Name", "", required, func(s string) { e.Name = s }},
+		{"Type (rsu, option, iso, nso or psu)", "rsu", func(s string) error {
+			switch s {
+			case "rsu", "option", "iso", "nso", "psu":
+				return nil
+			}
+			return fmt.Errorf("expected rsu, option, iso, nso or psu")
+		}, func(s string) { e.Type = s }},
+		{"Refresher (y/n)", "n", optional, func(s string) { e.Refresher = strings.HasPrefix(strings.ToLower(s), "y") }},
+		{"Shares", "", number, func(s string) { e.Shares, _ = strconv.ParseFloat(s, 64) }},
+		{"Strike price", "0", number, func(s string) { e.StrikePrice, _ = strconv.ParseFloat(s, 64) }},
+		{"Vest start (e.g. 2024-03-01)", "", date, func(s string) { e.VestStart = s }},
+		{"Vest end, or duration (e.g. 4y)", "4y", func(s string) error {
+			if _, _, _, err := parseSpan(s); err == nil {
+				return nil
+			}
+			return date(s)
+		}, func(s string) {
+			if _, _, _, err := parseSpan(s); err == nil {
+				e.Duration = s
+			} else {
+				e.VestEnd = s
+			}
+		}},
+		{"Cliff (a span such as 1y, a date, or none)", "none", optional, func(s string) {
+			if s != "none" {
+				e.Cliff = s
+			}
+		}},
+		{"Vest frequency (monthly, quarterly, annual, or default)", "default", func(s string) error {
+			if _, ok := frequencyMonths[s]; ok || s == "default" {
+				return nil
+			}
+			return fmt.Errorf("expected monthly, quarterly, annual or default")
+		}, func(s string) {
+			if s != "default" {
+				e.Frequency = s
+			}
+		}},
+	}
+	for _, a := range answers {
+		var answer string
+		answer, err = ask(a.question, a.def, a.valid)
+		if err != nil {
+			return e, err
+		}
+		a.set(answer)
+	}
+	if e.Type == "rsu" {
+		e.Type = ""
+	}
+
+	_, err = newGrant(grantConfig{
+		Name:        e.Name,
+		Type:        e.Type,
+		Shares:      e.Shares,
+		StrikePrice: e.StrikePrice,
+		VestStart:   e.VestStart,
+		VestEnd:     e.VestEnd,
+		Duration:    e.Duration,
+		Cliff:       e.Cliff,
+		Frequency:   e.Frequency,
+	}, 0)
+	if err != nil {
+		return e, fmt.Errorf("%s: %s", e.Name, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,378 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leekchan/accounting"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var historySince string
+var historyXLSX string
+var historyCSV string
+var historyPruneDryRun bool
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the snapshots recorded by past runs.",
+	Long: `List the snapshot history, oldest first: when each run was, the share
+price, the value of your vested, unsold shares and how it changed since the
+previous snapshot of the same position (or portfolio). --since leaves out
+those before a date, and --output csv writes the list as CSV.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		since, snapshots := loadHistory()
+		rows := historyRows(snapshots, since)
+
+		var write func(io.Writer, []historyRow) error
+		switch viper.GetString("output") {
+		case "json":
+			write = writeHistoryJSON
+		case "csv":
+			write = writeHistoryCSV
+		case "text", "":
+			if len(rows) == 0 {
+				fmt.Println("No snapshots recorded yet.")
+				return
+			}
+			write = writeHistoryText
+		default:
+			fmt.Printf("history: unknown output %q; expected text, json or csv\n", viper.GetString("output"))
+			os.Exit(1)
+		}
+		err := write(os.Stdout, rows)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+var historyExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the snapshots and vest schedule to a spreadsheet.",
+	Long: `Export the snapshot history and every vest, past and to come, with a
+sheet for each position and one for the portfolio's totals: to an Excel
+workbook with --xlsx FILE, or with --csv DIR to a CSV file per sheet in
+that directory.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if (historyXLSX == "") == (historyCSV == "") {
+			fmt.Println("history export: give one of --xlsx or --csv")
+			os.Exit(1)
+		}
+		since, snapshots := loadHistory()
+		positions, err := loadPositions()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sheets := historySheets(positions, snapshots, since, time.Now())
+
+		if historyXLSX != "" {
+			f, err := os.Create(historyXLSX)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			err = writeXLSX(f, sheets)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Printf("Wrote %d sheets to %s.\n", len(sheets), historyXLSX)
+			return
+		}
+		err = os.MkdirAll(historyCSV, 0700)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, s := range sheets {
+			err = writeSheetCSV(filepath.Join(historyCSV, s.Name+".csv"), s)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Wrote %d CSV files to %s.\n", len(sheets), historyCSV)
+	},
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Thin out old snapshots per the retention policy.",
+	Long: `Thin out the snapshot history per snapshot-retention: by default every
+snapshot from the last 7 days is kept, then the last of each day for a year,
+then the last of each week, for each position and the portfolio. With
+weekly set, only the last of each month is kept beyond it. --dry-run says
+what would go without changing anything.
+
+worth snapshot --at prunes the history this way after each snapshot, and
+worth sync once it merges it, when snapshot-retention is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		kept, dropped, err := pruneHistory(time.Now(), historyPruneDryRun)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		switch {
+		case dropped == 0:
+			fmt.Printf("Nothing to prune; keeping all %d snapshots.\n", kept)
+		case historyPruneDryRun:
+			fmt.Printf("Would remove %d snapshots and keep %d.\n", dropped, kept)
+		default:
+			fmt.Printf("Removed %d snapshots, keeping %d.\n", dropped, kept)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyExportCmd)
+	historyCmd.AddCommand(historyPruneCmd)
+
+	historyCmd.PersistentFlags().StringVar(&historySince, "since", "", "only include snapshots from this date on")
+	historyExportCmd.Flags().StringVar(&historyXLSX, "xlsx", "", "write an Excel workbook to this file")
+	historyExportCmd.Flags().StringVar(&historyCSV, "csv", "", "write a CSV file per sheet to this directory")
+	historyPruneCmd.Flags().BoolVar(&historyPruneDryRun, "dry-run", false, "say what would be removed without removing it")
+}
+
+// loadHistory reads the --since date and the snapshot history.
+func loadHistory() (time.Time, []snapshot) {
+	var since time.Time
+	if historySince != "" {
+		var err error
+		since, err = configDate(historySince)
+		if err != nil {
+			fmt.Printf("history: --since: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	snapshots, err := loadSnapshots()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return since, snapshots
+}
+
+// historySheets lays out a sheet for each position, listing its snapshots
+// from since on, whether taken of it alone or as part of the portfolio,
+// then its vests; and a sheet of the portfolio's totals if there are any.
+// A position split into share classes gets one sheet.
+func historySheets(positions []position, snapshots []snapshot, since, now time.Time) []xlsxSheet {
+	used := map[string]bool{}
+	var sheets []xlsxSheet
+	var names []string
+	byName := map[string][]position{}
+	for _, p := range positions {
+		if byName[p.Name] == nil {
+			names = append(names, p.Name)
+		}
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+
+	var totals [][]interface{}
+	for _, name := range names {
+		group := byName[name]
+		rows := [][]interface{}{{"Snapshots"},
+			{"Time", "Ticker", "Currency", "Price", "Vested shares", "Unvested shares", "Vested value", "Unvested value", "Total value"}}
+		for _, s := range snapshots {
+			if s.Time.Before(since) {
+				continue
+			}
+			for _, p := range append([]snapshot{s}, s.Positions...) {
+				if p.Position == name && p.Ticker == normalizeSymbol(group[0].Ticker) {
+					rows = append(rows, []interface{}{s.Time.Local().Format("2006-01-02 15:04"), p.Ticker, p.Currency, p.Price,
+						p.SharesVested, p.SharesUnvested, p.VestedValue, p.UnvestedValue, p.TotalValue})
+				}
+			}
+		}
+		if !group[0].Holding {
+			rows = append(rows, nil, []interface{}{"Vests"}, []interface{}{"Date", "Grant", "Shares", "Strike price", "Status"})
+			var events []vestEvent
+			for _, p := range group {
+				for _, g := range p.Grants {
+					if g.isESPP() {
+						continue
+					}
+					events = append(events, g.pastVests(now)...)
+					events = append(events, g.upcomingVests(now)...)
+				}
+			}
+			sortVests(events)
+			for _, e := range events {
+				status := "vested"
+				if e.Date.After(now) {
+					status = "upcoming"
+				}
+				rows = append(rows, []interface{}{e.Date.Format("2006-01-02"), e.Grant, e.Shares, e.StrikePrice, status})
+			}
+		}
+		sheets = append(sheets, xlsxSheet{Name: xlsxSheetName(name, used), Rows: rows})
+	}
+	for _, s := range snapshots {
+		if s.Positions != nil && !s.Time.Before(since) {
+			totals = append(totals, []interface{}{s.Time.Local().Format("2006-01-02 15:04"), s.Currency, s.VestedValue, s.UnvestedValue, s.TotalValue})
+		}
+	}
+	if len(totals) > 0 {
+		rows := append([][]interface{}{{"Time", "Currency", "Vested value", "Unvested value", "Total value"}}, totals...)
+		sheets = append(sheets, xlsxSheet{Name: xlsxSheetName("Portfolio", used), Rows: rows})
+	}
+	return sheets
+}
+
+// writeSheetCSV writes a sheet's rows to a CSV file.
+func writeSheetCSV(path string, s xlsxSheet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	for _, row := range s.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			if n, ok := cell.(float64); ok {
+				record[i] = strconv.FormatFloat(n, 'f', -1, 64)
+			} else if cell != nil {
+				record[i] = fmt.Sprint(cell)
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	err = cw.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// historyRow is a snapshot and the change in its vested value since the
+// previous one of the same position; First marks there being none.
+type historyRow struct {
+	snapshot
+	Change float64
+	First  bool
+}
+
+// name is the position a row is for, or "Portfolio".
+func (r historyRow) name() string {
+	switch {
+	case r.Positions != nil:
+		return "Portfolio"
+	case r.Position != "":
+		return r.Position
+	}
+	return r.Ticker
+}
+
+// historyRows pairs each snapshot from since on with the change from the
+// one before it, which may itself be older than since.
+func historyRows(snapshots []snapshot, since time.Time) []historyRow {
+	var rows []historyRow
+	last := map[string]float64{}
+	for _, s := range snapshots {
+		key := s.Ticker + "\x00" + s.Position
+		previous, seen := last[key]
+		last[key] = s.VestedValue
+		if s.Time.Before(since) {
+			continue
+		}
+		rows = append(rows, historyRow{snapshot: s, Change: s.VestedValue - previous, First: !seen})
+	}
+	return rows
+}
+
+func writeHistoryText(w io.Writer, rows []historyRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Date\tPosition\tPrice\tVested value\tChange\t")
+	for _, r := range rows {
+		ac := accounting.Accounting{Symbol: currencySymbol(r.Currency), Precision: 2}
+		price, change := "", ""
+		if r.Price > 0 {
+			price = ac.FormatMoney(r.Price)
+		}
+		if !r.First {
+			change = signedMoney(ac.FormatMoney(r.Change), r.Change)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", r.Time.Local().Format("2006-01-02 15:04"), r.name(), price,
+			ac.FormatMoney(r.VestedValue), change)
+	}
+	return tw.Flush()
+}
+
+func writeHistoryCSV(w io.Writer, rows []historyRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "position", "ticker", "currency", "price", "shares_vested_unsold", "vested_value",
+		"unvested_value", "total_value", "change"})
+	number := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	for _, r := range rows {
+		change := ""
+		if !r.First {
+			change = number(r.Change)
+		}
+		price := ""
+		if r.Price > 0 {
+			price = number(r.Price)
+		}
+		cw.Write([]string{r.Time.Format(time.RFC3339), r.name(), r.Ticker, r.Currency, price,
+			number(r.SharesVested), number(r.VestedValue), number(r.UnvestedValue), number(r.TotalValue), change})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeHistoryJSON(w io.Writer, rows []historyRow) error {
+	type jsonSnapshot struct {
+		snapshot
+		Change *float64 `json:"change,omitempty"`
+	}
+	out := struct {
+		SchemaVersion int            `json:"schema_version"`
+		Snapshots     []jsonSnapshot `json:"snapshots"`
+	}{SchemaVersion: schemaVersion, Snapshots: []jsonSnapshot{}}
+	for _, r := range rows {
+		s := jsonSnapshot{snapshot: r.snapshot}
+		if !r.First {
+			change := r.Change
+			s.Change = &change
+		}
+		out.Snapshots = append(out.Snapshots, s)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
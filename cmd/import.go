@@ -0,0 +1,698 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var importFrom string
+var importWrite bool
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import grants from a Carta, Shareworks or E*TRADE export.",
+	Long: `Read the vesting schedule exported from Carta, Shareworks or E*TRADE as
+CSV and turn each grant in it into an entry in the grants list, with its
+tranches. The grants are printed as YAML to paste into your config, or with
+--write merged into the config file (or the grants-file, if set), replacing
+any grants of the same name.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, ok := importFormats[importFrom]
+		if !ok {
+			fmt.Printf("import: invalid --from %q: expected carta, shareworks or etrade\n", importFrom)
+			os.Exit(1)
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		grants, err := parseExport(f, format)
+		if err != nil {
+			fmt.Printf("%s: %s\n", args[0], err)
+			os.Exit(1)
+		}
+
+		if !importWrite {
+			enc := yaml.NewEncoder(os.Stdout)
+			enc.SetIndent(2)
+			err = enc.Encode(map[string][]importedGrant{"grants": grants})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		path, err := grantsFilePath()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		doc, err := readConfigNode(path)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		list, err := configGrants(doc)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for _, g := range grants {
+			var node yaml.Node
+			err = node.Encode(g)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			replaced := false
+			for i, existing := range list.Content {
+				if grantName(existing) == g.Name {
+					list.Content[i], replaced = &node, true
+				}
+			}
+			if !replaced {
+				list.Content = append(list.Content, &node)
+			}
+		}
+		err = writeConfigNode(path, doc)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d grants into %s.\n", len(grants), path)
+	},
+}
+
+// importTransactionsCmd represents the import transactions command
+var importTransactionsCmd = &cobra.Command{
+	Use:   "transactions FILE",
+	Short: "Import vests, sales and transfers from a brokerage's history.",
+	Long: `Read the transaction history exported from E*TRADE, Shareworks, Schwab or
+Fidelity as CSV and list the vests, sales and transfers of your stock in
+it. With --write, sales (including shares withheld for taxes) are added to
+the ledger, skipping any already there, and the price each vest was taxed
+at becomes its lot's basis under lot-basis in the config. Transfers between
+accounts don't change what you own, so they're only listed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, ok := transactionFormats[importFrom]
+		if !ok {
+			fmt.Printf("import transactions: invalid --from %q: expected etrade, shareworks, schwab or fidelity\n", importFrom)
+			os.Exit(1)
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		transactions, err := parseTransactions(f, format, configuredTicker())
+		if err != nil {
+			fmt.Printf("%s: %s\n", args[0], err)
+			os.Exit(1)
+		}
+		plan, err := planTransactions(transactions, time.Now())
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		formatTransactions(os.Stdout, plan)
+		if !importWrite {
+			if len(plan.Sales) > 0 || len(plan.Bases) > 0 {
+				fmt.Println("Run again with --write to record them.")
+			}
+			return
+		}
+		err = plan.write()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importTransactionsCmd)
+
+	importCmd.Flags().StringVar(&importFrom, "from", "", "format of the export: carta, shareworks or etrade")
+	importCmd.Flags().BoolVar(&importWrite, "write", false, "merge the grants into the config file instead of printing them")
+	importTransactionsCmd.Flags().StringVar(&importFrom, "from", "", "format of the history: etrade, shareworks, schwab or fidelity")
+	importTransactionsCmd.Flags().BoolVar(&importWrite, "write", false, "record the sales in the ledger and the vests' basis in the config")
+}
+
+// importFormat lists, for each field, the column headings a broker's export
+// may use for it, in order of preference.
+type importFormat struct {
+	Grant     []string
+	Type      []string
+	GrantDate []string
+	Strike    []string
+	Date      []string
+	Shares    []string
+}
+
+var importFormats = map[string]importFormat{
+	"carta": {
+		Grant:     []string{"Grant ID", "Security ID", "Grant Name", "Security"},
+		Type:      []string{"Security Type", "Grant Type", "Type"},
+		GrantDate: []string{"Grant Date", "Issue Date", "Board Approval Date"},
+		Strike:    []string{"Exercise Price", "Strike Price"},
+		Date:      []string{"Vest Date", "Vesting Date"},
+		Shares:    []string{"Quantity", "Shares Vesting", "Vesting Quantity", "Shares"},
+	},
+	"shareworks": {
+		Grant:     []string{"Grant Name", "Grant ID", "Award ID", "Award Name"},
+		Type:      []string{"Award Type", "Grant Type", "Plan Type"},
+		GrantDate: []string{"Grant Date", "Award Date"},
+		Strike:    []string{"Grant Price", "Exercise Price", "Option Price"},
+		Date:      []string{"Vest Date", "Vesting Date", "Release Date"},
+		Shares:    []string{"Vesting Quantity", "Quantity", "Shares"},
+	},
+	"etrade": {
+		Grant:     []string{"Grant Number", "Grant Id", "Grant ID"},
+		Type:      []string{"Plan Type", "Award Type", "Grant Type"},
+		GrantDate: []string{"Grant Date"},
+		Strike:    []string{"Exercise Price", "Grant Price"},
+		Date:      []string{"Vest Date", "Vest Period Date", "Vesting Date"},
+		Shares:    []string{"Vested Qty.", "Vesting Qty.", "Vest Qty.", "Qty.", "Quantity"},
+	},
+}
+
+// importedGrant is a grant as written to the config by import.
+type importedGrant struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type,omitempty"`
+	StrikePrice float64           `yaml:"strike-price,omitempty"`
+	GrantDate   string            `yaml:"grant-date,omitempty"`
+	Tranches    []importedTranche `yaml:"tranches"`
+}
+
+type importedTranche struct {
+	Date   string  `yaml:"date"`
+	Shares float64 `yaml:"shares"`
+}
+
+// parseExport reads a CSV export with one row per vest, grouping the rows
+// into grants in the order they first appear. Rows without a grant, such as
+// totals, are skipped.
+func parseExport(r io.Reader, format importFormat) ([]importedGrant, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %s", err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	column := func(names []string) int {
+		for _, name := range names {
+			if i, ok := columns[strings.ToLower(name)]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	grantCol, typeCol, grantDateCol := column(format.Grant), column(format.Type), column(format.GrantDate)
+	strikeCol, dateCol, sharesCol := column(format.Strike), column(format.Date), column(format.Shares)
+	if grantCol < 0 || dateCol < 0 || sharesCol < 0 {
+		return nil, fmt.Errorf("missing a grant, vest date or quantity column; found %s", strings.Join(header, ", "))
+	}
+
+	var grants []importedGrant
+	index := map[string]int{}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		name := field(grantCol)
+		if name == "" {
+			continue
+		}
+
+		date, err := parseExportDate(field(dateCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		shares, err := parseExportNumber(field(sharesCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity: %s", line, err)
+		}
+
+		i, ok := index[name]
+		if !ok {
+			g := importedGrant{Name: name, Type: importedType(field(typeCol))}
+			if s := field(strikeCol); s != "" {
+				g.StrikePrice, err = parseExportNumber(s)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid strike price: %s", line, err)
+				}
+			}
+			if s := field(grantDateCol); s != "" {
+				grantDate, err := parseExportDate(s)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: grant date: %s", line, err)
+				}
+				g.GrantDate = grantDate.Format("2006-01-02")
+			}
+			i = len(grants)
+			index[name] = i
+			grants = append(grants, g)
+		}
+		grants[i].Tranches = append(grants[i].Tranches, importedTranche{Date: date.Format("2006-01-02"), Shares: shares})
+	}
+	if len(grants) == 0 {
+		return nil, fmt.Errorf("no grants found")
+	}
+	for _, g := range grants {
+		sort.SliceStable(g.Tranches, func(i, j int) bool { return g.Tranches[i].Date < g.Tranches[j].Date })
+	}
+	return grants, nil
+}
+
+// transactionFormat lists, for each field of a brokerage's transaction
+// history, the column headings it may use, in order of preference; every
+// fee column found is added up.
+type transactionFormat struct {
+	Date   []string
+	Action []string
+	Symbol []string
+	Grant  []string
+	Shares []string
+	Price  []string
+	Fees   []string
+}
+
+var transactionFormats = map[string]transactionFormat{
+	"etrade": {
+		Date:   []string{"Date", "Transaction Date", "Date Sold"},
+		Action: []string{"Transaction Type", "Record Type", "Type"},
+		Symbol: []string{"Symbol"},
+		Grant:  []string{"Grant Number", "Grant Id", "Grant ID"},
+		Shares: []string{"Qty.", "Quantity", "Shares"},
+		Price:  []string{"Price", "Price Per Share", "Sale Price", "Proceeds Per Share"},
+		Fees:   []string{"Commission", "Fees", "Fee"},
+	},
+	"shareworks": {
+		Date:   []string{"Transaction Date", "Date", "Settlement Date"},
+		Action: []string{"Transaction Type", "Activity", "Type"},
+		Symbol: []string{"Symbol", "Ticker"},
+		Grant:  []string{"Grant Name", "Grant ID", "Award ID", "Award Name"},
+		Shares: []string{"Quantity", "Shares", "Number of Shares"},
+		Price:  []string{"Price", "Share Price", "Market Price", "Sale Price"},
+		Fees:   []string{"Fees", "Commission", "Fees and Commissions"},
+	},
+	"schwab": {
+		Date:   []string{"Date"},
+		Action: []string{"Action", "Type"},
+		Symbol: []string{"Symbol"},
+		Grant:  []string{"Award ID", "Grant ID"},
+		Shares: []string{"Quantity", "Shares"},
+		Price:  []string{"Price", "Sale Price", "FairMarketValuePrice", "Vest FMV"},
+		Fees:   []string{"Fees & Comm", "Fees & Commissions", "Fees"},
+	},
+	"fidelity": {
+		Date:   []string{"Run Date", "Date", "Settlement Date"},
+		Action: []string{"Action", "Description"},
+		Symbol: []string{"Symbol"},
+		Grant:  []string{"Grant ID", "Grant Number"},
+		Shares: []string{"Quantity"},
+		Price:  []string{"Price ($)", "Price"},
+		Fees:   []string{"Commission ($)", "Fees ($)", "Commission", "Fees"},
+	},
+}
+
+// transaction is one vest, sale or transfer from a brokerage's history.
+type transaction struct {
+	Date   time.Time
+	Kind   string
+	Grant  string
+	Shares float64
+	Price  float64
+	Fees   float64
+}
+
+// parseTransactions reads a CSV transaction history, keeping the vests,
+// sales and transfers of ticker (or of any stock, if the history doesn't
+// say which). Rows of other kinds, like dividends and cash, are skipped.
+func parseTransactions(r io.Reader, format transactionFormat, ticker string) ([]transaction, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %s", err)
+	}
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	column := func(names []string) int {
+		for _, name := range names {
+			if i, ok := columns[strings.ToLower(name)]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+	dateCol, actionCol, symbolCol, grantCol := column(format.Date), column(format.Action), column(format.Symbol), column(format.Grant)
+	sharesCol, priceCol := column(format.Shares), column(format.Price)
+	var feeCols []int
+	for _, name := range format.Fees {
+		if i, ok := columns[strings.ToLower(name)]; ok {
+			feeCols = append(feeCols, i)
+		}
+	}
+	if dateCol < 0 || actionCol < 0 || sharesCol < 0 {
+		return nil, fmt.Errorf("missing a date, action or quantity column; found %s", strings.Join(header, ", "))
+	}
+
+	var transactions []transaction
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+		kind := transactionKind(field(actionCol))
+		if kind == "" || field(sharesCol) == "" {
+			continue
+		}
+		if symbol := field(symbolCol); symbol != "" && ticker != "" && normalizeSymbol(symbol) != ticker {
+			continue
+		}
+
+		date, err := parseExportDate(field(dateCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		t := transaction{Date: time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local), Kind: kind, Grant: field(grantCol)}
+		t.Shares, err = parseExportNumber(field(sharesCol))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quantity: %s", line, err)
+		}
+		t.Shares = math.Abs(t.Shares)
+		if s := field(priceCol); s != "" {
+			t.Price, err = parseExportNumber(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid price: %s", line, err)
+			}
+		}
+		for _, i := range feeCols {
+			if s := field(i); s != "" {
+				fee, err := parseExportNumber(s)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid fees: %s", line, err)
+				}
+				t.Fees += math.Abs(fee)
+			}
+		}
+		if kind == "sale" && t.Price <= 0 {
+			return nil, fmt.Errorf("line %d: a sale without a price", line)
+		}
+		transactions = append(transactions, t)
+	}
+	if len(transactions) == 0 {
+		return nil, fmt.Errorf("no vests, sales or transfers found")
+	}
+	sort.SliceStable(transactions, func(i, j int) bool { return transactions[i].Date.Before(transactions[j].Date) })
+	return transactions, nil
+}
+
+// transactionKind maps a brokerage's description of a transaction to vest,
+// sale or transfer, or "" for anything else. Shares withheld to cover taxes
+// count as sold.
+func transactionKind(s string) string {
+	s = strings.ToLower(s)
+	has := func(words ...string) bool {
+		for _, w := range words {
+			if strings.Contains(s, w) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case has("dividend", "interest", "reinvest"):
+		return ""
+	case has("transfer", "journal", "withdraw", "acat"):
+		return "transfer"
+	case has("withh", "to cover", "sale", "sell", "sold"):
+		return "sale"
+	case has("vest", "release", "lapse", "deposit", "distribution"):
+		return "vest"
+	}
+	return ""
+}
+
+// transactionPlan is what importing a transaction history would record:
+// new sales for the ledger and the basis of lots for the config, with a
+// note on what becomes of each transaction.
+type transactionPlan struct {
+	Transactions []transaction
+	Notes        []string
+	Sales        []saleRecord
+	Bases        map[string]float64
+	Lots         []string
+}
+
+// planTransactions works out what to record for each transaction. A vest is
+// matched to the lot acquired that day, of the grant it names if that's one
+// of yours; a sale is skipped if the ledger has it already.
+func planTransactions(transactions []transaction, now time.Time) (transactionPlan, error) {
+	plan := transactionPlan{Transactions: transactions, Bases: map[string]float64{}}
+	positions, err := loadPositions()
+	if err != nil {
+		return plan, err
+	}
+	grants := positions[0].Grants
+	exercises, err := loadExercises(grants)
+	if err != nil {
+		return plan, err
+	}
+	lots := buildLots(grants, exercises, nil, now)
+	known := map[string]bool{}
+	for _, g := range grants {
+		known[g.Name] = true
+	}
+	sales, err := loadLedger()
+	if err != nil {
+		return plan, err
+	}
+	// the transactions are in the first position's stock, whose grants
+	// they're matched to
+	splits, err := loadSplits(positions[0].Ticker)
+	if err != nil {
+		return plan, err
+	}
+
+	for _, t := range transactions {
+		grant := t.Grant
+		if !known[grant] {
+			grant = ""
+		}
+		switch t.Kind {
+		case "sale":
+			r := saleRecord{Date: t.Date, Shares: t.Shares, Price: t.Price, Fees: t.Fees, Grant: grant}
+			factor := splitFactor(splits, r.Date)
+			note := "new sale"
+			for _, s := range append(sales, plan.Sales...) {
+				if s.Date.Equal(r.Date) && math.Abs(s.Shares-r.Shares*factor) < 1e-6 && math.Abs(s.Price-r.Price/factor) < 0.005 {
+					note = "in the ledger already"
+				}
+			}
+			if note == "new sale" {
+				plan.Sales = append(plan.Sales, r)
+			}
+			plan.Notes = append(plan.Notes, note)
+		case "vest":
+			var ids []string
+			for _, l := range lots {
+				if l.Acquired.Format("2006-01-02") == t.Date.Format("2006-01-02") && (grant == "" || l.Grant == grant) {
+					ids = append(ids, l.ID)
+				}
+			}
+			switch {
+			case len(ids) == 0:
+				plan.Notes = append(plan.Notes, "no vest of your grants that day")
+			case len(ids) > 1:
+				plan.Notes = append(plan.Notes, "several of your grants vested that day: "+strings.Join(ids, ", "))
+			case t.Price <= 0:
+				plan.Notes = append(plan.Notes, "lot "+ids[0]+", with no price")
+			default:
+				if _, ok := plan.Bases[ids[0]]; !ok {
+					plan.Lots = append(plan.Lots, ids[0])
+				}
+				plan.Bases[ids[0]] = t.Price
+				plan.Notes = append(plan.Notes, "basis of lot "+ids[0])
+			}
+		case "transfer":
+			plan.Notes = append(plan.Notes, "not recorded")
+		}
+	}
+	return plan, nil
+}
+
+func formatTransactions(w io.Writer, plan transactionPlan) {
+	ac := configuredMoney()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Date\tKind\tShares\tPrice\tFees\t")
+	for i, t := range plan.Transactions {
+		price, fees := "", ""
+		if t.Price > 0 {
+			price = ac.FormatMoney(t.Price)
+		}
+		if t.Fees > 0 {
+			fees = ac.FormatMoney(t.Fees)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Date.Format("2006-01-02"), t.Kind, formatShares(t.Shares), price, fees, plan.Notes[i])
+	}
+	tw.Flush()
+}
+
+// write adds the new sales to the ledger and sets the basis of the vested
+// lots under lot-basis in the config file, replacing any set before.
+func (p transactionPlan) write() error {
+	var path string
+	for _, r := range p.Sales {
+		var err error
+		path, err = appendLedger(r)
+		if err != nil {
+			return err
+		}
+	}
+	if len(p.Sales) > 0 {
+		fmt.Printf("Recorded %d sales in %s.\n", len(p.Sales), path)
+	}
+	if len(p.Lots) == 0 {
+		return nil
+	}
+
+	path = viper.ConfigFileUsed()
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return err
+	}
+	list, err := configList(doc, "lot-basis")
+	if err != nil {
+		return err
+	}
+	for _, id := range p.Lots {
+		var node yaml.Node
+		err = node.Encode(struct {
+			Lot   string  `yaml:"lot"`
+			Basis float64 `yaml:"basis"`
+		}{id, p.Bases[id]})
+		if err != nil {
+			return err
+		}
+		replaced := false
+		for i, existing := range list.Content {
+			if nodeField(existing, "lot") == id {
+				list.Content[i], replaced = &node, true
+			}
+		}
+		if !replaced {
+			list.Content = append(list.Content, &node)
+		}
+	}
+	err = writeConfigNode(path, doc)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Set the basis of %d lots in %s.\n", len(p.Lots), path)
+	return nil
+}
+
+// parseExportDate accepts the US-style dates brokers export as well as the
+// formats accepted in the config.
+func parseExportDate(s string) (time.Time, error) {
+	for _, layout := range []string{"01/02/2006", "1/2/2006", "02-Jan-2006", "2-Jan-2006", "01-02-2006"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return parseDate(s)
+}
+
+// parseExportNumber parses a quantity or price, ignoring currency symbols
+// and thousands separators.
+func parseExportNumber(s string) (float64, error) {
+	return strconv.ParseFloat(strings.NewReplacer("$", "", ",", "", " ", "").Replace(s), 64)
+}
+
+// importedType maps a broker's description of a grant to its type in the
+// config, or "" when it isn't recognised.
+func importedType(s string) string {
+	s = strings.ToLower(s)
+	switch {
+	case strings.Contains(s, "iso") || strings.Contains(s, "incentive"):
+		return "iso"
+	case strings.Contains(s, "nso") || strings.Contains(s, "nqso") || strings.Contains(s, "non-qualified") || strings.Contains(s, "nonqualified"):
+		return "nso"
+	case strings.Contains(s, "psu") || strings.Contains(s, "performance"):
+		return "psu"
+	case strings.Contains(s, "rsu") || strings.Contains(s, "restricted") || s == "rs":
+		return "rsu"
+	case strings.Contains(s, "option"):
+		return "option"
+	}
+	return ""
+}
@@ -0,0 +1,169 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// isoLimit is the most, by fair market value at grant, of incentive stock
+// options that may first become exercisable in a calendar year. Options
+// beyond it are treated as non-qualified.
+const isoLimit = 100000
+
+// isoCmd represents the iso command
+var isoCmd = &cobra.Command{
+	Use:   "iso",
+	Short: "Split your ISO grants into ISOs and NSOs under the $100K rule.",
+	Long: `Apply the $100,000 annual limit on incentive stock options to your ISO
+grants (type: iso). Options first exercisable in a calendar year count
+against the limit at their strike price, earliest grants first; any beyond
+it are non-qualified. Each vest is annotated with its ISO/NSO split.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		grants, err := loadGrants()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		splits, used := isoSplits(grants)
+		if viper.GetString("output") == "json" {
+			err = writeISOJSON(splits, used)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(splits) == 0 {
+			fmt.Println("None of your grants are ISOs; set type: iso on those that are.")
+			return
+		}
+
+		ac := configuredMoney()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Exercisable\tGrant\tShares\tISO\tNSO\t")
+		for _, s := range splits {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", s.Date.Format("Jan 2, 2006"), s.Grant,
+				formatShares(s.Shares), formatShares(s.ISO), formatShares(s.Shares-s.ISO))
+		}
+		w.Flush()
+
+		fmt.Println()
+		for _, year := range sortedYears(used) {
+			fmt.Printf("%d: %s of the %s limit used\n", year, ac.FormatMoney(used[year]), ac.FormatMoney(isoLimit))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(isoCmd)
+}
+
+// isoSplit is the ISO/NSO split of the options becoming exercisable on a
+// date.
+type isoSplit struct {
+	Date   time.Time
+	Grant  string
+	Shares float64
+	ISO    float64
+}
+
+// isoSplits applies the annual limit to every ISO grant's vests, returning
+// each vest's split and the limit used in each year. Grants are counted in
+// the order they were made. With early exercise, a grant's options are all
+// exercisable on the grant date.
+func isoSplits(grants []grant) ([]isoSplit, map[int]float64) {
+	var isos []grant
+	for _, g := range grants {
+		if g.Type == "iso" {
+			isos = append(isos, g)
+		}
+	}
+	sort.SliceStable(isos, func(i, j int) bool { return isos[i].grantDate().Before(isos[j].grantDate()) })
+
+	var splits []isoSplit
+	used := map[int]float64{}
+	for _, g := range isos {
+		// every vest from the start of the grant
+		vests := g.upcomingVests(time.Time{})
+		if g.EarlyExercise {
+			vests = []vestEvent{{Date: g.grantDate(), Shares: g.Shares, Grant: g.Name}}
+		}
+		for _, e := range vests {
+			iso := e.Shares
+			if g.StrikePrice > 0 {
+				room := math.Max(isoLimit-used[e.Date.Year()], 0)
+				iso = math.Min(e.Shares, math.Floor(room/g.StrikePrice))
+			}
+			used[e.Date.Year()] += iso * g.StrikePrice
+			splits = append(splits, isoSplit{Date: e.Date, Grant: g.Name, Shares: e.Shares, ISO: iso})
+		}
+	}
+	sort.SliceStable(splits, func(i, j int) bool { return splits[i].Date.Before(splits[j].Date) })
+	return splits, used
+}
+
+func sortedYears(used map[int]float64) []int {
+	var years []int
+	for year := range used {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	return years
+}
+
+func writeISOJSON(splits []isoSplit, used map[int]float64) error {
+	type jsonSplit struct {
+		Date      time.Time `json:"date"`
+		Grant     string    `json:"grant"`
+		Shares    float64   `json:"shares"`
+		ISOShares float64   `json:"iso_shares"`
+		NSOShares float64   `json:"nso_shares"`
+	}
+	type jsonYear struct {
+		Year      int     `json:"year"`
+		LimitUsed float64 `json:"limit_used"`
+	}
+	out := struct {
+		SchemaVersion int         `json:"schema_version"`
+		Limit         float64     `json:"limit"`
+		Vests         []jsonSplit `json:"vests"`
+		Years         []jsonYear  `json:"years"`
+	}{SchemaVersion: schemaVersion, Limit: isoLimit, Vests: []jsonSplit{}, Years: []jsonYear{}}
+	for _, s := range splits {
+		out.Vests = append(out.Vests, jsonSplit{Date: s.Date, Grant: s.Grant, Shares: s.Shares, ISOShares: s.ISO, NSOShares: s.Shares - s.ISO})
+	}
+	for _, year := range sortedYears(used) {
+		out.Years = append(out.Years, jsonYear{Year: year, LimitUsed: used[year]})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
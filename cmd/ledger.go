@@ -0,0 +1,169 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// saleRecord is an actual sale, recorded in the ledger by sell record. Lot
+// and Grant say where the shares came from, when known.
+type saleRecord struct {
+	Date   time.Time `json:"date"`
+	Shares float64   `json:"shares"`
+	Price  float64   `json:"price"`
+	Fees   float64   `json:"fees,omitempty"`
+	Lot    string    `json:"lot,omitempty"`
+	Grant  string    `json:"grant,omitempty"`
+}
+
+// ledgerPath returns where the sale ledger lives: the ledger setting, or
+// ledger.jsonl beside the config file.
+func ledgerPath() (string, error) {
+	if path := viper.GetString("ledger"); path != "" {
+		return homedir.Expand(path)
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return filepath.Join(filepath.Dir(used), "ledger.jsonl"), nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "worth", "ledger.jsonl"), nil
+}
+
+// loadLedger reads the recorded sales, one JSON object per line, restating
+// those from before a split of the stock sold in today's shares. A missing
+// ledger has no sales.
+func loadLedger() ([]saleRecord, error) {
+	path, err := ledgerPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tickers, err := saleTickers()
+	if err != nil {
+		return nil, err
+	}
+	splits := map[string][]split{}
+
+	var sales []saleRecord
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var r saleRecord
+		err = json.Unmarshal([]byte(line), &r)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+		ticker := r.ticker(tickers)
+		if _, ok := splits[ticker]; !ok {
+			splits[ticker], err = loadSplits(ticker)
+			if err != nil {
+				return nil, err
+			}
+		}
+		factor := splitFactor(splits[ticker], r.Date)
+		r.Shares *= factor
+		r.Price /= factor
+		sales = append(sales, r)
+	}
+	return sales, scanner.Err()
+}
+
+// saleTickers maps each grant to the ticker of its stock, and no grant at
+// all to the configured ticker.
+func saleTickers() (map[string]string, error) {
+	positions, err := loadPositions()
+	if err != nil {
+		return nil, err
+	}
+	tickers := map[string]string{}
+	for _, p := range positions {
+		for _, g := range p.Grants {
+			tickers[g.Name] = g.Ticker
+		}
+	}
+	tickers[""] = configuredTicker()
+	return tickers, nil
+}
+
+// ticker is the ticker of the stock sold: that of the grant the sale was
+// recorded against, or the grant of its lot, or else the configured one.
+func (r saleRecord) ticker(tickers map[string]string) string {
+	grant := r.Grant
+	if grant == "" {
+		if i := strings.LastIndex(r.Lot, "#"); i >= 0 {
+			grant = r.Lot[:i]
+		}
+	}
+	if ticker, ok := tickers[grant]; ok {
+		return ticker
+	}
+	return tickers[""]
+}
+
+// appendLedger adds a sale to the end of the ledger.
+func appendLedger(r saleRecord) (string, error) {
+	path, err := ledgerPath()
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,576 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// lotsCmd represents the lots command
+var lotsCmd = &cobra.Command{
+	Use:   "lots",
+	Short: "List the lots of shares you hold and their holding periods.",
+	Long: `List the lots of shares you hold: one per past vest of your RSU grants,
+acquired on the vest date with that day's closing price as their cost basis,
+and one per option exercise listed under exercises in the config. Shares
+from ISOs have the strike price as their basis; those from other options
+add the spread taxed as income on exercise, for a basis of the fair market
+value then (the exercise's fmv, or else that day's closing price). ESPP
+purchases (see worth espp) are lots too, at the discounted price paid. Set
+lot-basis to use the basis your broker reports instead. Shares sold come out
+of the oldest lots first.
+
+Each lot's unrealized gain is short-term until it has been held for more
+than a year, and long-term from then on; the summary shows how much flips
+to long-term, and when. Shares from ISO exercises and ESPP purchases are
+also flagged with whether selling them today would be a qualifying
+disposition (more than two years after the grant or offering start and a
+year after acquiring them) or not, and until when.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		lots, _, err := loadLots(v, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if viper.GetString("output") == "json" {
+			err = writeLotsJSON(v, lots, now)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if len(lots) == 0 {
+			fmt.Println("You don't hold any shares yet.")
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		disposition := false
+		for _, l := range lots {
+			disposition = disposition || !l.QualifiesOn.IsZero()
+		}
+		header := "Lot\tAcquired\tShares\tBasis\tValue\tGain\tTerm\tLong-term on\t"
+		if disposition {
+			header += "Disposition\t"
+		}
+		fmt.Fprintln(w, header)
+		var short, long, shortShares, longShares float64
+		for _, l := range lots {
+			gain := l.gain(v.Price)
+			term := "short"
+			if l.longTerm(now) {
+				term = "long"
+				long += gain
+				longShares += l.Shares
+			} else {
+				short += gain
+				shortShares += l.Shares
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t", l.ID, l.Acquired.Format("Jan 2, 2006"), formatShares(l.Shares),
+				ac.FormatMoney(l.Basis), ac.FormatMoney(l.Shares*v.Price), ac.FormatMoney(gain), term, l.longTermOn().Format("Jan 2, 2006"))
+			if disposition {
+				switch {
+				case l.QualifiesOn.IsZero():
+					fmt.Fprint(w, "\t")
+				case l.qualifying(now):
+					fmt.Fprint(w, "qualifying\t")
+				default:
+					fmt.Fprintf(w, "disqualifying until %s\t", l.QualifiesOn.Format("Jan 2, 2006"))
+				}
+			}
+			fmt.Fprintln(w)
+		}
+		w.Flush()
+
+		fmt.Println()
+		fmt.Printf("Short-term: %s shares with %s of unrealized gain.\n", formatShares(shortShares), ac.FormatMoney(short))
+		fmt.Printf("Long-term: %s shares with %s of unrealized gain.\n", formatShares(longShares), ac.FormatMoney(long))
+		for _, l := range lots {
+			if !l.longTerm(now) {
+				fmt.Printf("The next lot, %s (%s shares, %s of gain), turns long-term on %s.\n", l.ID, formatShares(l.Shares),
+					ac.FormatMoney(l.gain(v.Price)), l.longTermOn().Format("Jan 2, 2006"))
+				break
+			}
+		}
+		for _, l := range lots {
+			if !l.QualifiesOn.IsZero() && !l.qualifying(now) {
+				fmt.Printf("Selling %s today would be a disqualifying disposition, taxing its spread as income; wait until %s.\n",
+					l.ID, l.QualifiesOn.Format("Jan 2, 2006"))
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lotsCmd)
+}
+
+// lot is a batch of shares acquired together, at a vest or an option
+// exercise. Holding periods and cost basis are tracked per lot.
+type lot struct {
+	ID          string
+	Grant       string
+	Acquired    time.Time
+	Shares      float64
+	Basis       float64
+	QualifiesOn time.Time
+}
+
+// longTermOn is the first day a sale of the lot is long-term, once it has
+// been held for more than a year.
+func (l lot) longTermOn() time.Time {
+	return l.Acquired.AddDate(1, 0, 1)
+}
+
+func (l lot) longTerm(t time.Time) bool {
+	return !t.Before(l.longTermOn())
+}
+
+// qualifying reports whether selling the lot at t would be a qualifying
+// disposition. Only lots with a QualifiesOn date, from ISO exercises, have
+// the distinction.
+func (l lot) qualifying(t time.Time) bool {
+	return !l.QualifiesOn.IsZero() && !t.Before(l.QualifiesOn)
+}
+
+// qualifyingDate is the first day a sale of shares acquired on acquired,
+// under a grant made on granted, is a qualifying disposition: more than two
+// years after the grant and more than a year after acquiring them.
+func qualifyingDate(granted, acquired time.Time) time.Time {
+	byGrant := granted.AddDate(2, 0, 1)
+	byAcquisition := acquired.AddDate(1, 0, 1)
+	if byGrant.After(byAcquisition) {
+		return byGrant
+	}
+	return byAcquisition
+}
+
+// gain is the lot's unrealized gain at price.
+func (l lot) gain(price float64) float64 {
+	return l.Shares * (price - l.Basis)
+}
+
+// exercise is an option exercise listed in the config, with the fair market
+// value of the shares then if known.
+type exercise struct {
+	Grant  string
+	Date   time.Time
+	Shares float64
+	FMV    float64
+}
+
+// loadExercises reads the option exercises, checking each names an option
+// grant, and restates them in today's shares.
+func loadExercises(grants []grant) ([]exercise, error) {
+	var raw []struct {
+		Grant  string      `mapstructure:"grant"`
+		Date   interface{} `mapstructure:"date"`
+		Shares float64     `mapstructure:"shares"`
+		FMV    float64     `mapstructure:"fmv"`
+	}
+	err := viper.UnmarshalKey("exercises", &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exercises: %s", err)
+	}
+
+	var exercises []exercise
+	for i, r := range raw {
+		date, err := configDate(r.Date)
+		if err != nil {
+			return nil, fmt.Errorf("exercise %d: %s", i+1, err)
+		}
+		if r.Shares <= 0 {
+			return nil, fmt.Errorf("exercise %d: shares must be positive", i+1)
+		}
+		ticker, found := "", false
+		for _, g := range grants {
+			if g.Name == r.Grant {
+				if !g.isOption() {
+					return nil, fmt.Errorf("exercise %d: %s isn't an option grant", i+1, r.Grant)
+				}
+				ticker, found = g.Ticker, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("exercise %d: no grant named %q", i+1, r.Grant)
+		}
+		splits, err := loadSplits(ticker)
+		if err != nil {
+			return nil, err
+		}
+		factor := splitFactor(splits, date)
+		exercises = append(exercises, exercise{Grant: r.Grant, Date: date, Shares: r.Shares * factor, FMV: r.FMV / factor})
+	}
+	return exercises, nil
+}
+
+// loadLots works out the lots held now and the parts of lots sold up to
+// now, fetching past closing prices for the basis of vested RSUs.
+func loadLots(v valuation, now time.Time) ([]lot, []realizedLot, error) {
+	var grants []grant
+	for _, g := range v.Grants {
+		grants = append(grants, g.grant)
+	}
+	exercises, err := loadExercises(grants)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var since time.Time
+	for _, g := range grants {
+		if !g.isOption() && g.Start.Before(now) && (since.IsZero() || g.Start.Before(since)) {
+			since = g.Start
+		}
+	}
+	for _, e := range exercises {
+		if e.FMV == 0 && (since.IsZero() || e.Date.Before(since)) {
+			since = e.Date
+		}
+	}
+	prices, err := pricesSince(v.Ticker, since, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	lots := buildLots(grants, exercises, prices, now)
+
+	var overrides []struct {
+		Lot   string  `mapstructure:"lot"`
+		Basis float64 `mapstructure:"basis"`
+	}
+	err = viper.UnmarshalKey("lot-basis", &overrides)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid lot-basis: %s", err)
+	}
+	for _, o := range overrides {
+		found := false
+		for i := range lots {
+			if lots[i].ID == o.Lot {
+				lots[i].Basis = o.Basis
+				found = true
+			}
+		}
+		// lots acquired after now are fine, so long as the grant exists
+		for _, g := range grants {
+			found = found || strings.HasPrefix(o.Lot, g.Name+"#")
+		}
+		if !found {
+			return nil, nil, fmt.Errorf("lot-basis: no lot %q; see worth lots", o.Lot)
+		}
+	}
+
+	sales, err := loadLedger()
+	if err != nil {
+		return nil, nil, err
+	}
+	held, realized := applySales(lots, sales, sharesSold, now)
+	return held, realized, nil
+}
+
+// pricesSince fetches symbol's daily closing prices from a week before since
+// up to now, or none for a zero since.
+func pricesSince(symbol string, since, now time.Time) ([]pricePoint, error) {
+	if since.IsZero() {
+		return nil, nil
+	}
+	return getDailyPrices(symbol, int(now.Sub(since).Hours()/24)+7)
+}
+
+// buildLots returns every lot acquired up to now, oldest first.
+func buildLots(grants []grant, exercises []exercise, prices []pricePoint, now time.Time) []lot {
+	var lots []lot
+	for _, g := range grants {
+		if g.isESPP() {
+			for _, p := range esppPurchases(g, prices, 0, now) {
+				if !p.Estimated {
+					lots = append(lots, lot{Grant: g.Name, Acquired: p.Date, Shares: p.Shares, Basis: p.Price, QualifiesOn: p.QualifiesOn})
+				}
+			}
+			continue
+		}
+		if g.isOption() {
+			continue
+		}
+		for _, e := range g.pastVests(now) {
+			lots = append(lots, lot{Grant: g.Name, Acquired: e.Date, Shares: e.Shares, Basis: closeOn(prices, e.Date)})
+		}
+	}
+	for _, e := range exercises {
+		if e.Date.After(now) {
+			continue
+		}
+		for _, g := range grants {
+			if g.Name == e.Grant {
+				l := lot{Grant: g.Name, Acquired: e.Date, Shares: e.Shares, Basis: g.StrikePrice}
+				if g.Type == "iso" {
+					l.QualifiesOn = qualifyingDate(g.grantDate(), e.Date)
+				} else {
+					fmv := e.FMV
+					if fmv == 0 {
+						fmv = closeOn(prices, e.Date)
+					}
+					l.Basis = math.Max(fmv, g.StrikePrice)
+				}
+				lots = append(lots, l)
+			}
+		}
+	}
+	sort.SliceStable(lots, func(i, j int) bool { return lots[i].Acquired.Before(lots[j].Acquired) })
+
+	count := map[string]int{}
+	for i := range lots {
+		count[lots[i].Grant]++
+		lots[i].ID = fmt.Sprintf("%s#%d", lots[i].Grant, count[lots[i].Grant])
+	}
+	return lots
+}
+
+// realizedLot is the part of a lot sold in one recorded sale, carrying its
+// share of the sale's fees. Shares sold that couldn't be matched to a lot
+// (options exercised and sold without an exercises entry, say) have no ID
+// and no known basis.
+type realizedLot struct {
+	lot
+	Sold  time.Time
+	Price float64
+	Fees  float64
+}
+
+func (r realizedLot) proceeds() float64 {
+	return r.Shares*r.Price - r.Fees
+}
+
+func (r realizedLot) realizedGain() float64 {
+	return r.proceeds() - r.Shares*r.Basis
+}
+
+// applySales returns the lots still held after the ledger's sales up to now
+// and extra shares sold besides, and the parts of lots the sales realized.
+// A sale of a particular lot comes out of that lot; the rest come out of
+// the oldest lots held at the time.
+func applySales(lots []lot, sales []saleRecord, extra float64, now time.Time) ([]lot, []realizedLot) {
+	held := append([]lot(nil), lots...)
+	var realized []realizedLot
+	take := func(i int, shares float64, s *saleRecord) float64 {
+		taken := math.Min(shares, held[i].Shares)
+		if taken > 0 && s != nil {
+			part := held[i]
+			part.Shares = taken
+			realized = append(realized, realizedLot{lot: part, Sold: s.Date, Price: s.Price, Fees: s.Fees * taken / s.Shares})
+		}
+		held[i].Shares -= taken
+		return shares - taken
+	}
+
+	sorted := append([]saleRecord(nil), sales...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	for _, s := range sorted {
+		if s.Date.After(now) || s.Shares <= 0 {
+			continue
+		}
+		s := s
+		left := s.Shares
+		for i := range held {
+			if s.Lot != "" && held[i].ID == s.Lot {
+				left = take(i, left, &s)
+			}
+		}
+		for i := range held {
+			if !held[i].Acquired.After(s.Date) {
+				left = take(i, left, &s)
+			}
+		}
+		if left > 1e-9 {
+			realized = append(realized, realizedLot{
+				lot:  lot{Grant: s.Grant, Acquired: s.Date, Shares: left},
+				Sold: s.Date, Price: s.Price, Fees: s.Fees * left / s.Shares,
+			})
+		}
+	}
+	for i := range held {
+		extra = take(i, extra, nil)
+	}
+
+	var remaining []lot
+	for _, l := range held {
+		if l.Shares > 1e-9 {
+			remaining = append(remaining, l)
+		}
+	}
+	return remaining, realized
+}
+
+// lotMethods are the standard ways of choosing the lots a sale comes from:
+// oldest first, newest first, highest cost basis first, and least tax
+// first.
+var lotMethods = []string{"fifo", "lifo", "hifo", "mintax"}
+
+// selectLots picks the lots to sell shares from, by one of lotMethods or
+// from the comma-separated lot IDs in method, in the order given. The last
+// lot picked may be sold in part. For mintax, the valuation's price and
+// tax settings and the date of the sale decide the order.
+func selectLots(lots []lot, shares float64, method string, v valuation, now time.Time) ([]lot, error) {
+	order := append([]lot(nil), lots...)
+	switch method {
+	case "fifo":
+	case "lifo":
+		sort.SliceStable(order, func(i, j int) bool { return order[i].Acquired.After(order[j].Acquired) })
+	case "hifo":
+		sort.SliceStable(order, func(i, j int) bool { return order[i].Basis > order[j].Basis })
+	case "mintax":
+		// the least tax per share first, losses leading, at the marginal
+		// short-term and long-term rates (or, without tax settings,
+		// assuming long-term gains are taxed at half the rate)
+		short, long := 1.0, 0.5
+		if v.Tax.Set {
+			short, long = v.Tax.gainsTax(1000, 0)/1000, v.Tax.gainsTax(0, 1000)/1000
+		}
+		tax := func(l lot) float64 {
+			if l.longTerm(now) {
+				return (v.Price - l.Basis) * long
+			}
+			return (v.Price - l.Basis) * short
+		}
+		sort.SliceStable(order, func(i, j int) bool { return tax(order[i]) < tax(order[j]) })
+	default:
+		order = nil
+		for _, id := range strings.Split(method, ",") {
+			id = strings.TrimSpace(id)
+			found := false
+			for _, l := range lots {
+				if l.ID == id {
+					order = append(order, l)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("no lot %q; expected fifo, lifo, hifo, mintax or lot IDs from worth lots", id)
+			}
+		}
+	}
+
+	var picked []lot
+	for _, l := range order {
+		if shares <= 0 {
+			break
+		}
+		l.Shares = math.Min(l.Shares, shares)
+		shares -= l.Shares
+		picked = append(picked, l)
+	}
+	return picked, nil
+}
+
+// termGains splits the gain from selling lots at price on t into short-term
+// and long-term.
+func termGains(lots []lot, price float64, t time.Time) (float64, float64) {
+	short, long := 0.0, 0.0
+	for _, l := range lots {
+		if l.longTerm(t) {
+			long += l.gain(price)
+		} else {
+			short += l.gain(price)
+		}
+	}
+	return short, long
+}
+
+// closeOn returns the closing price on the last trading day on or before
+// date, or the earliest price known for dates before them all.
+func closeOn(prices []pricePoint, date time.Time) float64 {
+	if len(prices) == 0 {
+		return 0
+	}
+	i := sort.Search(len(prices), func(i int) bool { return prices[i].Date.After(date) })
+	if i == 0 {
+		return prices[0].Close
+	}
+	return prices[i-1].Close
+}
+
+// jsonLot is one lot in the lots JSON output.
+type jsonLot struct {
+	ID          string     `json:"id"`
+	Grant       string     `json:"grant"`
+	Acquired    time.Time  `json:"acquired"`
+	Shares      float64    `json:"shares"`
+	Basis       float64    `json:"basis"`
+	Value       float64    `json:"value"`
+	Gain        float64    `json:"gain"`
+	LongTerm    bool       `json:"long_term"`
+	LongTermOn  time.Time  `json:"long_term_on"`
+	Qualifying  *bool      `json:"qualifying,omitempty"`
+	QualifiesOn *time.Time `json:"qualifies_on,omitempty"`
+}
+
+func writeLotsJSON(v valuation, lots []lot, now time.Time) error {
+	out := struct {
+		SchemaVersion int       `json:"schema_version"`
+		Ticker        string    `json:"ticker"`
+		Price         float64   `json:"price"`
+		ShortTermGain float64   `json:"short_term_gain"`
+		LongTermGain  float64   `json:"long_term_gain"`
+		Lots          []jsonLot `json:"lots"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Lots: []jsonLot{}}
+	for _, l := range lots {
+		jl := jsonLot{
+			ID:         l.ID,
+			Grant:      l.Grant,
+			Acquired:   l.Acquired,
+			Shares:     l.Shares,
+			Basis:      l.Basis,
+			Value:      l.Shares * v.Price,
+			Gain:       l.gain(v.Price),
+			LongTerm:   l.longTerm(now),
+			LongTermOn: l.longTermOn(),
+		}
+		if !l.QualifiesOn.IsZero() {
+			qualifying, on := l.qualifying(now), l.QualifiesOn
+			jl.Qualifying, jl.QualifiesOn = &qualifying, &on
+		}
+		if jl.LongTerm {
+			out.LongTermGain += jl.Gain
+		} else {
+			out.ShortTermGain += jl.Gain
+		}
+		out.Lots = append(out.Lots, jl)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lotShares lists the ID and shares of each lot, to compare a selection.
+func lotShares(lots []lot) []string {
+	var out []string
+	for _, l := range lots {
+		out = append(out, l.ID+":"+formatShares(l.Shares))
+	}
+	return out
+}
+
+func TestSelectLots(t *testing.T) {
+	now := mustDate("2026-06-01")
+	lots := []lot{
+		{ID: "A", Acquired: mustDate("2024-01-15"), Shares: 100, Basis: 50},
+		{ID: "B", Acquired: mustDate("2025-01-15"), Shares: 100, Basis: 120},
+		{ID: "C", Acquired: mustDate("2026-01-15"), Shares: 100, Basis: 90},
+	}
+	v := valuation{Price: 100}
+
+	tests := []struct {
+		method string
+		shares float64
+		want   []string
+	}{
+		{"fifo", 150, []string{"A:100", "B:50"}},
+		{"lifo", 150, []string{"C:100", "B:50"}},
+		{"hifo", 150, []string{"B:100", "C:50"}},
+		// B is at a loss, and C's small short-term gain costs less than A's
+		// long-term one at half the rate
+		{"mintax", 250, []string{"B:100", "C:100", "A:50"}},
+		{"C, A", 120, []string{"C:100", "A:20"}},
+		{"fifo", 40, []string{"A:40"}},
+		{"fifo", 500, []string{"A:100", "B:100", "C:100"}},
+		{"fifo", 0, nil},
+	}
+	for _, tt := range tests {
+		picked, err := selectLots(lots, tt.shares, tt.method, v, now)
+		if err != nil {
+			t.Errorf("selectLots(%q, %v): %v", tt.method, tt.shares, err)
+			continue
+		}
+		if got := lotShares(picked); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectLots(%q, %v) = %v, want %v", tt.method, tt.shares, got, tt.want)
+		}
+	}
+
+	if _, err := selectLots(lots, 10, "A,Z", v, now); err == nil {
+		t.Error("selectLots with an unknown lot ID succeeded, want an error")
+	}
+	if lots[0].Shares != 100 {
+		t.Errorf("selectLots changed the lots given to it: A has %v shares", lots[0].Shares)
+	}
+}
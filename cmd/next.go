@@ -0,0 +1,118 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// nextCmd represents the next command
+var nextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Show your next vest event.",
+	Long: `Answer the question "when is my next vest, how many shares, and what
+are they worth today?"`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		events := nextVests(v.upcomingVests(now))
+
+		if viper.GetString("output") == "json" {
+			err = writeNextJSON(v, events)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(events) == 0 {
+			fmt.Println("You are 100% vested; there is nothing left to vest.")
+			return
+		}
+		ac := v.money()
+		shares, value := 0.0, 0.0
+		var parts []string
+		for _, e := range events {
+			shares += e.Shares
+			value += e.Shares * (v.Price - e.StrikePrice)
+			parts = append(parts, fmt.Sprintf("%s from %s", formatShares(e.Shares), e.Grant))
+		}
+		date := events[0].Date
+		fmt.Printf("Your next vest is on %s (in %d days): %s shares", date.Format("Mon Jan 2, 2006"), calendarDays(now, date), formatShares(shares))
+		if len(events) > 1 {
+			fmt.Printf(" (%s)", strings.Join(parts, ", "))
+		} else if len(v.Grants) > 1 {
+			fmt.Printf(" of %s", events[0].Grant)
+		}
+		fmt.Printf(", worth %s at today's price of %s.\n", ac.FormatMoney(value), ac.FormatMoney(v.Price))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(nextCmd)
+}
+
+// nextVests returns the events on the earliest upcoming vest date, one per
+// grant vesting that day.
+func nextVests(events []vestEvent) []vestEvent {
+	var next []vestEvent
+	for _, e := range events {
+		if len(next) > 0 && !e.Date.Equal(next[0].Date) {
+			break
+		}
+		next = append(next, e)
+	}
+	return next
+}
+
+func writeNextJSON(v valuation, events []vestEvent) error {
+	out := struct {
+		SchemaVersion int        `json:"schema_version"`
+		Ticker        string     `json:"ticker"`
+		Price         float64    `json:"price"`
+		Date          *time.Time `json:"date,omitempty"`
+		Shares        float64    `json:"shares"`
+		Value         float64    `json:"value"`
+		Vests         []jsonVest `json:"vests"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Vests: []jsonVest{}}
+	for _, e := range events {
+		value := e.Shares * (v.Price - e.StrikePrice)
+		out.Date = &events[0].Date
+		out.Shares += e.Shares
+		out.Value += value
+		out.Vests = append(out.Vests, jsonVest{Date: e.Date, Grant: e.Grant, Shares: e.Shares, Value: value})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,421 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// schemaVersion is the version of the JSON output format. Fields may be added
+// within a version, but renaming or removing a field, or changing its type or
+// meaning, requires bumping it so downstream consumers can detect the change.
+const schemaVersion = 3
+
+// jsonReport is the stable JSON representation of a valuation.
+type jsonReport struct {
+	SchemaVersion       int               `json:"schema_version"`
+	GeneratedAt         time.Time         `json:"generated_at"`
+	Position            string            `json:"position,omitempty"`
+	Holding             bool              `json:"holding,omitempty"`
+	Class               string            `json:"class,omitempty"`
+	Currency            string            `json:"currency,omitempty"`
+	Exchange            string            `json:"exchange"`
+	MarketOpen          bool              `json:"market_open"`
+	Ticker              string            `json:"ticker"`
+	Price               float64           `json:"price"`
+	QuotedPrice         float64           `json:"quoted_price,omitempty"`
+	StrikePrice         float64           `json:"strike_price"`
+	Shares              float64           `json:"shares"`
+	SharesSold          float64           `json:"shares_sold"`
+	PercentVested       float64           `json:"percent_vested"`
+	SharesVested        float64           `json:"shares_vested"`
+	SharesUnvested      float64           `json:"shares_unvested"`
+	SharesVestedUnsold  float64           `json:"shares_vested_unsold"`
+	TotalValue          float64           `json:"total_value"`
+	VestedValue         float64           `json:"vested_value"`
+	UnvestedValue       float64           `json:"unvested_value"`
+	VestStart           time.Time         `json:"vest_start"`
+	VestEnd             time.Time         `json:"vest_end"`
+	Cliff               *time.Time        `json:"cliff,omitempty"`
+	SecondsRemaining    int64             `json:"seconds_remaining"`
+	Change              float64           `json:"change"`
+	ChangePercent       float64           `json:"change_percent"`
+	VestedValueChange   float64           `json:"vested_value_change"`
+	YearHigh            *float64          `json:"week52_high,omitempty"`
+	YearLow             *float64          `json:"week52_low,omitempty"`
+	RangePercent        *float64          `json:"week52_position_percent,omitempty"`
+	Grants              []jsonGrant       `json:"grants"`
+	ProjectedRefreshers []jsonGrant       `json:"projected_refreshers,omitempty"`
+	ProjectedValue      float64           `json:"projected_value"`
+	AsOf                *time.Time        `json:"as_of,omitempty"`
+	QuitOn              *time.Time        `json:"quit_on,omitempty"`
+	Acquisition         *jsonAcquisition  `json:"acquisition,omitempty"`
+	SharesSellable      float64           `json:"shares_sellable"`
+	SellableValue       float64           `json:"sellable_value"`
+	VestingPerDay       float64           `json:"vesting_per_day"`
+	VestingPerWeek      float64           `json:"vesting_per_week"`
+	VestingPerMonth     float64           `json:"vesting_per_month"`
+	WindowOpens         *time.Time        `json:"window_opens,omitempty"`
+	NextBlackout        *time.Time        `json:"next_blackout,omitempty"`
+	TerminatedOn        *time.Time        `json:"terminated_on,omitempty"`
+	ExerciseCost        float64           `json:"exercise_cost,omitempty"`
+	VestedTax           *float64          `json:"vested_tax,omitempty"`
+	VestedWithheld      *float64          `json:"vested_withheld,omitempty"`
+	VestedAfterTax      *float64          `json:"vested_after_tax,omitempty"`
+	DividendYield       float64           `json:"dividend_yield"`
+	DividendIncome      float64           `json:"dividend_income"`
+	DividendsPaid       *float64          `json:"dividends_paid,omitempty"`
+	DividendsAccrued    *float64          `json:"dividends_accrued,omitempty"`
+	ReinvestedShares    *float64          `json:"reinvested_shares,omitempty"`
+	ReinvestedValue     *float64          `json:"reinvested_value,omitempty"`
+	TotalReturn         *float64          `json:"total_return,omitempty"`
+	BlackScholes        *jsonBlackScholes `json:"black_scholes,omitempty"`
+	Home                *jsonHome         `json:"home,omitempty"`
+	Private             *jsonPrivate      `json:"private,omitempty"`
+	Tender              *jsonTender       `json:"tender,omitempty"`
+	Concentration       *float64          `json:"percent_of_net_worth,omitempty"`
+	Salary              float64           `json:"salary,omitempty"`
+	UnvestedSalaryRatio *float64          `json:"unvested_salary_ratio,omitempty"`
+	UnvestedMonthsPay   *float64          `json:"unvested_months_of_salary,omitempty"`
+	TargetValue         *float64          `json:"target_value,omitempty"`
+	TargetPrice         *float64          `json:"target_price,omitempty"`
+	Trends              []jsonTrend       `json:"trends,omitempty"`
+}
+
+// jsonTrend is the change since a reference date named by the trends
+// setting; the price is left out if the price history doesn't reach back to
+// it, and the vested value if no snapshot was taken since.
+type jsonTrend struct {
+	Since              string   `json:"since"`
+	Date               string   `json:"date"`
+	Price              *float64 `json:"price,omitempty"`
+	PriceChangePercent *float64 `json:"price_change_percent,omitempty"`
+	VestedValue        *float64 `json:"vested_value,omitempty"`
+	VestedValueChange  *float64 `json:"vested_value_change,omitempty"`
+}
+
+// jsonTender is the tender offer scenario, present with --tender-price.
+type jsonTender struct {
+	Price           float64 `json:"price"`
+	PercentEligible float64 `json:"percent_eligible"`
+	Shares          float64 `json:"shares"`
+	Proceeds        float64 `json:"proceeds"`
+	Tax             float64 `json:"tax"`
+	NetProceeds     float64 `json:"net_proceeds"`
+	SharesRemaining float64 `json:"shares_remaining"`
+	RemainingValue  float64 `json:"remaining_value"`
+}
+
+// jsonPrivate is a private company's valuation, present in place of a
+// market quote.
+type jsonPrivate struct {
+	FMV            float64    `json:"409a_price"`
+	FMVDate        *time.Time `json:"409a_date,omitempty"`
+	DLOM           float64    `json:"percent_dlom,omitempty"`
+	PreferredPrice float64    `json:"preferred_price,omitempty"`
+	PreferredDate  *time.Time `json:"preferred_date,omitempty"`
+	PreferredValue float64    `json:"preferred_value,omitempty"`
+	FullyDiluted   float64    `json:"fully_diluted_shares,omitempty"`
+	Ownership      float64    `json:"percent_owned,omitempty"`
+	Dilution       float64    `json:"percent_dilution,omitempty"`
+	DilutedPrice   float64    `json:"diluted_price,omitempty"`
+	DilutedValue   float64    `json:"diluted_value,omitempty"`
+}
+
+// jsonHome is the report's values converted to the home currency, present
+// when one is configured.
+type jsonHome struct {
+	Currency      string  `json:"currency"`
+	ExchangeRate  float64 `json:"exchange_rate"`
+	Price         float64 `json:"price"`
+	TotalValue    float64 `json:"total_value"`
+	VestedValue   float64 `json:"vested_value"`
+	UnvestedValue float64 `json:"unvested_value"`
+}
+
+// jsonBlackScholes is the theoretical value of the options, present with
+// --black-scholes.
+type jsonBlackScholes struct {
+	Volatility   float64 `json:"volatility"`
+	RiskFreeRate float64 `json:"risk_free_rate"`
+	Yield        float64 `json:"dividend_yield"`
+	OptionValue  float64 `json:"option_value"`
+	SpreadValue  float64 `json:"spread_value"`
+	Delta        float64 `json:"delta"`
+	Theta        float64 `json:"theta"`
+	Vega         float64 `json:"vega"`
+}
+
+// jsonGrant is one grant's part of the report.
+type jsonGrant struct {
+	Name               string     `json:"name"`
+	Shares             float64    `json:"shares"`
+	StrikePrice        float64    `json:"strike_price"`
+	SharesVested       float64    `json:"shares_vested"`
+	SharesUnvested     float64    `json:"shares_unvested"`
+	SharesVestedUnsold float64    `json:"shares_vested_unsold"`
+	VestedValue        float64    `json:"vested_value"`
+	UnvestedValue      float64    `json:"unvested_value"`
+	VestStart          time.Time  `json:"vest_start"`
+	VestEnd            time.Time  `json:"vest_end"`
+	NextVestDate       *time.Time `json:"next_vest_date,omitempty"`
+	NextVestShares     float64    `json:"next_vest_shares"`
+	Performance        *jsonPSU   `json:"performance,omitempty"`
+	ExerciseDeadline   *time.Time `json:"exercise_deadline,omitempty"`
+	ExerciseCost       float64    `json:"exercise_cost,omitempty"`
+	Expires            *time.Time `json:"expires,omitempty"`
+	ExpiryWarning      bool       `json:"expiry_warning,omitempty"`
+}
+
+// jsonPSU is the payout range of a performance stock unit grant.
+type jsonPSU struct {
+	TargetShares   float64 `json:"target_shares"`
+	Multiplier     float64 `json:"multiplier"`
+	ThresholdValue float64 `json:"threshold_value"`
+	TargetValue    float64 `json:"target_value"`
+	MaximumValue   float64 `json:"maximum_value"`
+}
+
+// jsonAcquisition is the change-of-control scenario, present with
+// --assume-acquisition.
+type jsonAcquisition struct {
+	SingleTriggerShares float64 `json:"single_trigger_shares"`
+	SingleTriggerValue  float64 `json:"single_trigger_value"`
+	DoubleTriggerShares float64 `json:"double_trigger_shares"`
+	DoubleTriggerValue  float64 `json:"double_trigger_value"`
+}
+
+func newJSONReport(v valuation) jsonReport {
+	r := jsonReport{
+		SchemaVersion:      schemaVersion,
+		GeneratedAt:        time.Now().UTC(),
+		Position:           v.Position,
+		Holding:            v.Holding,
+		Class:              v.Class,
+		Currency:           v.Currency,
+		Exchange:           v.Exchange,
+		MarketOpen:         v.MarketOpen,
+		Ticker:             v.Ticker,
+		Price:              v.Price,
+		QuotedPrice:        v.QuotedPrice,
+		StrikePrice:        v.StrikePrice,
+		Shares:             v.Shares,
+		SharesSold:         v.SharesSold,
+		PercentVested:      v.PortionDone * 100,
+		SharesVested:       v.SharesVested,
+		SharesUnvested:     v.SharesUnvested,
+		SharesVestedUnsold: v.SharesVestedUnsold,
+		TotalValue:         v.TotalValue,
+		VestedValue:        v.VestedValue,
+		UnvestedValue:      v.UnvestedValue,
+		VestStart:          v.VestStart,
+		VestEnd:            v.VestEnd,
+		SecondsRemaining:   roundTime(v.Remaining.Seconds()),
+		Change:             v.Change,
+		ChangePercent:      v.ChangePercent,
+		VestedValueChange:  v.VestedChange,
+		SharesSellable:     v.SharesSellable,
+		SellableValue:      v.SellableValue,
+		VestingPerDay:      v.VestingPerDay,
+		VestingPerWeek:     v.VestingPerDay * 7,
+		VestingPerMonth:    v.VestingPerDay * daysPerYear / 12,
+		DividendYield:      v.DividendYield,
+		DividendIncome:     v.DividendIncome,
+	}
+	if !v.Cliff.IsZero() {
+		r.Cliff = &v.Cliff
+	}
+	if !v.AsOf.IsZero() {
+		r.AsOf = &v.AsOf
+	}
+	if !v.QuitOn.IsZero() {
+		r.QuitOn = &v.QuitOn
+	}
+	if !v.WindowOpens.IsZero() {
+		r.WindowOpens = &v.WindowOpens
+	}
+	if !v.NextBlackout.IsZero() {
+		r.NextBlackout = &v.NextBlackout
+	}
+	if !v.TerminatedOn.IsZero() {
+		r.TerminatedOn = &v.TerminatedOn
+		r.ExerciseCost = v.ExerciseCost
+	}
+	if v.Tax.Set {
+		r.VestedTax, r.VestedWithheld, r.VestedAfterTax = &v.VestedTax, &v.VestedWithheld, &v.VestedAfterTax
+	}
+	if viper.GetBool("dividends") {
+		total := v.totalReturn()
+		r.DividendsPaid, r.DividendsAccrued, r.TotalReturn = &v.DividendsPaid, &v.DividendsAccrued, &total
+		if viper.GetBool("drip") {
+			r.ReinvestedShares, r.ReinvestedValue = &v.ReinvestedShares, &v.ReinvestedValue
+		}
+	}
+	if v.Salary > 0 {
+		ratio, months := v.UnvestedValue/v.Salary, v.salaryMonths()
+		r.Salary, r.UnvestedSalaryRatio, r.UnvestedMonthsPay = v.Salary, &ratio, &months
+	}
+	if v.TargetValue > 0 {
+		r.TargetValue = &v.TargetValue
+		if v.TargetReachable {
+			r.TargetPrice = &v.TargetPrice
+		}
+	}
+	if v.ConcentrationLimit > 0 {
+		percent := v.Concentration * 100
+		r.Concentration = &percent
+	}
+	for _, t := range v.Trends {
+		jt := jsonTrend{Since: t.Since, Date: t.Date.Format("2006-01-02")}
+		if t.Price > 0 {
+			price, percent := t.Price, (v.Price-t.Price)/t.Price*100
+			jt.Price, jt.PriceChangePercent = &price, &percent
+		}
+		if t.VestedValue != nil {
+			change := v.VestedValue - *t.VestedValue
+			jt.VestedValue, jt.VestedValueChange = t.VestedValue, &change
+		}
+		r.Trends = append(r.Trends, jt)
+	}
+	if t := v.Tender; t != nil {
+		r.Tender = &jsonTender{
+			Price:           t.Price,
+			PercentEligible: t.Cap * 100,
+			Shares:          t.Shares,
+			Proceeds:        t.Proceeds,
+			Tax:             t.Tax,
+			NetProceeds:     t.Proceeds - t.Tax,
+			SharesRemaining: t.Remaining,
+			RemainingValue:  t.RemainingValue,
+		}
+	}
+	if p := v.Private; p != nil {
+		r.Private = &jsonPrivate{
+			FMV:            p.FMV,
+			DLOM:           p.DLOM * 100,
+			PreferredPrice: p.Preferred,
+			PreferredValue: v.PreferredValue,
+			FullyDiluted:   p.FullyDiluted,
+			Ownership:      v.Ownership * 100,
+			Dilution:       (1 - p.retained()) * 100,
+			DilutedPrice:   v.DilutedPrice,
+			DilutedValue:   v.DilutedValue,
+		}
+		if !p.FMVDate.IsZero() {
+			r.Private.FMVDate = &p.FMVDate
+		}
+		if !p.PreferredDate.IsZero() {
+			r.Private.PreferredDate = &p.PreferredDate
+		}
+	}
+	if v.HomeCurrency != "" {
+		r.Home = &jsonHome{
+			Currency:      v.HomeCurrency,
+			ExchangeRate:  v.ExchangeRate,
+			Price:         v.home(v.Price),
+			TotalValue:    v.home(v.TotalValue),
+			VestedValue:   v.home(v.VestedValue),
+			UnvestedValue: v.home(v.UnvestedValue),
+		}
+	}
+	if v.Model != nil {
+		r.BlackScholes = &jsonBlackScholes{
+			Volatility:   v.Model.Volatility,
+			RiskFreeRate: v.Model.Rate,
+			Yield:        v.Model.Yield,
+			OptionValue:  v.OptionValue,
+			SpreadValue:  v.OptionSpreadValue,
+			Delta:        v.Delta,
+			Theta:        v.Theta,
+			Vega:         v.Vega,
+		}
+	}
+	if viper.GetBool("assume-acquisition") {
+		r.Acquisition = &jsonAcquisition{
+			SingleTriggerShares: v.AccelSingle,
+			SingleTriggerValue:  v.AccelSingleValue,
+			DoubleTriggerShares: v.AccelDouble,
+			DoubleTriggerValue:  v.AccelDoubleValue,
+		}
+	}
+	if v.YearHigh > v.YearLow {
+		position := v.RangePosition * 100
+		r.YearHigh, r.YearLow, r.RangePercent = &v.YearHigh, &v.YearLow, &position
+	}
+	r.Grants = jsonGrants(v.Grants, v.Price)
+	r.ProjectedRefreshers = jsonGrants(v.Projected, v.Price)
+	r.ProjectedValue = v.ProjectedValue
+	return r
+}
+
+func jsonGrants(grants []grantValuation, price float64) []jsonGrant {
+	var out []jsonGrant
+	for _, g := range grants {
+		jg := jsonGrant{
+			Name:               g.Name,
+			Shares:             g.Shares,
+			StrikePrice:        g.StrikePrice,
+			SharesVested:       g.SharesVested,
+			SharesUnvested:     g.SharesUnvested,
+			SharesVestedUnsold: g.SharesVestedUnsold,
+			VestedValue:        g.VestedValue,
+			UnvestedValue:      g.UnvestedValue,
+			VestStart:          g.Start,
+			VestEnd:            g.vestEnd(),
+			NextVestShares:     g.NextVest.Shares,
+		}
+		if !g.NextVest.Date.IsZero() {
+			next := g.NextVest.Date
+			jg.NextVestDate = &next
+		}
+		if !g.Expires.IsZero() {
+			expires := g.Expires
+			jg.Expires = &expires
+			jg.ExpiryWarning = g.ExpiryWarning
+		}
+		if !g.ExerciseDeadline.IsZero() {
+			deadline := g.ExerciseDeadline
+			jg.ExerciseDeadline = &deadline
+			jg.ExerciseCost = g.ExerciseCost
+		}
+		if g.Type == "psu" {
+			jg.Performance = &jsonPSU{
+				TargetShares:   g.TargetShares,
+				Multiplier:     g.Multiplier,
+				ThresholdValue: g.valueAt(price, g.Threshold),
+				TargetValue:    g.valueAt(price, 1),
+				MaximumValue:   g.valueAt(price, g.Maximum),
+			}
+		}
+		out = append(out, jg)
+	}
+	return out
+}
+
+func writeJSON(w io.Writer, v valuation) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONReport(v))
+}
@@ -0,0 +1,199 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var planSell string
+var planAt string
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Generate a dated sell schedule for your upcoming vests.",
+	Long: `Generate a dated schedule for selling a percentage of your upcoming vests,
+with estimated proceeds at today's price and the fees set under fees in the
+config, as groundwork for a 10b5-1 plan. The cumulative total is net of
+fees.
+
+With --at vest, shares are sold as they vest, or as soon as trading reopens
+if they vest during a blackout. With --at window, the shares vested since
+the last sale are sold together when each trading window opens.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if planSell == "" {
+			fmt.Println("plan: --sell is required")
+			os.Exit(1)
+		}
+		percent, err := configPercent(planSell)
+		if err != nil || percent <= 0 || percent > 1 {
+			fmt.Printf("plan: invalid --sell %q: expected a percentage from 1%% to 100%%\n", planSell)
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		windows, err := loadBlackouts()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if planAt != "vest" && planAt != "window" {
+			fmt.Printf("plan: invalid --at %q: expected vest or window\n", planAt)
+			os.Exit(1)
+		}
+		if planAt == "window" && len(windows) == 0 {
+			fmt.Println("plan: --at window needs blackouts or earnings-dates in the config")
+			os.Exit(1)
+		}
+
+		fees, err := loadFees()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		sales, unplanned := planSales(v, v.upcomingVests(now), windows, percent, planAt == "window")
+		for i := range sales {
+			sales[i].Fees = fees.on(sales[i].Shares, v.Price)
+		}
+		if viper.GetString("output") == "json" {
+			err = writePlanJSON(v, sales, percent)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(sales) == 0 && unplanned == 0 {
+			fmt.Println("Nothing left to vest.")
+			return
+		}
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Sell on\tShares\tProceeds\tFees\tCumulative\t")
+		total := 0.0
+		for _, s := range sales {
+			total += s.Proceeds - s.Fees
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", s.Date.Format("Jan 2, 2006"), formatShares(s.Shares),
+				ac.FormatMoney(s.Proceeds), ac.FormatMoney(s.Fees), ac.FormatMoney(total))
+		}
+		w.Flush()
+		if unplanned > 0 {
+			fmt.Printf("%s shares vest after the last known trading window and aren't scheduled; add later earnings-dates to plan them.\n",
+				formatShares(unplanned))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	planCmd.Flags().StringVar(&planSell, "sell", "", "percentage of each vest to sell (e.g. 50%)")
+	planCmd.Flags().StringVar(&planAt, "at", "vest", "when to sell: at each vest, or at each open trading window")
+}
+
+// sale is one dated sale in a sell plan.
+type sale struct {
+	Date     time.Time
+	Shares   float64
+	Proceeds float64
+	Fees     float64
+}
+
+// planSales schedules selling percent of each vest event, either as soon as
+// trading allows or, with atWindows, when the next trading window opens.
+// Shares vesting after the last known window opening are returned as
+// unplanned.
+func planSales(v valuation, vests []vestEvent, windows []blackout, percent float64, atWindows bool) ([]sale, float64) {
+	var openings []time.Time
+	for _, b := range windows {
+		open := tradingOpens(windows, b.Start)
+		if len(openings) == 0 || open.After(openings[len(openings)-1]) {
+			openings = append(openings, open)
+		}
+	}
+
+	var sales []sale
+	unplanned := 0.0
+	for _, e := range vests {
+		date := tradingOpens(windows, e.Date)
+		if atWindows {
+			date = time.Time{}
+			for _, open := range openings {
+				if open.After(e.Date) {
+					date = open
+					break
+				}
+			}
+			if date.IsZero() {
+				unplanned += e.Shares * percent
+				continue
+			}
+		}
+
+		shares := e.Shares * percent
+		proceeds := shares * (v.Price - e.StrikePrice)
+		if n := len(sales); n > 0 && sales[n-1].Date.Equal(date) {
+			sales[n-1].Shares += shares
+			sales[n-1].Proceeds += proceeds
+			continue
+		}
+		sales = append(sales, sale{Date: date, Shares: shares, Proceeds: proceeds})
+	}
+	return sales, unplanned
+}
+
+// jsonSale is one sale in the plan's JSON output.
+type jsonSale struct {
+	Date     time.Time `json:"date"`
+	Shares   float64   `json:"shares"`
+	Proceeds float64   `json:"proceeds"`
+	Fees     float64   `json:"fees"`
+}
+
+func writePlanJSON(v valuation, sales []sale, percent float64) error {
+	out := struct {
+		SchemaVersion int        `json:"schema_version"`
+		Ticker        string     `json:"ticker"`
+		Price         float64    `json:"price"`
+		SellPercent   float64    `json:"sell_percent"`
+		Sales         []jsonSale `json:"sales"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, SellPercent: percent * 100, Sales: []jsonSale{}}
+	for _, s := range sales {
+		out.Sales = append(out.Sales, jsonSale{Date: s.Date, Shares: s.Shares, Proceeds: s.Proceeds, Fees: s.Fees})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
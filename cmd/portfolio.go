@@ -0,0 +1,432 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/leekchan/accounting"
+	"github.com/spf13/viper"
+)
+
+// position is the grants held in one stock, listed under positions in the
+// config. Without positions, grants default to the configured ticker and
+// those naming another ticker (an old employer's RSUs, a spouse's options)
+// form positions of their own. Currency is what the stock trades in.
+// Private is set for a private company, which may have no ticker. A
+// Holding is shares simply owned, like an index fund, with no vesting.
+// A position with several share classes is split into one per Class,
+// priced at FixedPrice or else at the quote, times Multiplier.
+type position struct {
+	Name       string
+	Ticker     string
+	Type       string
+	Currency   string
+	Private    *privatePrice
+	Holding    bool
+	Class      string
+	FixedPrice float64
+	Multiplier float64
+	Grants     []grant
+}
+
+// holdingConfig is an entry in the holdings list: shares of a fund or
+// stock already owned outright.
+type holdingConfig struct {
+	Name     string      `mapstructure:"name"`
+	Ticker   string      `mapstructure:"ticker"`
+	Shares   float64     `mapstructure:"shares"`
+	Currency string      `mapstructure:"currency"`
+	Since    interface{} `mapstructure:"since"`
+}
+
+// positionConfig is a position as written in the config. Its type and
+// ticker are the defaults for its grants.
+type positionConfig struct {
+	Name     string         `mapstructure:"name"`
+	Ticker   string         `mapstructure:"ticker"`
+	Type     string         `mapstructure:"type"`
+	Currency string         `mapstructure:"currency"`
+	Private  *privateConfig `mapstructure:"private"`
+	Classes  []classConfig  `mapstructure:"classes"`
+	Grants   []grantConfig  `mapstructure:"grants"`
+}
+
+// loadPositions reads the positions from the config, or groups the grants
+// by ticker, with the configured ticker's position first.
+func loadPositions() ([]position, error) {
+	var positions []position
+	if viper.IsSet("positions") {
+		var err error
+		positions, err = readPositions()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		grants, err := loadGrants()
+		if err != nil {
+			return nil, err
+		}
+		for _, g := range grants {
+			i := 0
+			for i < len(positions) && positions[i].Ticker != g.Ticker {
+				i++
+			}
+			if i == len(positions) {
+				positions = append(positions, position{Name: g.Ticker, Ticker: g.Ticker})
+			}
+			positions[i].Grants = append(positions[i].Grants, g)
+		}
+		err = applyPrivateConfig(positions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	holdings, err := loadHoldings()
+	if err != nil {
+		return nil, err
+	}
+	positions = append(positions, holdings...)
+
+	primary := normalizeSymbol(viper.GetString("ticker"))
+	for i, p := range positions {
+		if p.Ticker == primary {
+			copy(positions[1:i+1], positions[:i])
+			positions[0] = p
+			break
+		}
+	}
+	return positions, nil
+}
+
+// configuredTicker returns the configured ticker or, without one, the first
+// position's, which is the one valued then.
+func configuredTicker() string {
+	if ticker := viper.GetString("ticker"); ticker != "" {
+		return normalizeSymbol(ticker)
+	}
+	positions, err := loadPositions()
+	if err != nil || len(positions) == 0 {
+		return ""
+	}
+	return positions[0].Ticker
+}
+
+// loadHoldings reads the holdings list, each a position of one grant that
+// vested in full when the shares were bought (or long ago).
+func loadHoldings() ([]position, error) {
+	var configs []holdingConfig
+	err := viper.UnmarshalKey("holdings", &configs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holdings: %s", err)
+	}
+	var holdings []position
+	for i, hc := range configs {
+		p := position{Name: hc.Name, Ticker: normalizeSymbol(hc.Ticker), Currency: strings.ToUpper(hc.Currency), Holding: true}
+		if p.Ticker == "" {
+			return nil, fmt.Errorf("holding %d: ticker is required", i+1)
+		}
+		if p.Name == "" {
+			p.Name = p.Ticker
+		}
+		if hc.Shares <= 0 {
+			return nil, fmt.Errorf("holding %s: shares must be positive", p.Name)
+		}
+		var since time.Time
+		if hc.Since != nil {
+			since, err = configDate(hc.Since)
+			if err != nil {
+				return nil, fmt.Errorf("holding %s: since: %s", p.Name, err)
+			}
+		}
+		p.Grants = []grant{{
+			Name:     p.Name,
+			Ticker:   p.Ticker,
+			Type:     "holding",
+			Shares:   hc.Shares,
+			Start:    since,
+			End:      since,
+			Tranches: []tranche{{Date: since, Shares: hc.Shares}},
+		}}
+		holdings = append(holdings, p)
+	}
+	return holdings, nil
+}
+
+// applyPrivateConfig makes the configured ticker's position, which may have
+// no ticker at all, a private company's when private is set.
+func applyPrivateConfig(positions []position) error {
+	if !viper.IsSet("private") {
+		return nil
+	}
+	var pc privateConfig
+	err := viper.UnmarshalKey("private", &pc)
+	if err != nil {
+		return fmt.Errorf("invalid private: %s", err)
+	}
+	primary := normalizeSymbol(viper.GetString("ticker"))
+	for i := range positions {
+		if positions[i].Ticker != primary {
+			continue
+		}
+		positions[i].Private, err = pc.price()
+		if err != nil {
+			return err
+		}
+		if pc.Name != "" {
+			positions[i].Name = pc.Name
+		} else if primary == "" {
+			positions[i].Name = "Private company"
+		}
+	}
+	return nil
+}
+
+// readPositions reads the positions list, each with its own grants.
+func readPositions() ([]position, error) {
+	var configs []positionConfig
+	err := viper.UnmarshalKey("positions", &configs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid positions: %s", err)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("positions is empty")
+	}
+	defaults, err := loadGrantDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	var positions []position
+	names := map[string]bool{}
+	for i, pc := range configs {
+		p := position{Name: pc.Name, Ticker: normalizeSymbol(pc.Ticker), Type: pc.Type, Currency: strings.ToUpper(pc.Currency)}
+		if pc.Private != nil {
+			p.Private, err = pc.Private.price()
+			if err != nil {
+				return nil, fmt.Errorf("position %d: %s", i+1, err)
+			}
+			if p.Name == "" {
+				p.Name = pc.Private.Name
+			}
+		}
+		if p.Ticker == "" && p.Name == "" {
+			return nil, fmt.Errorf("position %d: a ticker, or a name for a private company, is required", i+1)
+		}
+		if p.Ticker == "" && p.Private == nil {
+			return nil, fmt.Errorf("%s: ticker is required", p.Name)
+		}
+		if p.Name == "" {
+			p.Name = p.Ticker
+		}
+		if len(pc.Grants) == 0 {
+			return nil, fmt.Errorf("%s: no grants", p.Name)
+		}
+		classes, err := pc.shareClasses()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p.Name, err)
+		}
+		byClass := make([][]grant, len(classes))
+		for j, gc := range pc.Grants {
+			if gc.Name == "" {
+				gc.Name = fmt.Sprintf("%s grant %d", p.Name, j+1)
+			}
+			if names[gc.Name] {
+				return nil, fmt.Errorf("%s: there's already a grant named %q", p.Name, gc.Name)
+			}
+			names[gc.Name] = true
+			class := 0
+			if len(classes) > 0 {
+				class, err = classIndex(classes, gc.Class)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %s: %s", p.Name, gc.Name, err)
+				}
+				if gc.Ticker == "" {
+					gc.Ticker = classes[class].Ticker
+				}
+			} else if gc.Class != "" {
+				return nil, fmt.Errorf("%s: %s: the position has no share classes", p.Name, gc.Name)
+			}
+			if gc.Ticker == "" {
+				gc.Ticker = p.Ticker
+			}
+			if gc.Type == "" {
+				gc.Type = p.Type
+			}
+			g, err := defaults.newGrant(gc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %s", p.Name, gc.Name, err)
+			}
+			if len(classes) > 0 {
+				byClass[class] = append(byClass[class], g)
+			} else {
+				p.Grants = append(p.Grants, g)
+			}
+		}
+		if len(classes) == 0 {
+			positions = append(positions, p)
+		}
+		for i, c := range classes {
+			if len(byClass[i]) > 0 {
+				positions = append(positions, p.withClass(c, byClass[i]))
+			}
+		}
+	}
+	return positions, nil
+}
+
+// loadPortfolio values every position as of now, converting those in
+// another currency to the home currency, or the first position's.
+func loadPortfolio(now time.Time) ([]valuation, error) {
+	positions, err := loadPositions()
+	if err != nil {
+		return nil, err
+	}
+	var portfolio []valuation
+	for _, p := range positions {
+		v, err := p.valuation(now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", p.Ticker, err)
+		}
+		portfolio = append(portfolio, v)
+	}
+	currency := reportingCurrency(portfolio)
+	for i := range portfolio {
+		err = portfolio[i].convertTo(currency)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", portfolio[i].Ticker, err)
+		}
+	}
+	return portfolio, nil
+}
+
+// reportingCurrency is the currency a portfolio is totalled in.
+func reportingCurrency(portfolio []valuation) string {
+	if home := homeCurrency(); home != "" {
+		return home
+	}
+	return portfolio[0].Currency
+}
+
+// reported converts an amount in the position's currency to the
+// portfolio's.
+func (v valuation) reported(amount float64, currency string) float64 {
+	if v.Currency == currency {
+		return amount
+	}
+	return v.home(amount)
+}
+
+// blendedVested is the portion of the portfolio's equity value that has
+// vested, so that a large position counts for more than a small one.
+// Holdings, which never vested, are left out.
+func blendedVested(portfolio []valuation, currency string) float64 {
+	vested, unvested := 0.0, 0.0
+	for _, v := range portfolio {
+		if !v.Holding {
+			vested += math.Max(v.reported(v.VestedValue, currency), 0)
+			unvested += math.Max(v.reported(v.UnvestedValue, currency), 0)
+		}
+	}
+	if vested+unvested <= 0 {
+		return 0
+	}
+	return vested / (vested + unvested)
+}
+
+// formatPortfolio prints each position's value and the combined totals,
+// with a subtotal for a position split into share classes.
+func formatPortfolio(portfolio []valuation) {
+	currency := reportingCurrency(portfolio)
+	ac := accounting.Accounting{Symbol: currencySymbol(currency), Precision: 2}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Position\tTicker\tPrice\tChange\tVested\tVested value\tUnvested value\tNext vest\t")
+	var vested, unvested float64
+	var classes []valuation
+	for i, v := range portfolio {
+		vested += v.reported(v.VestedValue, currency)
+		unvested += v.reported(v.UnvestedValue, currency)
+		name := v.Position
+		if v.Class != "" {
+			name = fmt.Sprintf("%s (%s)", v.Position, v.Class)
+			classes = append(classes, v)
+		}
+		next := "-"
+		if vests := v.upcomingVests(v.AsOf); len(vests) > 0 {
+			next = vests[0].Date.Format("Jan 2, 2006")
+		}
+		portion := fmt.Sprintf("%d%%", int64(v.PortionDone*100))
+		if v.Holding {
+			portion = "-"
+		}
+		money := v.money()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%+.2f%%\t%s\t%s\t%s\t%s\t\n", name, v.Ticker, money.FormatMoney(v.Price), v.ChangePercent,
+			portion, money.FormatMoney(v.VestedValue), money.FormatMoney(v.UnvestedValue), next)
+
+		last := i == len(portfolio)-1 || portfolio[i+1].Position != v.Position || portfolio[i+1].Class == ""
+		if len(classes) > 1 && last {
+			var classVested, classUnvested float64
+			for _, c := range classes {
+				classVested += c.reported(c.VestedValue, currency)
+				classUnvested += c.reported(c.UnvestedValue, currency)
+			}
+			fmt.Fprintf(w, "%s total\t\t\t\t%d%%\t%s\t%s\t\t\n", v.Position, int64(blendedVested(classes, currency)*100),
+				ac.FormatMoney(classVested), ac.FormatMoney(classUnvested))
+		}
+		if last {
+			classes = nil
+		}
+	}
+	fmt.Fprintf(w, "Total\t\t\t\t%d%%\t%s\t%s\t\t\n", int64(blendedVested(portfolio, currency)*100), ac.FormatMoney(vested), ac.FormatMoney(unvested))
+	w.Flush()
+}
+
+// writePortfolioJSON writes each position's report along with the combined
+// totals.
+func writePortfolioJSON(w io.Writer, portfolio []valuation) error {
+	out := struct {
+		SchemaVersion int          `json:"schema_version"`
+		GeneratedAt   time.Time    `json:"generated_at"`
+		Currency      string       `json:"currency"`
+		VestedValue   float64      `json:"vested_value"`
+		UnvestedValue float64      `json:"unvested_value"`
+		TotalValue    float64      `json:"total_value"`
+		PercentVested float64      `json:"percent_vested"`
+		Positions     []jsonReport `json:"positions"`
+	}{SchemaVersion: schemaVersion, GeneratedAt: time.Now().UTC(), Currency: reportingCurrency(portfolio)}
+	for _, v := range portfolio {
+		out.VestedValue += v.reported(v.VestedValue, out.Currency)
+		out.UnvestedValue += v.reported(v.UnvestedValue, out.Currency)
+		out.TotalValue += v.reported(v.TotalValue, out.Currency)
+		out.Positions = append(out.Positions, newJSONReport(v))
+	}
+	out.PercentVested = blendedVested(portfolio, out.Currency) * 100
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,254 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// priceCache holds the daily closes fetched for a ticker, keyed by day, as
+// the provider gave them (before restating for splits). Full records that
+// the whole history has been fetched, not just the last hundred days.
+type priceCache struct {
+	Updated time.Time          `json:"updated"`
+	Full    bool               `json:"full"`
+	Closes  map[string]float64 `json:"closes"`
+}
+
+// overviewCache holds a ticker's company overview as last fetched.
+type overviewCache struct {
+	Updated  time.Time    `json:"updated"`
+	Overview JsonOverview `json:"overview"`
+}
+
+// cachedOverview returns the symbol's company overview, which changes slowly
+// enough to fetch once a day: from beside its cached closes if it was
+// fetched since the exchange last closed, and otherwise from OVERVIEW. If
+// that fails, whatever is cached is used, and without that the overview is
+// empty, leaving out the 52-week range and dividend yield.
+func cachedOverview(symbol string) JsonOverview {
+	now := time.Now()
+	var cache overviewCache
+	path := ""
+	if viper.GetBool("cache-prices") {
+		prices, err := priceCachePath(symbol)
+		if err == nil {
+			path = strings.TrimSuffix(prices, ".json") + ".overview.json"
+			data, err := os.ReadFile(path)
+			if err == nil && json.Unmarshal(data, &cache) == nil && !cache.Updated.Before(lastClose(symbolExchange(symbol), now)) {
+				return cache.Overview
+			}
+		}
+	}
+
+	// a rate-limited or unknown symbol's reply has no symbol
+	overview, err := getOverview(symbol)
+	if err != nil || overview.Symbol == "" {
+		return cache.Overview
+	}
+	if path != "" {
+		data, err := json.Marshal(overviewCache{Updated: now, Overview: overview})
+		if err == nil {
+			err = writeFileAtomic(path, data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't update the price cache: %s\n", err)
+		}
+	}
+	return overview
+}
+
+// priceCachePath returns where a ticker's closes are cached: a file named
+// for it in the price-cache directory, by default worth/prices in the
+// user's cache directory.
+func priceCachePath(symbol string) (string, error) {
+	dir := viper.GetString("price-cache")
+	if dir != "" {
+		var err error
+		dir, err = homedir.Expand(dir)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "worth", "prices")
+	}
+	return filepath.Join(dir, normalizeSymbol(symbol)+".json"), nil
+}
+
+// loadPriceCache reads a cached price history; a missing one is empty.
+func loadPriceCache(path string) (priceCache, error) {
+	c := priceCache{Closes: map[string]float64{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return priceCache{Closes: map[string]float64{}}, fmt.Errorf("%s: %s", path, err)
+	}
+	if c.Closes == nil {
+		c.Closes = map[string]float64{}
+	}
+	return c, nil
+}
+
+func (c priceCache) save(path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// span returns the first and last days cached.
+func (c priceCache) span() (first, last time.Time) {
+	var days []string
+	for day := range c.Closes {
+		days = append(days, day)
+	}
+	if len(days) == 0 {
+		return
+	}
+	sort.Strings(days)
+	first, _ = time.Parse("2006-01-02", days[0])
+	last, _ = time.Parse("2006-01-02", days[len(days)-1])
+	return first, last
+}
+
+// covers reports whether the cache goes back to since and has been
+// updated since the exchange last closed.
+func (c priceCache) covers(since time.Time, e exchange, now time.Time) bool {
+	if c.Updated.Before(lastClose(e, now)) {
+		return false
+	}
+	first, _ := c.span()
+	// since may be a weekend or holiday, a few days before the first close
+	return c.Full || (!first.IsZero() && !first.After(since.AddDate(0, 0, 4)))
+}
+
+// lastClose returns when the exchange last closed before now, going by
+// weekends but not holidays.
+func lastClose(e exchange, now time.Time) time.Time {
+	loc, err := time.LoadLocation(e.Location)
+	if err != nil {
+		loc = time.UTC
+	}
+	hour, _ := strconv.Atoi(e.Closes[:2])
+	minute, _ := strconv.Atoi(e.Closes[3:])
+	local := now.In(loc)
+	t := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	for t.After(now) || t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// dailyCloses returns the closes for symbol, keyed by day,
+// going back at least days. They come from the price cache when it has
+// them, and otherwise from TIME_SERIES_DAILY, topping the cache up; if
+// that fails, whatever is cached is used. cache-prices: false turns the
+// cache off.
+func dailyCloses(symbol string, days int) (map[string]float64, error) {
+	now := time.Now()
+	since := now.AddDate(0, 0, -days)
+
+	var cache priceCache
+	path := ""
+	if viper.GetBool("cache-prices") {
+		var err error
+		path, err = priceCachePath(symbol)
+		if err == nil {
+			cache, err = loadPriceCache(path)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't read the price cache: %s\n", err)
+			path = ""
+		}
+		if path != "" && cache.covers(since, symbolExchange(symbol), now) {
+			return cache.Closes, nil
+		}
+	}
+
+	// the compact series is the last hundred trading days, enough to top up
+	// a cache that already goes back far enough and isn't too old to join
+	size := "compact"
+	first, last := cache.span()
+	joins := !last.IsZero() && last.After(now.AddDate(0, 0, -140))
+	if days > 100 && (!joins || !cache.Full && first.After(since.AddDate(0, 0, 4))) {
+		size = "full"
+	}
+	var daily JsonDaily
+	err := query("TIME_SERIES_DAILY", symbol, map[string]string{"outputsize": size}, &daily)
+	if err == nil && len(daily.TimeSeries) == 0 {
+		err = fmt.Errorf("no daily prices returned for %s", symbol)
+	}
+	if err != nil {
+		if len(cache.Closes) > 0 {
+			return cache.Closes, nil
+		}
+		return nil, err
+	}
+
+	closes := map[string]float64{}
+	if path != "" {
+		closes = cache.Closes
+	}
+	for day, bar := range daily.TimeSeries {
+		closing, err := strconv.ParseFloat(bar.Close, 64)
+		if err != nil {
+			return nil, err
+		}
+		closes[day] = closing
+	}
+	if path != "" {
+		cache.Closes, cache.Updated, cache.Full = closes, now, size == "full" || cache.Full && joins
+		err = cache.save(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't update the price cache: %s\n", err)
+		}
+	}
+	return closes, nil
+}
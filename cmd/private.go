@@ -0,0 +1,164 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// privateConfig is the private settings of a company without a public
+// ticker: the fair market value of its common stock from the latest 409A
+// valuation and, optionally, the price investors last paid for preferred
+// stock, its fully diluted share count, the financings expected to dilute
+// it and a discount for lack of marketability (DLOM) to take off.
+type privateConfig struct {
+	Name          string            `mapstructure:"name"`
+	FMV           float64           `mapstructure:"409a-price"`
+	FMVDate       interface{}       `mapstructure:"409a-date"`
+	Preferred     float64           `mapstructure:"preferred-price"`
+	PreferredDate interface{}       `mapstructure:"preferred-date"`
+	FullyDiluted  float64           `mapstructure:"fully-diluted-shares"`
+	Financings    []financingConfig `mapstructure:"financings"`
+	DLOM          interface{}       `mapstructure:"dlom"`
+}
+
+// financingConfig is a future round of funding, diluting existing holders
+// either by a given percentage or by raising an amount at a pre-money
+// valuation.
+type financingConfig struct {
+	Name     string      `mapstructure:"name"`
+	Dilution interface{} `mapstructure:"dilution"`
+	Raise    float64     `mapstructure:"raise"`
+	PreMoney float64     `mapstructure:"pre-money"`
+}
+
+// financing is a future round and the share of the company it sells.
+type financing struct {
+	Name     string
+	Dilution float64
+}
+
+// privatePrice is what a private company's shares are valued at, in place
+// of a quote.
+type privatePrice struct {
+	FMV           float64
+	FMVDate       time.Time
+	Preferred     float64
+	PreferredDate time.Time
+	FullyDiluted  float64
+	Financings    []financing
+	DLOM          float64
+}
+
+// price checks the private settings and parses their dates.
+func (pc privateConfig) price() (*privatePrice, error) {
+	if pc.FMV <= 0 {
+		return nil, fmt.Errorf("private: 409a-price is required")
+	}
+	p := &privatePrice{FMV: pc.FMV, Preferred: pc.Preferred}
+	var err error
+	if pc.FMVDate != nil {
+		p.FMVDate, err = configDate(pc.FMVDate)
+		if err != nil {
+			return nil, fmt.Errorf("private: 409a-date: %s", err)
+		}
+	}
+	if pc.PreferredDate != nil {
+		p.PreferredDate, err = configDate(pc.PreferredDate)
+		if err != nil {
+			return nil, fmt.Errorf("private: preferred-date: %s", err)
+		}
+	}
+	p.DLOM, err = configPercent(pc.DLOM)
+	if err != nil {
+		return nil, fmt.Errorf("private: dlom: %s", err)
+	}
+	if p.DLOM < 0 || p.DLOM >= 1 {
+		return nil, fmt.Errorf("private: dlom must be at least 0 and under 100%%")
+	}
+	if pc.FullyDiluted < 0 {
+		return nil, fmt.Errorf("private: fully-diluted-shares can't be negative")
+	}
+	p.FullyDiluted = pc.FullyDiluted
+	for i, fc := range pc.Financings {
+		f := financing{Name: fc.Name}
+		if f.Name == "" {
+			f.Name = fmt.Sprintf("financing %d", i+1)
+		}
+		switch {
+		case fc.Dilution != nil && fc.Raise > 0:
+			return nil, fmt.Errorf("private: %s: dilution and raise can't both be set", f.Name)
+		case fc.Dilution != nil:
+			f.Dilution, err = configPercent(fc.Dilution)
+			if err != nil {
+				return nil, fmt.Errorf("private: %s: dilution: %s", f.Name, err)
+			}
+		case fc.Raise > 0 && fc.PreMoney > 0:
+			f.Dilution = fc.Raise / (fc.PreMoney + fc.Raise)
+		default:
+			return nil, fmt.Errorf("private: %s: expected dilution, or raise and pre-money", f.Name)
+		}
+		if f.Dilution <= 0 || f.Dilution >= 1 {
+			return nil, fmt.Errorf("private: %s: dilution must be between 0 and 100%%", f.Name)
+		}
+		p.Financings = append(p.Financings, f)
+	}
+	return p, nil
+}
+
+// value is what a share is taken to be worth: the 409A price less the
+// discount for lack of marketability.
+func (p privatePrice) value() float64 {
+	return p.FMV * (1 - p.DLOM)
+}
+
+// retained is the share of the company existing holders keep after the
+// financings.
+func (p privatePrice) retained() float64 {
+	kept := 1.0
+	for _, f := range p.Financings {
+		kept *= 1 - f.Dilution
+	}
+	return kept
+}
+
+// applyPrivate records the private prices, and what the shares would be
+// worth at the preferred price.
+func (v *valuation) applyPrivate(p *privatePrice, grants []grant, sales []saleRecord) {
+	v.Private = p
+	if p.Preferred > 0 {
+		v.PreferredValue = valuate(grants, p.Preferred, v.AsOf, sales).TotalValue
+	}
+	if p.FullyDiluted > 0 {
+		v.Ownership = (v.SharesVestedUnsold + v.SharesUnvested) / p.FullyDiluted
+	}
+	// the company's value is taken to stay the same, spread over more shares
+	if len(p.Financings) > 0 {
+		v.DilutedPrice = p.value() * p.retained()
+		v.DilutedValue = valuate(grants, v.DilutedPrice, v.AsOf, sales).TotalValue
+	}
+}
+
+// isStale reports whether the 409A valuation is more than a year old, past
+// when it should have been redone.
+func (p privatePrice) isStale(now time.Time) bool {
+	return !p.FMVDate.IsZero() && p.FMVDate.AddDate(1, 0, 0).Before(now)
+}
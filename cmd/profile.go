@@ -0,0 +1,145 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// allProfiles is the --profile that combines every profile.
+const allProfiles = "all"
+
+var profile string
+
+// baseConfigFile is the --config given alongside --profile, whose directory
+// holds the profiles.
+var baseConfigFile string
+
+// profilesDir returns where profiles live: profiles/ in the directory of
+// the config file, ~/.config/worth by default. Each profile is a directory
+// of its own, holding its config.yaml and ledger.
+func profilesDir(base string) (string, error) {
+	if base != "" {
+		return filepath.Join(filepath.Dir(base), "profiles"), nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "worth", "profiles"), nil
+}
+
+// profileNames lists the profiles, in name order.
+func profileNames(base string) ([]string, error) {
+	dir, err := profilesDir(base)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "config.yaml")); e.IsDir() && err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// profileConfig returns the config file for the named profile. All of them
+// start from the first.
+func profileConfig(base, name string) (string, error) {
+	dir, err := profilesDir(base)
+	if err != nil {
+		return "", err
+	}
+	if name == allProfiles {
+		names, err := profileNames(base)
+		if err != nil {
+			return "", err
+		}
+		if len(names) == 0 {
+			return "", fmt.Errorf("no profiles in %s", dir)
+		}
+		name = names[0]
+	}
+	path := filepath.Join(dir, name, "config.yaml")
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("no profile named %q: expected %s", name, path)
+	}
+	return path, nil
+}
+
+// readProfile switches the config over to the profile in path.
+func readProfile(path string) error {
+	viper.SetConfigFile(path)
+	return viper.ReadInConfig()
+}
+
+// loadProfiles values every profile's positions as of now, named after the
+// profile, and totals them in the first profile's reporting currency.
+func loadProfiles(now time.Time) ([]valuation, error) {
+	names, err := profileNames(baseConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	var portfolio []valuation
+	for _, name := range names {
+		path, err := profileConfig(baseConfigFile, name)
+		if err != nil {
+			return nil, err
+		}
+		err = readProfile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		positions, err := loadPortfolio(now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		for _, v := range positions {
+			v.Position = name + ": " + v.Position
+			portfolio = append(portfolio, v)
+		}
+	}
+
+	err = readProfile(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	currency := reportingCurrency(portfolio)
+	for i := range portfolio {
+		err = portfolio[i].convertTo(currency)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", portfolio[i].Position, err)
+		}
+	}
+	return portfolio, nil
+}
@@ -0,0 +1,369 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var projectSimulations int
+var projectVolatility string
+var projectDrift string
+var projectSeed int64
+var projectGrowth string
+
+// projectPercentiles are the outcomes reported by project.
+var projectPercentiles = []float64{5, 25, 50, 75, 95}
+
+// projectCmd represents the project command
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Simulate what your shares could be worth once fully vested.",
+	Long: `Simulate the stock price on your last vest date along thousands of random
+paths (geometric Brownian motion) and show the range of outcomes for the
+value of everything you hold by then: the vested shares you haven't sold
+and those still to vest. Options that end up underwater are worth nothing.
+
+The volatility is --volatility, or the volatility setting, or else estimated
+from the past year's daily prices; either may be a span such as 90d to
+estimate it from the prices over that span instead. Prices drift up by --drift a year
+(the risk-free-rate setting by default), less the dividend yield.
+
+With --growth, skip the simulation and simply compound today's price at that
+rate a year, showing what each upcoming vest and the whole lot at the last
+vest would be worth. It's a hypothetical, not a forecast.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if projectSimulations < 1 {
+			fmt.Println("project: --simulations must be at least 1")
+			os.Exit(1)
+		}
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !v.VestEnd.After(now) {
+			fmt.Println("Everything has vested already; there's nothing to project.")
+			return
+		}
+		if projectGrowth != "" {
+			rate, err := configPercent(projectGrowth)
+			if err != nil || rate <= -1 {
+				fmt.Printf("project: invalid --growth %q: expected a yearly rate such as 8%%\n", projectGrowth)
+				os.Exit(1)
+			}
+			projectGrowthRate(v, rate, now)
+			return
+		}
+		p, err := loadProjection(v)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		seed := projectSeed
+		if seed == 0 {
+			seed = now.UnixNano()
+		}
+		outcomes := p.simulate(v, projectSimulations, rand.New(rand.NewSource(seed)))
+
+		if viper.GetString("output") == "json" {
+			err = writeProjectJSON(v, p, outcomes)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		ac := v.money()
+		source := "configured"
+		switch {
+		case p.Window == "1y":
+			source = "estimated from the past year"
+		case p.Estimated:
+			source = "estimated from the past " + p.Window
+		}
+		fmt.Printf("Simulated %d price paths to %s (%.1f years) at %.0f%% volatility (%s) and %.1f%% drift.\n\n",
+			projectSimulations, v.VestEnd.Format("Jan 2, 2006"), p.Years, p.Volatility*100, source, p.Drift*100)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Percentile\tPrice\tValue\t")
+		for i, pct := range projectPercentiles {
+			fmt.Fprintf(w, "%.0fth\t%s\t%s\t\n", pct, ac.FormatMoney(outcomes.Prices[i]), ac.FormatMoney(outcomes.Values[i]))
+		}
+		w.Flush()
+		fmt.Println()
+		fmt.Printf("The average outcome is %s, against %s at today's price.\n", ac.FormatMoney(outcomes.Mean), ac.FormatMoney(heldValue(v, v.Price)))
+		if outcomes.Underwater > 0 {
+			fmt.Printf("In %.0f%% of the paths some of your options end up underwater.\n", outcomes.Underwater*100)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(projectCmd)
+
+	projectCmd.Flags().IntVar(&projectSimulations, "simulations", 10000, "number of price paths to simulate")
+	projectCmd.Flags().StringVar(&projectVolatility, "volatility", "", "annual volatility (e.g. 35%), or a span of past prices to estimate it from (e.g. 90d); the past year's if not set")
+	projectCmd.Flags().StringVar(&projectDrift, "drift", "", "expected annual return (e.g. 7%); the risk-free rate if not set")
+	projectCmd.Flags().Int64Var(&projectSeed, "seed", 0, "random seed, for repeatable results")
+	projectCmd.Flags().StringVar(&projectGrowth, "growth", "", "instead of simulating, compound the price at this yearly rate (e.g. 8%)")
+}
+
+// grownPrice is price compounded at rate a year from now until t.
+func grownPrice(price, rate float64, now, t time.Time) float64 {
+	return price * math.Pow(1+rate, yearsUntil(now, t))
+}
+
+// projectGrowthRate shows the upcoming vests and the value at the last vest
+// with the price compounding at rate.
+func projectGrowthRate(v valuation, rate float64, now time.Time) {
+	vests := v.upcomingVests(now)
+	endPrice := grownPrice(v.Price, rate, now, v.VestEnd)
+
+	if viper.GetString("output") == "json" {
+		type jsonVest struct {
+			Date   time.Time `json:"date"`
+			Grant  string    `json:"grant"`
+			Shares float64   `json:"shares"`
+			Price  float64   `json:"price"`
+			Value  float64   `json:"value"`
+		}
+		out := struct {
+			SchemaVersion int        `json:"schema_version"`
+			Hypothetical  bool       `json:"hypothetical"`
+			Ticker        string     `json:"ticker"`
+			Price         float64    `json:"price"`
+			Growth        float64    `json:"growth"`
+			Vests         []jsonVest `json:"vests"`
+			VestEnd       time.Time  `json:"vest_end"`
+			VestEndPrice  float64    `json:"vest_end_price"`
+			VestEndValue  float64    `json:"vest_end_value"`
+		}{SchemaVersion: schemaVersion, Hypothetical: true, Ticker: v.Ticker, Price: v.Price, Growth: rate, Vests: []jsonVest{},
+			VestEnd: v.VestEnd, VestEndPrice: endPrice, VestEndValue: heldValue(v, endPrice)}
+		for _, e := range vests {
+			price := grownPrice(v.Price, rate, now, e.Date)
+			out.Vests = append(out.Vests, jsonVest{Date: e.Date, Grant: e.Grant, Shares: e.Shares, Price: price,
+				Value: e.Shares * math.Max(price-e.StrikePrice, 0)})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(out)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	ac := v.money()
+	fmt.Printf("Hypothetically, if %s grew %.1f%% a year from %s:\n\n", v.Ticker, rate*100, ac.FormatMoney(v.Price))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Vest date\tGrant\tShares\tPrice\tValue\tCumulative\t")
+	total := 0.0
+	for _, e := range vests {
+		price := grownPrice(v.Price, rate, now, e.Date)
+		value := e.Shares * math.Max(price-e.StrikePrice, 0)
+		total += value
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", e.Date.Format("Jan 2, 2006"), e.Grant, formatShares(e.Shares),
+			ac.FormatMoney(price), ac.FormatMoney(value), ac.FormatMoney(total))
+	}
+	w.Flush()
+	fmt.Println()
+	fmt.Printf("At the last vest on %s the price would be %s, and everything you hold then worth %s (%s at today's price).\n",
+		v.VestEnd.Format("Jan 2, 2006"), ac.FormatMoney(endPrice), ac.FormatMoney(heldValue(v, endPrice)), ac.FormatMoney(heldValue(v, v.Price)))
+	fmt.Println("This is a hypothetical, not a forecast.")
+}
+
+// projection holds the inputs for simulating the price at vest-end.
+type projection struct {
+	Years      float64
+	Volatility float64
+	Estimated  bool
+	Window     string
+	Drift      float64
+	Yield      float64
+}
+
+// loadProjection works out the simulation inputs from the flags and config,
+// fetching a year of prices to estimate the volatility if it isn't given.
+func loadProjection(v valuation) (projection, error) {
+	p := projection{Years: yearsUntil(v.AsOf, v.VestEnd), Yield: v.DividendYield}
+	var err error
+	switch {
+	case projectVolatility != "":
+		p.Volatility, p.Window, err = volatilitySetting(v.Ticker, projectVolatility)
+	case viper.IsSet("volatility"):
+		p.Volatility, p.Window, err = volatilitySetting(v.Ticker, viper.Get("volatility"))
+	default:
+		p.Volatility, p.Window, err = volatilitySetting(v.Ticker, "1y")
+	}
+	p.Estimated = p.Window != ""
+	if err != nil {
+		return p, fmt.Errorf("volatility: %s", err)
+	}
+	if p.Volatility <= 0 {
+		return p, fmt.Errorf("volatility must be positive")
+	}
+
+	drift := projectDrift
+	if drift == "" {
+		drift = viper.GetString("risk-free-rate")
+	}
+	p.Drift, err = configPercent(drift)
+	if err != nil {
+		return p, fmt.Errorf("drift: %s", err)
+	}
+	return p, nil
+}
+
+// historicalVolatility annualizes the standard deviation of the daily log
+// returns, over 252 trading days a year.
+func historicalVolatility(prices []pricePoint) float64 {
+	var returns []float64
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1].Close > 0 && prices[i].Close > 0 {
+			returns = append(returns, math.Log(prices[i].Close/prices[i-1].Close))
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	variance /= float64(len(returns) - 1)
+	return math.Sqrt(variance * 252)
+}
+
+// outcomes are the results of a simulation: the price and value at each of
+// projectPercentiles, the mean value and the share of paths leaving some
+// options underwater.
+type outcomes struct {
+	Prices     []float64
+	Values     []float64
+	Mean       float64
+	Underwater float64
+}
+
+// simulate draws n prices at vest-end and values everything held by then
+// at each. The value rises with the price, so the value percentiles are
+// those of the price.
+func (p projection) simulate(v valuation, n int, r *rand.Rand) outcomes {
+	growth := (p.Drift - p.Yield - p.Volatility*p.Volatility/2) * p.Years
+	spread := p.Volatility * math.Sqrt(p.Years)
+	prices := make([]float64, n)
+	for i := range prices {
+		prices[i] = v.Price * math.Exp(growth+spread*r.NormFloat64())
+	}
+	sort.Float64s(prices)
+
+	var o outcomes
+	underwater := 0
+	for _, price := range prices {
+		o.Mean += heldValue(v, price)
+		for _, g := range v.Grants {
+			if g.isOption() && price < g.StrikePrice && g.SharesVestedUnsold+g.SharesUnvested > 0 {
+				underwater++
+				break
+			}
+		}
+	}
+	o.Mean /= float64(n)
+	o.Underwater = float64(underwater) / float64(n)
+	for _, pct := range projectPercentiles {
+		price := prices[int(math.Min(pct/100*float64(n), float64(n-1)))]
+		o.Prices = append(o.Prices, price)
+		o.Values = append(o.Values, heldValue(v, price))
+	}
+	return o
+}
+
+// heldValue is what the vested unsold and unvested shares are worth at
+// price, with underwater options worth nothing.
+func heldValue(v valuation, price float64) float64 {
+	value := 0.0
+	for _, g := range v.Grants {
+		per := price - g.StrikePrice
+		if g.isOption() {
+			per = math.Max(per, 0)
+		}
+		value += (g.SharesVestedUnsold + g.SharesUnvested) * per
+	}
+	return value
+}
+
+func writeProjectJSON(v valuation, p projection, o outcomes) error {
+	type jsonOutcome struct {
+		Percentile float64 `json:"percentile"`
+		Price      float64 `json:"price"`
+		Value      float64 `json:"value"`
+	}
+	out := struct {
+		SchemaVersion       int           `json:"schema_version"`
+		Ticker              string        `json:"ticker"`
+		Price               float64       `json:"price"`
+		VestEnd             time.Time     `json:"vest_end"`
+		Simulations         int           `json:"simulations"`
+		Volatility          float64       `json:"volatility"`
+		VolatilityEstimated bool          `json:"volatility_estimated"`
+		Drift               float64       `json:"drift"`
+		DividendYield       float64       `json:"dividend_yield"`
+		CurrentValue        float64       `json:"current_value"`
+		MeanValue           float64       `json:"mean_value"`
+		Underwater          float64       `json:"underwater_fraction"`
+		Outcomes            []jsonOutcome `json:"outcomes"`
+	}{
+		SchemaVersion:       schemaVersion,
+		Ticker:              v.Ticker,
+		Price:               v.Price,
+		VestEnd:             v.VestEnd,
+		Simulations:         projectSimulations,
+		Volatility:          p.Volatility,
+		VolatilityEstimated: p.Estimated,
+		Drift:               p.Drift,
+		DividendYield:       p.Yield,
+		CurrentValue:        heldValue(v, v.Price),
+		MeanValue:           o.Mean,
+		Underwater:          o.Underwater,
+	}
+	for i, pct := range projectPercentiles {
+		out.Outcomes = append(out.Outcomes, jsonOutcome{Percentile: pct, Price: o.Prices[i], Value: o.Values[i]})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
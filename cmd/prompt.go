@@ -0,0 +1,224 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var promptFormat string
+var promptTTL time.Duration
+var promptRefresh bool
+
+// promptCmd represents the prompt command
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a compact segment for shell prompts and status lines.",
+	Long: `Print a short, colourised summary suitable for starship, powerlevel10k or
+tmux status lines. The segment is always served from a local cache so the
+shell never waits on the network; when the cache is older than --ttl a
+refresh is started in the background.
+
+Formats: ansi (default), zsh, bash, tmux, plain.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := promptCachePath()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if promptRefresh {
+			err = refreshPromptCache(path)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		r, err := readPromptCache(path)
+		if err != nil || time.Since(r.GeneratedAt) > promptTTL {
+			startPromptRefresh(path)
+		}
+		if err == nil {
+			fmt.Print(promptSegment(r, promptFormat))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promptCmd)
+
+	promptCmd.Flags().StringVar(&promptFormat, "format", "ansi", "colour escaping: ansi, zsh, bash, tmux or plain")
+	promptCmd.Flags().DurationVar(&promptTTL, "ttl", 15*time.Minute, "refresh the cache in the background when older than this")
+	promptCmd.Flags().BoolVar(&promptRefresh, "refresh", false, "fetch a fresh quote and update the cache synchronously")
+}
+
+func promptCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	if profile != "" {
+		return filepath.Join(dir, "worth", "prompt-"+profile+".json"), nil
+	}
+	return filepath.Join(dir, "worth", "prompt.json"), nil
+}
+
+func readPromptCache(path string) (jsonReport, error) {
+	var r jsonReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+func refreshPromptCache(path string) error {
+	defer os.Remove(path + ".lock")
+
+	v, err := loadValuation(time.Now())
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	err = writeJSON(f, v)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// startPromptRefresh re-runs worth in the background to refresh the cache.
+// A lock file keeps many shells from all refreshing at once; a stale lock
+// left by a crashed refresh is ignored after a minute.
+func startPromptRefresh(path string) {
+	lock := path + ".lock"
+	if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) < time.Minute {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(lock, nil, 0600)
+	if err != nil {
+		return
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return
+	}
+	args := []string{"prompt", "--refresh", "--config", cfgFile}
+	if profile != "" {
+		args = []string{"prompt", "--refresh", "--profile", profile}
+		if baseConfigFile != "" {
+			args = append(args, "--config", baseConfigFile)
+		}
+	}
+	refresh := exec.Command(self, args...)
+	if refresh.Start() != nil {
+		os.Remove(lock)
+		return
+	}
+	refresh.Process.Release()
+}
+
+// promptSegment renders the cached report, e.g. "IBM $104.50 ▲3.2% $73.4k".
+func promptSegment(r jsonReport, format string) string {
+	arrow, color := "▲", "green"
+	if r.Change < 0 {
+		arrow, color = "▼", "red"
+	}
+	currency := r.Currency
+	if currency == "" {
+		// cached before reports carried their currency
+		currency = "USD"
+	}
+	return fmt.Sprintf("%s %s%.2f %s%s%.1f%%%s %s",
+		r.Ticker, currencySymbol(currency), r.Price,
+		promptColor(format, color), arrow, math.Abs(r.ChangePercent), promptColor(format, "reset"),
+		compactMoney(r.VestedValue, currency))
+}
+
+// promptColor returns the escape sequence to switch to color (or back to the
+// default with "reset"), wrapped the way each shell expects non-printing
+// characters to be marked.
+func promptColor(format, color string) string {
+	ansi := map[string]string{"green": "\x1b[32m", "red": "\x1b[31m", "reset": "\x1b[0m"}[color]
+	switch format {
+	case "zsh":
+		return "%{" + ansi + "%}"
+	case "bash":
+		return "\\[" + ansi + "\\]"
+	case "tmux":
+		if color == "reset" {
+			return "#[default]"
+		}
+		return "#[fg=" + color + "]"
+	case "plain":
+		return ""
+	default:
+		return ansi
+	}
+}
+
+// compactMoney abbreviates large amounts in currency, e.g. $148k or €1.2M.
+func compactMoney(amount float64, currency string) string {
+	sign := currencySymbol(currency)
+	if amount < 0 {
+		sign = "-" + sign
+		amount = -amount
+	}
+	switch {
+	case amount >= 1e9:
+		return fmt.Sprintf("%s%.1fB", sign, amount/1e9)
+	case amount >= 1e6:
+		return fmt.Sprintf("%s%.1fM", sign, amount/1e6)
+	case amount >= 1e4:
+		return fmt.Sprintf("%s%.0fk", sign, amount/1e3)
+	case amount >= 1e3:
+		return fmt.Sprintf("%s%.1fk", sign, amount/1e3)
+	default:
+		return fmt.Sprintf("%s%.0f", sign, amount)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/viper"
+)
+
+type JsonQuote struct {
+	GlobalQuote struct {
+		Symbol           string `json:"01. symbol"`
+		Open             string `json:"02. open"`
+		High             string `json:"03. high"`
+		Low              string `json:"04. low"`
+		Price            string `json:"05. price"`
+		Volume           string `json:"06. volume"`
+		LatestTradingDay string `json:"07. latest trading day"`
+		PreviousClose    string `json:"08. previous close"`
+		Change           string `json:"09. change"`
+		ChangePercent    string `json:"10. change percent"`
+	} `json:"Global Quote"`
+}
+
+// JsonOverview is the subset of the OVERVIEW (company fundamentals) response
+// that we use.
+type JsonOverview struct {
+	Symbol   string `json:"Symbol"`
+	Currency string `json:"Currency"`
+	YearHigh string `json:"52WeekHigh"`
+	YearLow  string `json:"52WeekLow"`
+	// DividendYield is the trailing annual dividend as a fraction of the
+	// price, or "None".
+	DividendYield string `json:"DividendYield"`
+}
+
+// JsonDaily is the TIME_SERIES_DAILY response, keyed by trading day.
+type JsonDaily struct {
+	TimeSeries map[string]struct {
+		Open   string `json:"1. open"`
+		High   string `json:"2. high"`
+		Low    string `json:"3. low"`
+		Close  string `json:"4. close"`
+		Volume string `json:"5. volume"`
+	} `json:"Time Series (Daily)"`
+}
+
+// JsonDividends is the DIVIDENDS response, newest first. Dates not yet
+// known are given as "None".
+type JsonDividends struct {
+	Data []struct {
+		ExDate  string `json:"ex_dividend_date"`
+		PayDate string `json:"payment_date"`
+		Amount  string `json:"amount"`
+	} `json:"data"`
+}
+
+// JsonExchangeRate is the CURRENCY_EXCHANGE_RATE response.
+type JsonExchangeRate struct {
+	Rate struct {
+		From          string `json:"1. From_Currency Code"`
+		To            string `json:"3. To_Currency Code"`
+		ExchangeRate  string `json:"5. Exchange Rate"`
+		LastRefreshed string `json:"6. Last Refreshed"`
+	} `json:"Realtime Currency Exchange Rate"`
+}
+
+// dividend is a dividend per share, owed to whoever holds the shares on
+// its ex-dividend date.
+type dividend struct {
+	ExDate  time.Time
+	PayDate time.Time
+	Amount  float64
+}
+
+// pricePoint is a closing price on a trading day.
+type pricePoint struct {
+	Date  time.Time
+	Close float64
+}
+
+// change returns the movement since the previous close, both in dollars and
+// as a percentage.
+func (q JsonQuote) change() (float64, float64, error) {
+	change, err := strconv.ParseFloat(q.GlobalQuote.Change, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(q.GlobalQuote.ChangePercent, "%"), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return change, percent, nil
+}
+
+// weekRange returns the 52-week low and high. Securities without
+// fundamentals (ETFs, for instance) report nothing, which yields zeros.
+func (o JsonOverview) weekRange() (float64, float64) {
+	low, err := strconv.ParseFloat(o.YearLow, 64)
+	if err != nil {
+		return 0, 0
+	}
+	high, err := strconv.ParseFloat(o.YearHigh, 64)
+	if err != nil {
+		return 0, 0
+	}
+	return low, high
+}
+
+// dividendYield returns the trailing dividend yield, or zero when the
+// company doesn't pay one.
+func (o JsonOverview) dividendYield() float64 {
+	yield, err := strconv.ParseFloat(o.DividendYield, 64)
+	if err != nil {
+		return 0
+	}
+	return yield
+}
+
+// query calls an AlphaVantage API function for symbol, if it takes one, and
+// decodes the response into out. Any extra parameters are added to the
+// request.
+func query(function, symbol string, extra map[string]string, out interface{}) error {
+	params := map[string]string{
+		"function": function,
+		"apikey":   viper.GetString("apikey"),
+	}
+	if symbol != "" {
+		params["symbol"] = normalizeSymbol(symbol)
+	}
+	for k, v := range extra {
+		params[k] = v
+	}
+
+	// resty.SetDebug(true)
+	client := resty.New()
+	resp, err := client.R().
+		SetQueryParams(params).
+		SetHeader("X-Requested-With", "Curl").
+		Get("https://www.alphavantage.co/query")
+	if err != nil {
+		return err
+	}
+	// resty.SetDebug(false)
+	return json.Unmarshal(resp.Body(), out)
+}
+
+func getQuote(symbol string) (JsonQuote, error) {
+	var quote JsonQuote
+	err := query("GLOBAL_QUOTE", symbol, nil, &quote)
+	return quote, err
+}
+
+func getOverview(symbol string) (JsonOverview, error) {
+	var overview JsonOverview
+	err := query("OVERVIEW", symbol, nil, &overview)
+	return overview, err
+}
+
+// getExchangeRate returns what one unit of from is worth in to. Pence
+// (GBX) are converted by way of pounds.
+func getExchangeRate(from, to string) (float64, error) {
+	switch {
+	case from == "GBX" && to == "GBP":
+		return 0.01, nil
+	case from == "GBP" && to == "GBX":
+		return 100, nil
+	case from == "GBX":
+		rate, err := getExchangeRate("GBP", to)
+		return rate / 100, err
+	case to == "GBX":
+		rate, err := getExchangeRate(from, "GBP")
+		return rate * 100, err
+	}
+	var rate JsonExchangeRate
+	err := query("CURRENCY_EXCHANGE_RATE", "", map[string]string{"from_currency": from, "to_currency": to}, &rate)
+	if err != nil {
+		return 0, err
+	}
+	r, err := strconv.ParseFloat(rate.Rate.ExchangeRate, 64)
+	if err != nil {
+		return 0, fmt.Errorf("no exchange rate from %s to %s", from, to)
+	}
+	return r, nil
+}
+
+// getDividends returns the symbol's dividend history, oldest first. A
+// dividend whose payment date isn't known yet is taken to be paid on its
+// ex-dividend date.
+func getDividends(symbol string) ([]dividend, error) {
+	var history JsonDividends
+	err := query("DIVIDENDS", symbol, nil, &history)
+	if err != nil {
+		return nil, err
+	}
+	var dividends []dividend
+	for _, d := range history.Data {
+		exDate, err := time.Parse("2006-01-02", d.ExDate)
+		if err != nil {
+			continue
+		}
+		amount, err := strconv.ParseFloat(d.Amount, 64)
+		if err != nil {
+			return nil, err
+		}
+		payDate, err := time.Parse("2006-01-02", d.PayDate)
+		if err != nil {
+			payDate = exDate
+		}
+		dividends = append(dividends, dividend{ExDate: exDate, PayDate: payDate, Amount: amount})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].ExDate.Before(dividends[j].ExDate) })
+	return dividends, nil
+}
+
+// getDailyPrices returns symbol's daily closing prices for the last days
+// calendar days, oldest first, adjusted for any splits since.
+func getDailyPrices(symbol string, days int) ([]pricePoint, error) {
+	closes, err := dailyCloses(symbol, days)
+	if err != nil {
+		return nil, err
+	}
+	splits, err := loadSplits(normalizeSymbol(symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	var points []pricePoint
+	for day, closing := range closes {
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, err
+		}
+		if date.Before(since) {
+			continue
+		}
+		points = append(points, pricePoint{Date: date, Close: closing / splitFactor(splits, vestDay(date))})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date.Before(points[j].Date) })
+	return points, nil
+}
@@ -0,0 +1,129 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var htmlFile string
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Generate a shareable report of your stock's value.",
+	Long: `Generate a self-contained static HTML page with the current valuation,
+a vesting progress bar and the upcoming vest dates.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if htmlFile == "" {
+			fmt.Println("report: --html is required")
+			os.Exit(1)
+		}
+
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		f, err := os.Create(htmlFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+
+		err = writeHTMLReport(f, v, v.upcomingVests(now), now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVar(&htmlFile, "html", "", "write an HTML report to this file")
+}
+
+func writeHTMLReport(w io.Writer, v valuation, vests []vestEvent, now time.Time) error {
+	ac := v.money()
+	funcs := template.FuncMap{
+		"money":  func(amount float64) string { return ac.FormatMoney(amount) },
+		"date":   func(t time.Time) string { return t.Format("Jan 2, 2006") },
+		"value":  func(e vestEvent) string { return ac.FormatMoney(e.Shares * (v.Price - e.StrikePrice)) },
+		"shares": formatShares,
+	}
+	t, err := template.New("report").Funcs(funcs).Parse(htmlReportTemplate)
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, struct {
+		V        valuation
+		Percent  int64
+		Vests    []vestEvent
+		Now      time.Time
+		Finished bool
+		Multi    bool
+	}{v, int64(v.PortionDone * 100), vests, now, v.PortionDone >= 1.0, len(v.Grants) > 1})
+}
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.V.Ticker}} equity report</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 40em; margin: 2em auto; color: #222; }
+h1 { font-size: 1.5em; }
+.bar { background: #eee; border-radius: 4px; height: 1.5em; overflow: hidden; }
+.bar div { background: #2a7; height: 100%; }
+table { border-collapse: collapse; width: 100%; margin-top: 1em; }
+th, td { text-align: right; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+th:first-child, td:first-child { text-align: left; }
+.muted { color: #888; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>{{.V.Ticker}} at {{money .V.Price}}</h1>
+<p>Total unsold shares are worth <strong>{{money .V.TotalValue}}</strong>.</p>
+<p>Vested and unsold: {{shares .V.SharesVestedUnsold}} shares ({{money .V.VestedValue}}).<br>
+Unvested: {{shares .V.SharesUnvested}} shares ({{money .V.UnvestedValue}}).</p>
+<h2>Vesting progress</h2>
+<div class="bar"><div style="width: {{.Percent}}%"></div></div>
+<p>{{.Percent}}% vested; fully vested on {{date .V.VestEnd}}.</p>
+{{if not .Finished}}<h2>Upcoming vest dates</h2>
+<table>
+<tr><th>Date</th>{{if .Multi}}<th>Grant</th>{{end}}<th>Shares</th><th>Value today</th></tr>
+{{range .Vests}}<tr><td>{{date .Date}}</td>{{if $.Multi}}<td>{{.Grant}}</td>{{end}}<td>{{shares .Shares}}</td><td>{{value .}}</td></tr>
+{{end}}</table>
+{{end}}<p class="muted">Generated {{date .Now}}.</p>
+</body>
+</html>
+`
@@ -0,0 +1,176 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// retention is how long the history keeps every snapshot, then the last of
+// each day, then the last of each week; beyond Weekly, only the last of each
+// month is kept. A zero time keeps that tier forever.
+type retention struct {
+	All    time.Time
+	Daily  time.Time
+	Weekly time.Time
+}
+
+// loadRetention reads the snapshot-retention setting as of now.
+func loadRetention(now time.Time) (retention, error) {
+	var raw struct {
+		All    string `mapstructure:"all"`
+		Daily  string `mapstructure:"daily"`
+		Weekly string `mapstructure:"weekly"`
+	}
+	err := viper.UnmarshalKey("snapshot-retention", &raw)
+	if err != nil {
+		return retention{}, fmt.Errorf("invalid snapshot-retention: %s", err)
+	}
+	if raw.All == "" {
+		raw.All = "7d"
+	}
+	if raw.Daily == "" {
+		raw.Daily = "1y"
+	}
+	var r retention
+	for _, tier := range []struct {
+		name, span string
+		cutoff     *time.Time
+	}{{"all", raw.All, &r.All}, {"daily", raw.Daily, &r.Daily}, {"weekly", raw.Weekly, &r.Weekly}} {
+		if tier.span == "" {
+			continue
+		}
+		years, months, days, err := parseSpan(tier.span)
+		if err != nil {
+			return retention{}, fmt.Errorf("snapshot-retention %s: %s", tier.name, err)
+		}
+		*tier.cutoff = now.AddDate(-years, -months, -days)
+	}
+	if r.Daily.After(r.All) || (!r.Weekly.IsZero() && r.Weekly.After(r.Daily)) {
+		return retention{}, fmt.Errorf("snapshot-retention: all, daily and weekly must each be at least as long as the one before")
+	}
+	return r, nil
+}
+
+// period is the day, week or month a snapshot falls in under the policy,
+// or if it is recent enough to keep regardless, its own time, so that only
+// copies of the same snapshot share one.
+func (r retention) period(t time.Time) string {
+	local := t.Local()
+	switch {
+	case !t.Before(r.All):
+		return t.UTC().Format(time.RFC3339Nano)
+	case !t.Before(r.Daily):
+		return local.Format("2006-01-02")
+	case r.Weekly.IsZero() || !t.Before(r.Weekly):
+		year, week := local.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return local.Format("2006-01")
+}
+
+// pruneSnapshots sorts the history and splits it into the snapshots the
+// policy keeps, the last of each position (or the portfolio) in each
+// period, and those it drops.
+func pruneSnapshots(snapshots []snapshot, r retention) (kept, dropped []snapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	for i, keep := range r.keep(snapshots) {
+		if keep {
+			kept = append(kept, snapshots[i])
+		} else {
+			dropped = append(dropped, snapshots[i])
+		}
+	}
+	return kept, dropped
+}
+
+// keep marks which of the snapshots, in time order, the policy keeps.
+func (r retention) keep(snapshots []snapshot) []bool {
+	last := map[string]int{}
+	for i, s := range snapshots {
+		last[fmt.Sprintf("%s\x00%s\x00%t\x00%s", s.Ticker, s.Position, s.Positions != nil, r.period(s.Time))] = i
+	}
+	keep := make([]bool, len(snapshots))
+	for _, i := range last {
+		keep[i] = true
+	}
+	return keep
+}
+
+// pruneLog applies the snapshot-retention policy to a history kept as JSON
+// lines in time order, as sync merges it. The lines kept are left as they
+// were, so they still match the other side's copies.
+func pruneLog(data []byte, now time.Time) ([]byte, error) {
+	r, err := loadRetention(now)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	snapshots, err := readSnapshots(strings.NewReader(strings.Join(lines, "\n")), "snapshots")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for i, keep := range r.keep(snapshots) {
+		if keep {
+			buf.WriteString(lines[i] + "\n")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// pruneHistory applies the snapshot-retention policy to the history,
+// rewriting it unless dryRun, and returns how many snapshots it kept and
+// dropped.
+func pruneHistory(now time.Time, dryRun bool) (int, int, error) {
+	r, err := loadRetention(now)
+	if err != nil {
+		return 0, 0, err
+	}
+	store, err := openSnapshotStore()
+	if err != nil {
+		return 0, 0, err
+	}
+	// prune what's recorded, not the history restated for splits
+	snapshots, err := store.load()
+	if err != nil {
+		return 0, 0, err
+	}
+	kept, dropped := pruneSnapshots(snapshots, r)
+	if len(dropped) > 0 && !dryRun {
+		err = store.replace(kept)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return len(kept), len(dropped), nil
+}
@@ -0,0 +1,96 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	r := retention{All: at("2026-06-01T00:00:00Z"), Daily: at("2026-03-01T00:00:00Z"), Weekly: at("2025-06-01T00:00:00Z")}
+
+	tests := []struct {
+		name      string
+		snapshots []snapshot
+		kept      int
+	}{
+		{"recent snapshots are all kept", []snapshot{
+			{Time: at("2026-06-10T10:00:00Z")},
+			{Time: at("2026-06-10T11:00:00Z")},
+			{Time: at("2026-06-10T12:00:00Z")},
+		}, 3},
+		{"copies of a recent snapshot collapse", []snapshot{
+			{Time: at("2026-06-10T10:00:00Z")},
+			{Time: at("2026-06-10T10:00:00Z")},
+		}, 1},
+		{"one a day", []snapshot{
+			{Time: at("2026-04-10T10:00:00Z")},
+			{Time: at("2026-04-10T14:00:00Z")},
+			{Time: at("2026-04-11T14:00:00Z")},
+		}, 2},
+		{"one a week", []snapshot{
+			{Time: at("2025-09-01T12:00:00Z")}, // Monday
+			{Time: at("2025-09-03T12:00:00Z")},
+			{Time: at("2025-09-07T12:00:00Z")}, // Sunday
+			{Time: at("2025-09-08T12:00:00Z")},
+		}, 2},
+		{"one a month", []snapshot{
+			{Time: at("2025-01-05T12:00:00Z")},
+			{Time: at("2025-01-25T12:00:00Z")},
+			{Time: at("2025-02-05T12:00:00Z")},
+		}, 2},
+		{"positions and tickers are kept apart", []snapshot{
+			{Time: at("2026-04-10T10:00:00Z"), Ticker: "IBM", Position: "work"},
+			{Time: at("2026-04-10T11:00:00Z"), Ticker: "IBM", Position: "work"},
+			{Time: at("2026-04-10T10:00:00Z"), Ticker: "IBM", Position: "old job"},
+			{Time: at("2026-04-10T10:00:00Z"), Ticker: "SAP.DEX", Position: "work"},
+		}, 3},
+		{"the portfolio is kept apart from its positions", []snapshot{
+			{Time: at("2026-04-10T10:00:00Z")},
+			{Time: at("2026-04-10T10:00:00Z"), Positions: []snapshot{{Position: "work"}}},
+			{Time: at("2026-04-10T11:00:00Z"), Positions: []snapshot{{Position: "work"}}},
+		}, 2},
+	}
+	for _, tt := range tests {
+		kept, dropped := pruneSnapshots(tt.snapshots, r)
+		if len(kept) != tt.kept || len(kept)+len(dropped) != len(tt.snapshots) {
+			t.Errorf("%s: kept %d and dropped %d, want %d kept of %d", tt.name, len(kept), len(dropped), tt.kept, len(tt.snapshots))
+		}
+	}
+
+	// the last snapshot of each period is the one kept, whatever order they
+	// come in
+	kept, _ := pruneSnapshots([]snapshot{
+		{Time: at("2026-04-10T14:00:00Z"), TotalValue: 2},
+		{Time: at("2026-04-10T10:00:00Z"), TotalValue: 1},
+	}, r)
+	if len(kept) != 1 || kept[0].TotalValue != 2 {
+		t.Errorf("kept %v, want only the later snapshot of the day", kept)
+	}
+}
@@ -22,16 +22,16 @@ package cmd
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
 	"math"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/leekchan/accounting"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -40,28 +40,29 @@ import (
 
 var cfgFile string
 var ticker string
-var shares int64
-var sharesSold int64
+var shares float64
+var sharesSold float64
 var strikePrice float64
 var startTime string
 var endTime string
-var vestStart time.Time
-var vestEnd time.Time
-
-type JsonQuote struct {
-	GlobalQuote struct {
-		Symbol           string `json:"01. symbol"`
-		Open             string `json:"02. open"`
-		High             string `json:"03. high"`
-		Low              string `json:"04. low"`
-		Price            string `json:"05. price"`
-		Volume           string `json:"06. volume"`
-		LatestTradingDay string `json:"07. latest trading day"`
-		PreviousClose    string `json:"08. previous close"`
-		Change           string `json:"09. change"`
-		ChangePercent    string `json:"10. change percent"`
-	} `json:"Global Quote"`
-}
+var vestDuration string
+var ifQuitOn string
+var terminatedOn string
+var assumeAcquisition bool
+var blackScholes bool
+var greeks bool
+var targetValue float64
+var tenderPrice float64
+var tenderCap string
+var atPrice float64
+var vestFrequency string
+var projectionYears int
+var vestLocation *time.Location
+var cliff string
+var output string
+var grantsFile string
+var emoji bool
+var sinceLastCheck bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -70,30 +71,159 @@ var rootCmd = &cobra.Command{
 	Long: `Find out the value of your stock, and figure out how much
 longer you have to wait until you're fully vested.
 Originally written in perl by Jamie Zawinski.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-		vestStart, err = time.Parse(time.RFC1123, viper.GetString("vest-start"))
-		if err != nil {
-			fmt.Println(err)
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if profile == allProfiles && cmd != cmd.Root() {
+			fmt.Printf("worth %s: --profile all only combines the summary; name a profile\n", cmd.Name())
 			os.Exit(1)
 		}
-		vestEnd, err = time.Parse(time.RFC1123, viper.GetString("vest-end"))
-		if err != nil {
-			fmt.Println(err)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		asOf := time.Now()
+		if profile == allProfiles {
+			if ifQuitOn != "" || terminatedOn != "" || targetValue > 0 || tenderPrice > 0 || viper.GetFloat64("at-price") > 0 || viper.GetBool("emoji") || viper.GetBool("assume-acquisition") {
+				fmt.Println("--profile all only combines the summary")
+				os.Exit(1)
+			}
+			portfolio, err := loadProfiles(asOf)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if viper.GetString("output") == "json" {
+				err = writePortfolioJSON(os.Stdout, portfolio)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				return
+			}
+			formatPortfolio(portfolio)
+			return
+		}
+		var lastDay time.Time
+		if ifQuitOn != "" && terminatedOn != "" {
+			fmt.Println("--if-quit-on and --terminated-on can't both be set")
 			os.Exit(1)
 		}
+		if ifQuitOn != "" || terminatedOn != "" {
+			flag, value := "--if-quit-on", ifQuitOn
+			if terminatedOn != "" {
+				flag, value = "--terminated-on", terminatedOn
+			}
+			var err error
+			lastDay, err = configDate(value)
+			if err != nil {
+				fmt.Printf("%s: %s\n", flag, err)
+				os.Exit(1)
+			}
+			// anything vesting on the last day itself is kept
+			asOf = lastDay.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
 
-		quote, err := getQuote()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+		// several tickers make a portfolio, summed up position by position;
+		// the other modes only look at the configured ticker
+		if lastDay.IsZero() && targetValue == 0 && tenderPrice == 0 && viper.GetFloat64("at-price") == 0 && !viper.GetBool("emoji") && !viper.GetBool("assume-acquisition") {
+			positions, err := loadPositions()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if len(positions) > 1 {
+				portfolio, err := loadPortfolio(asOf)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				last := recordSnapshot(portfolioSnapshot(portfolio, asOf))
+				if viper.GetString("output") == "json" {
+					err = writePortfolioJSON(os.Stdout, portfolio)
+					if err != nil {
+						fmt.Println(err)
+						os.Exit(1)
+					}
+					return
+				}
+				formatPortfolio(portfolio)
+				ac := accounting.Accounting{Symbol: currencySymbol(reportingCurrency(portfolio)), Precision: 2}
+				formatSinceLast(last, moneyFormat{stock: ac}, asOf)
+				return
+			}
 		}
-		val, err := strconv.ParseFloat(quote.GlobalQuote.Price, 64)
+
+		v, err := loadValuation(asOf)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-		formatOutput(cmd, val)
+		v.QuitOn = lastDay
+		// only today's actual value goes into the history
+		if lastDay.IsZero() && viper.GetFloat64("at-price") == 0 {
+			v.SinceLast = recordSnapshot(newSnapshot(v, asOf))
+			v.Trends, err = loadTrends(v, asOf)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "couldn't work out the trends: %s\n", err)
+			}
+		}
+		if terminatedOn != "" {
+			v.applyTermination(lastDay)
+		}
+		if targetValue > 0 {
+			fees, err := loadFees()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			v.applyTarget(fees, targetValue)
+		}
+		if tenderPrice > 0 {
+			cap := 1.0
+			if tenderCap != "" {
+				cap, err = configPercent(tenderCap)
+				if err != nil || cap <= 0 || cap > 1 {
+					fmt.Printf("--tender-cap: expected a percentage up to 100%%, got %q\n", tenderCap)
+					os.Exit(1)
+				}
+			}
+			v.applyTender(tenderPrice, cap)
+		}
+		if viper.GetString("output") == "json" {
+			err = writeJSON(os.Stdout, v)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if viper.GetBool("emoji") {
+			line, err := formatEmoji(v, viper.GetStringSlice("emoji-fields"))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Println(line)
+			return
+		}
+		if targetValue > 0 {
+			formatTarget(v)
+			return
+		}
+		if v.Tender != nil {
+			formatTender(v)
+			return
+		}
+		if viper.GetBool("assume-acquisition") {
+			formatAcquisition(v)
+			return
+		}
+		if !v.TerminatedOn.IsZero() {
+			formatTermination(v, time.Now())
+			return
+		}
+		if !v.QuitOn.IsZero() {
+			formatQuit(v)
+			return
+		}
+		formatOutput(cmd, v)
 	},
 }
 
@@ -106,43 +236,72 @@ func Execute() {
 	}
 }
 
-func getQuote() (JsonQuote, error) {
-	var quote JsonQuote
-	var err error
-	// resty.SetDebug(true)
-	client := resty.New()
-	resp, err := client.R().
-		SetQueryParams(map[string]string{
-			"function": "GLOBAL_QUOTE",
-			"symbol":   viper.GetString("ticker"),
-			"apikey":   viper.GetString("apikey"),
-		}).
-		SetHeader("X-Requested-With", "Curl").
-		Get("https://www.alphavantage.co/query")
-	if err != nil {
-		return quote, err
-	}
-	// resty.SetDebug(false)
-	jsn := resp.Body()
-	err = json.Unmarshal(jsn, &quote)
-
-	return quote, err
-}
-
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.config/worth/config.yaml)")
 	rootCmd.PersistentFlags().StringVar(&ticker, "ticker", "", "ticker symbol")
 	rootCmd.PersistentFlags().Float64Var(&strikePrice, "strike-price", 0.0, "strike price")
-	rootCmd.PersistentFlags().Int64Var(&shares, "shares", 1, "number of shares")
-	rootCmd.PersistentFlags().Int64Var(&sharesSold, "shares sold", 0, "number of shares sold")
-	rootCmd.PersistentFlags().StringVar(&startTime, "vest-start", "", "vesting start date (RFC3339)")
-	rootCmd.PersistentFlags().StringVar(&endTime, "vest-end", "", "vesting end date (RFC3339)")
+	rootCmd.PersistentFlags().Float64Var(&shares, "shares", 1, "number of shares")
+	rootCmd.PersistentFlags().Float64Var(&sharesSold, "shares sold", 0, "number of shares sold")
+	rootCmd.PersistentFlags().MarkDeprecated("shares sold", "record sales with worth sell record instead")
+	rootCmd.PersistentFlags().StringVar(&startTime, "vest-start", "", "vesting start date (e.g. 2020-03-01 or Mar 1 2020)")
+	rootCmd.PersistentFlags().StringVar(&endTime, "vest-end", "", "vesting end date (e.g. 2024-03-01 or Mar 1 2024)")
+	viper.BindPFlag("vest-start", rootCmd.PersistentFlags().Lookup("vest-start"))
+	viper.BindPFlag("vest-end", rootCmd.PersistentFlags().Lookup("vest-end"))
+	rootCmd.PersistentFlags().StringVar(&vestDuration, "vest-duration", "", "vesting period after vest-start (e.g. 4y or 48m), instead of vest-end")
+	viper.BindPFlag("vest-duration", rootCmd.PersistentFlags().Lookup("vest-duration"))
+	rootCmd.PersistentFlags().StringVar(&cliff, "cliff", "", "vesting cliff, as a span after vest-start (1y, 6m) or a date")
+	viper.BindPFlag("cliff", rootCmd.PersistentFlags().Lookup("cliff"))
+	rootCmd.PersistentFlags().StringVar(&vestFrequency, "vest-frequency", "", "vest on a schedule: monthly, quarterly or annual (default continuous)")
+	viper.BindPFlag("vest-frequency", rootCmd.PersistentFlags().Lookup("vest-frequency"))
+	rootCmd.PersistentFlags().IntVar(&projectionYears, "projection-years", 4, "years ahead to project refresher grants")
+	viper.SetDefault("whole-shares", true)
+	viper.SetDefault("ptep", "90d")
+	viper.SetDefault("option-term", "10y")
+	viper.SetDefault("expiration-warning", "1y")
+	viper.SetDefault("blackout-before", "14d")
+	viper.SetDefault("blackout-after", "2d")
+	viper.SetDefault("record-snapshots", true)
+	viper.SetDefault("since-last-check", true)
+	viper.SetDefault("cache-prices", true)
+	viper.BindPFlag("projection-years", rootCmd.PersistentFlags().Lookup("projection-years"))
+	rootCmd.PersistentFlags().StringVar(&grantsFile, "grants-file", "", "read the grants list from this YAML or JSON file instead of the config")
+	viper.BindPFlag("grants-file", rootCmd.PersistentFlags().Lookup("grants-file"))
+	rootCmd.PersistentFlags().Float64Var(&atPrice, "at-price", 0, "value everything at this hypothetical share price instead of the quote")
+	viper.BindPFlag("at-price", rootCmd.PersistentFlags().Lookup("at-price"))
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "use the named profile under profiles/ in the config directory, or all of them combined")
+	rootCmd.PersistentFlags().StringVar(&output, "output", "text", "output format (text or json)")
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	rootCmd.Flags().StringVar(&ifQuitOn, "if-quit-on", "", "show what you'd keep and forfeit if this date were your last day")
+	rootCmd.Flags().StringVar(&terminatedOn, "terminated-on", "", "your last day, to count down the window to exercise vested options")
+	rootCmd.Flags().BoolVar(&assumeAcquisition, "assume-acquisition", false, "show value under a change of control, applying acceleration terms")
+	viper.BindPFlag("assume-acquisition", rootCmd.Flags().Lookup("assume-acquisition"))
+	rootCmd.Flags().BoolVar(&blackScholes, "black-scholes", false, "value options with Black-Scholes rather than at their spread")
+	viper.BindPFlag("black-scholes", rootCmd.Flags().Lookup("black-scholes"))
+	rootCmd.Flags().Float64Var(&targetValue, "target-value", 0, "show the share price needed for your shares to be worth this after fees and taxes")
+	rootCmd.Flags().Float64Var(&tenderPrice, "tender-price", 0, "show what selling into a tender offer at this price would bring in")
+	rootCmd.Flags().StringVar(&tenderCap, "tender-cap", "", "with --tender-price, the most of your vested shares the offer buys, e.g. 20% (default all)")
+	rootCmd.Flags().BoolVar(&sinceLastCheck, "since-last-check", true, "say how much the value has changed since the last run (--since-last-check=false to leave it out)")
+	viper.BindPFlag("since-last-check", rootCmd.Flags().Lookup("since-last-check"))
+	rootCmd.Flags().BoolVar(&greeks, "greeks", false, "with --black-scholes, show the delta, theta and vega of your options")
+	viper.SetDefault("risk-free-rate", "4%")
+	rootCmd.Flags().BoolVar(&emoji, "emoji", false, "print a condensed emoji summary line")
+	rootCmd.Flags().StringSlice("emoji-fields", defaultEmojiFields, "fields for --emoji: "+strings.Join(emojiFieldNames, ", "))
+	viper.BindPFlag("emoji", rootCmd.Flags().Lookup("emoji"))
+	viper.BindPFlag("emoji-fields", rootCmd.Flags().Lookup("emoji-fields"))
 }
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	if profile != "" {
+		path, err := profileConfig(cfgFile, profile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		baseConfigFile, cfgFile = cfgFile, path
+	}
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -181,36 +340,297 @@ func initConfig() {
 	}
 }
 
-func formatOutput(cmd *cobra.Command, price float64) {
-	now := time.Now()
-	portionDone := float64(now.Unix()-vestStart.Unix()) / float64(vestEnd.Unix()-vestStart.Unix())
+func formatOutput(cmd *cobra.Command, v valuation) {
+	ac := v.money()
+
+	printExpiryWarnings(v)
+	printConcentrationWarning(v)
+	switch {
+	case v.QuotedPrice > 0:
+		fmt.Printf("Today's %s price is %s; at %s (%+.1f%%) ", v.Ticker, ac.FormatMoney(v.QuotedPrice), ac.FormatMoney(v.Price),
+			(v.Price-v.QuotedPrice)/v.QuotedPrice*100)
+		fmt.Printf("your total unsold shares would be worth %s.\n", ac.FormatMoney(v.TotalValue))
+	case v.Private != nil:
+		formatPrivate(v)
+	default:
+		fmt.Printf("Today's %s price is %s; ", v.Ticker, ac.FormatMoney(v.Price))
+		fmt.Printf("your total unsold shares are worth %s.\n", ac.FormatMoney(v.TotalValue))
+	}
+	formatSinceLast(v.SinceLast, ac, v.AsOf)
+	formatTrends(v, ac)
+	if v.HomeCurrency != "" {
+		fmt.Printf("Amounts in %s are shown in %s too, at %.4f %s per %s.\n", v.Currency, v.HomeCurrency, v.ExchangeRate, v.HomeCurrency, v.Currency)
+	}
+	if v.Private == nil && v.QuotedPrice == 0 {
+		formatMarket(v)
+	}
+	if len(v.Grants) > 1 {
+		fmt.Println()
+		printGrantTable(v)
+		fmt.Println()
+	}
+	for _, g := range v.Grants {
+		if g.Type == "psu" {
+			fmt.Printf("%s pays out %.0f%% of target (%s shares); at threshold (%.0f%%) %s, at target %s, at max (%.0f%%) %s.\n",
+				g.Name, g.Multiplier*100, formatShares(g.TargetShares), g.Threshold*100, ac.FormatMoney(g.valueAt(v.Price, g.Threshold)),
+				ac.FormatMoney(g.valueAt(v.Price, 1)), g.Maximum*100, ac.FormatMoney(g.valueAt(v.Price, g.Maximum)))
+		}
+	}
+	if v.Model != nil && v.OptionValue > 0 {
+		fmt.Printf("Valued with Black-Scholes (%.0f%% volatility, %.1f%% risk-free rate), your options are worth %s rather than %s at their spread.\n",
+			v.Model.Volatility*100, v.Model.Rate*100, ac.FormatMoney(v.OptionValue), ac.FormatMoney(v.OptionSpreadValue))
+		if greeks {
+			fmt.Printf("They move like %s shares (delta), lose %s a day to time decay (theta) and gain %s per point of volatility (vega).\n",
+				formatShares(math.Round(v.Delta)), ac.FormatMoney(0-v.Theta), ac.FormatMoney(v.Vega))
+		}
+	}
+	if len(v.Projected) > 0 {
+		fmt.Printf("Projected: %d upcoming refresher grants (%s shares) would be worth %s at %s.\n",
+			len(v.Projected), formatShares(v.ProjectedShares), ac.FormatMoney(v.ProjectedValue), v.priceName())
+	}
 
-	shares := viper.GetInt64("shares")
-	sharesVested := float64(shares) * portionDone
-	sharesUnvested := float64(shares) - sharesVested
-	sharesVestedAndUnsold := sharesVested - float64(sharesSold)
+	if v.PortionDone >= 1.0 {
+		fmt.Printf("You are 100%% vested.  Why are you still here?\n\n")
+		os.Exit(0)
+	}
 
-	ac := accounting.Accounting{Symbol: "$", Precision: 2}
+	if v.AsOf.Before(v.Cliff) {
+		fmt.Printf("You haven't reached your cliff yet: %s shares (%s) vest all at once on %s.\n",
+			formatShares(v.CliffShares), ac.FormatMoney(v.CliffShares*(v.Price-v.StrikePrice)), v.Cliff.Format("Jan 2, 2006"))
+	}
 
-	// subtract the strike price to get the take away value for your shares...
-	value := price - viper.GetFloat64("strike-price")
-	shareValue := float64(shares) * value
+	fmt.Printf("You are %d%% vested, for a total of ", int64(v.PortionDone*100))
+	fmt.Printf("%s vested unsold shares (%s)\n", formatShares(v.SharesVestedUnsold), ac.FormatMoney(v.VestedValue))
+	if v.Tax.Set && v.VestedValue > 0 {
+		fmt.Printf("After an estimated %s in taxes that's %s", ac.FormatMoney(v.VestedTax), ac.FormatMoney(v.VestedAfterTax))
+		if v.Tax.Supplemental > 0 {
+			fmt.Printf("; supplemental withholding covers %s of it", ac.FormatMoney(v.VestedWithheld))
+		}
+		fmt.Println()
+	}
+	if v.DividendIncome > 0 {
+		fmt.Printf("%s yields %.2f%%, about %s a year on your vested shares.\n", v.Ticker, v.DividendYield*100, ac.FormatMoney(v.DividendIncome))
+	}
+	if v.DividendsPaid+v.DividendsAccrued > 0 {
+		fmt.Printf("They've earned %s in dividends", ac.FormatMoney(v.DividendsPaid))
+		if v.DividendsAccrued > 0 {
+			fmt.Printf(" (and %s more declared but not yet paid)", ac.FormatMoney(v.DividendsAccrued))
+		}
+		if v.ReinvestedShares > 0 {
+			fmt.Printf("; reinvested, they bought %s shares now worth %s", formatShares(v.ReinvestedShares), ac.FormatMoney(v.ReinvestedValue))
+		}
+		fmt.Printf(", for a total return of %s\n", ac.FormatMoney(v.totalReturn()))
+	}
+	if !v.WindowOpens.IsZero() {
+		fmt.Printf("You're in a trading blackout, so none of them can be sold until the window opens on %s\n",
+			v.WindowOpens.Format("Jan 2, 2006"))
+	} else if !v.NextBlackout.IsZero() {
+		fmt.Printf("All of them can be sold today; the next trading blackout starts on %s\n", v.NextBlackout.Format("Jan 2, 2006"))
+	}
+	if v.VestingPerDay > 0 {
+		fmt.Printf("You earn about %s a day in equity (%s a week, %s a month) at %s\n", ac.FormatMoney(v.VestingPerDay),
+			ac.FormatMoney(v.VestingPerDay*7), ac.FormatMoney(v.VestingPerDay*daysPerYear/12), v.priceName())
+	}
+	fmt.Printf("But if you quit today, you will walk away from %s\n", ac.FormatMoney(v.UnvestedValue))
+	if v.Salary > 0 {
+		fmt.Printf("That's %.2fx your salary, or %.1f months of pay\n", v.UnvestedValue/v.Salary, v.salaryMonths())
+	}
+	fmt.Printf("Hang in there, little trooper! Only")
+	fmt.Printf("%s to go!\n", printRemaining(v.AsOf, v.VestEnd))
+}
 
-	fmt.Printf("Today's %s price is %s; ", viper.GetString("ticker"), ac.FormatMoney(price))
-	fmt.Printf("your total unsold shares are worth %s.\n", ac.FormatMoney(shareValue))
+// formatMarket prints how the stock moved and where it sits in its range.
+func formatMarket(v valuation) {
+	ac := v.money()
+	direction := "up"
+	if v.Change < 0 {
+		direction = "down"
+	}
+	if v.MarketOpen || v.TradingDay.IsZero() {
+		fmt.Printf("%s is %s %s (%.2f%%) today, ", v.Ticker, direction, ac.FormatMoney(math.Abs(v.Change)), math.Abs(v.ChangePercent))
+	} else {
+		fmt.Printf("%s closed %s %s (%.2f%%) on %s, ", v.Ticker, direction, ac.FormatMoney(math.Abs(v.Change)), math.Abs(v.ChangePercent),
+			v.TradingDay.Format("Mon Jan 2"))
+	}
+	fmt.Printf("which moved your vested shares by %s.\n", ac.FormatMoney(v.VestedChange))
+	if v.YearHigh > v.YearLow {
+		fmt.Printf("The 52-week range is %s - %s; today's price sits %d%% of the way up it.\n",
+			ac.FormatMoney(v.YearLow), ac.FormatMoney(v.YearHigh), int64(v.RangePosition*100))
+	}
+}
 
-	if portionDone >= 1.0 {
-		fmt.Printf("You are 100%% vested.  Why are you still here?\n\n")
-		os.Exit(0)
+// formatPrivate prints what a private company's shares are valued at, by its
+// 409A valuation and by the price investors last paid.
+func formatPrivate(v valuation) {
+	ac := v.money()
+	p := v.Private
+	fmt.Printf("%s's 409A valuation puts its common stock at %s", v.Ticker, ac.FormatMoney(p.FMV))
+	if !p.FMVDate.IsZero() {
+		fmt.Printf(" as of %s", p.FMVDate.Format("Jan 2, 2006"))
+	}
+	if p.DLOM > 0 {
+		fmt.Printf(", or %s after a %.0f%% discount for lack of marketability", ac.FormatMoney(v.Price), p.DLOM*100)
+	}
+	fmt.Printf("; your total unsold shares are worth %s.\n", ac.FormatMoney(v.TotalValue))
+	if p.isStale(v.AsOf) {
+		fmt.Println("That valuation is more than a year old; a new one may differ.")
 	}
+	if p.Preferred > 0 {
+		fmt.Printf("Investors paid %s a share for preferred stock", ac.FormatMoney(p.Preferred))
+		if !p.PreferredDate.IsZero() {
+			fmt.Printf(" on %s", p.PreferredDate.Format("Jan 2, 2006"))
+		}
+		fmt.Printf("; at that price your shares would be worth %s.\n", ac.FormatMoney(v.PreferredValue))
+	}
+	if v.Ownership > 0 {
+		fmt.Printf("You hold %.4f%% of its %s fully diluted shares", v.Ownership*100, formatShares(p.FullyDiluted))
+		if len(p.Financings) > 0 {
+			fmt.Printf(", or %.4f%% after the financings assumed", v.Ownership*p.retained()*100)
+		}
+		fmt.Println(".")
+	}
+	if len(p.Financings) > 0 {
+		fmt.Printf("Diluted %.1f%% by them, the common would be worth %s a share and your shares %s.\n",
+			(1-p.retained())*100, ac.FormatMoney(v.DilutedPrice), ac.FormatMoney(v.DilutedValue))
+	}
+}
 
-	diff := vestEnd.Sub(now)
-	secsToGo := roundTime(diff.Seconds())
-	fmt.Printf("You are %d%% vested, for a total of ", int64(portionDone*100))
-	fmt.Printf("%d vested unsold shares (%s)\n", int64(sharesVestedAndUnsold), ac.FormatMoney(sharesVestedAndUnsold*value))
-	fmt.Printf("But if you quit today, you will walk away from %s\n", ac.FormatMoney(sharesUnvested*value))
-	fmt.Printf("Hang in there, little trooper! Only")
-	fmt.Printf("%s to go!\n", printSecs(secsToGo))
+// printExpiryWarnings calls out vested, in-the-money options that expire
+// soon, in red on a terminal, since letting them lapse forfeits their value.
+func printExpiryWarnings(v valuation) {
+	ac := v.money()
+	color := isTerminal(os.Stdout)
+	for _, g := range v.Grants {
+		if !g.ExpiryWarning {
+			continue
+		}
+		var line string
+		if g.Expires.After(v.AsOf) {
+			line = fmt.Sprintf("WARNING: %s vested options in %s (%s) expire on %s, in%s. Exercise them before then!",
+				formatShares(g.SharesVestedUnsold), g.Name, ac.FormatMoney(g.VestedValue), g.Expires.Format("Jan 2, 2006"),
+				printRemaining(v.AsOf, g.Expires))
+		} else {
+			line = fmt.Sprintf("WARNING: %s vested options in %s (%s) expired unexercised on %s.",
+				formatShares(g.SharesVestedUnsold), g.Name, ac.FormatMoney(g.VestedValue), g.Expires.Format("Jan 2, 2006"))
+		}
+		if color {
+			line = "\x1b[1;31m" + line + "\x1b[0m"
+		}
+		fmt.Println(line)
+	}
+}
+
+// formatQuit describes what you would keep and forfeit if your last day were
+// v.QuitOn, valued at today's price.
+func formatQuit(v valuation) {
+	ac := v.money()
+
+	fmt.Printf("Today's %s price is %s.\n", v.Ticker, ac.FormatMoney(v.Price))
+	fmt.Printf("If your last day were %s:\n", v.QuitOn.Format("Mon Jan 2, 2006"))
+	if v.AsOf.Before(v.Cliff) {
+		fmt.Printf("  that's before your cliff on %s, so none of your shares would have vested.\n", v.Cliff.Format("Jan 2, 2006"))
+	}
+	fmt.Printf("  you would be %d%% vested and keep %s vested unsold shares (%s),\n",
+		int64(v.PortionDone*100), formatShares(v.SharesVestedUnsold), ac.FormatMoney(v.VestedValue))
+	fmt.Printf("  and forfeit %s unvested shares (%s).\n", formatShares(v.SharesUnvested), ac.FormatMoney(v.UnvestedValue))
+	if v.Salary > 0 {
+		fmt.Printf("  That's %.1f months of pay.\n", v.salaryMonths())
+	}
+	if len(v.Grants) > 1 {
+		fmt.Println()
+		printGrantTable(v)
+	}
+}
+
+// formatTermination counts down the post-termination exercise window of
+// each option grant after leaving on v.TerminatedOn, and the cash needed to
+// exercise the vested options in time.
+func formatTermination(v valuation, now time.Time) {
+	ac := v.money()
+
+	fmt.Printf("Today's %s price is %s.\n", v.Ticker, ac.FormatMoney(v.Price))
+	fmt.Printf("You left on %s with %s vested unsold shares (%s), forfeiting %s unvested shares.\n",
+		v.TerminatedOn.Format("Mon Jan 2, 2006"), formatShares(v.SharesVestedUnsold), ac.FormatMoney(v.VestedValue),
+		formatShares(v.SharesUnvested))
+	options, cost := false, 0.0
+	for _, g := range v.Grants {
+		if g.ExerciseDeadline.IsZero() || g.SharesVestedUnsold == 0 {
+			continue
+		}
+		options = true
+		// options can still be exercised on the deadline itself
+		if !g.ExerciseDeadline.AddDate(0, 0, 1).After(now) {
+			fmt.Printf("%s: the window to exercise %s options closed on %s.\n", g.Name,
+				formatShares(g.SharesVestedUnsold), g.ExerciseDeadline.Format("Jan 2, 2006"))
+			continue
+		}
+		fmt.Printf("%s: exercise %s options for %s by %s;%s left.\n", g.Name, formatShares(g.SharesVestedUnsold),
+			ac.FormatMoney(g.ExerciseCost), g.ExerciseDeadline.Format("Jan 2, 2006"), printRemaining(now, g.ExerciseDeadline))
+		cost += g.ExerciseCost
+	}
+	if !options {
+		fmt.Println("You have no vested options to exercise.")
+		return
+	}
+	if cost > 0 {
+		fmt.Printf("Exercising all of them in time would take %s in cash.\n", ac.FormatMoney(cost))
+	}
+}
+
+// formatAcquisition describes how acceleration clauses would play out if the
+// company were acquired, valued at today's price.
+func formatAcquisition(v valuation) {
+	ac := v.money()
+
+	when := "today"
+	if !v.QuitOn.IsZero() {
+		when = "on " + v.QuitOn.Format("Mon Jan 2, 2006")
+	}
+	fmt.Printf("Today's %s price is %s.\n", v.Ticker, ac.FormatMoney(v.Price))
+	fmt.Printf("If %s were acquired %s you would have %s vested unsold shares (%s)", v.Ticker, when,
+		formatShares(v.SharesVestedUnsold), ac.FormatMoney(v.VestedValue))
+	if v.AccelSingle == 0 && v.AccelDouble == 0 {
+		fmt.Printf(";\nnone of your grants have acceleration terms, so nothing would vest early.\n")
+		return
+	}
+	fmt.Printf(".\n")
+	fmt.Printf("Single-trigger acceleration would vest %s more shares (%s), for %s in total.\n",
+		formatShares(v.AccelSingle), ac.FormatMoney(v.AccelSingleValue), ac.FormatMoney(v.VestedValue+v.AccelSingleValue))
+	fmt.Printf("If you were also let go, double-trigger acceleration would vest a further %s shares (%s), for %s in total.\n",
+		formatShares(v.AccelDouble), ac.FormatMoney(v.AccelDoubleValue), ac.FormatMoney(v.VestedValue+v.AccelSingleValue+v.AccelDoubleValue))
+	remaining := v.SharesUnvested - v.AccelSingle - v.AccelDouble
+	if remaining > 0 {
+		fmt.Printf("Even then, %s shares (%s) would still be unvested.\n", formatShares(remaining),
+			ac.FormatMoney(v.UnvestedValue-v.AccelSingleValue-v.AccelDoubleValue))
+	}
+}
+
+// printGrantTable breaks the valuation down by grant, with combined totals.
+// ESPP grants never vest anything, so worth espp shows them instead.
+func printGrantTable(v valuation) {
+	ac := v.money()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Grant\tVested\tUnvested\tValue\tNext vest\t")
+	for _, g := range v.Grants {
+		if g.isESPP() {
+			continue
+		}
+		next := "-"
+		if !g.NextVest.Date.IsZero() {
+			next = fmt.Sprintf("%s (%s)", g.NextVest.Date.Format("Jan 2, 2006"), formatShares(g.NextVest.Shares))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", g.Name, formatShares(g.SharesVestedUnsold), formatShares(g.SharesUnvested),
+			ac.FormatMoney(g.VestedValue+g.UnvestedValue), next)
+	}
+	fmt.Fprintf(w, "Total\t%s\t%s\t%s\t\t\n", formatShares(v.SharesVestedUnsold), formatShares(v.SharesUnvested),
+		ac.FormatMoney(v.VestedValue+v.UnvestedValue))
+	w.Flush()
+}
+
+// formatShares prints a share count with up to four decimal places, leaving
+// whole numbers of shares without any.
+func formatShares(shares float64) string {
+	return strconv.FormatFloat(math.Round(shares*1e4)/1e4, 'f', -1, 64)
 }
 
 func roundTime(input float64) int64 {
@@ -228,38 +648,38 @@ func roundTime(input float64) int64 {
 	return int64(i)
 }
 
-func printSecs(secsToGo int64) string {
-	var buffer bytes.Buffer
-	var err error
+// calendarDiff breaks the time between the dates of from and to down into
+// whole calendar years, months and days, so month lengths and leap years are
+// honoured.
+func calendarDiff(from, to time.Time) (int, int, int) {
+	from, to = dateOnly(from.In(to.Location())), dateOnly(to)
+	if !to.After(from) {
+		return 0, 0, 0
+	}
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	for months > 0 && addMonths(from, months).After(to) {
+		months--
+	}
+	return months / 12, months % 12, calendarDays(addMonths(from, months), to)
+}
 
-	daysPerYear := 365
-	daysPerMonth := (daysPerYear / 12)
-	minToGo := int(secsToGo / 60)
-	hoursToGo := int(minToGo / 60)
-	daysToGo := int(hoursToGo / 24)
-	yearsToGo := int(daysToGo / daysPerYear)
-	monthsToGo := int(daysToGo / daysPerMonth)
+// calendarDays counts the days from the date of from to that of to, both
+// as of to's timezone, going by the calendar rather than the hours between
+// them, which are a day short or over across a daylight saving change.
+func calendarDays(from, to time.Time) int {
+	return int(dateOnly(to).Sub(dateOnly(from.In(to.Location()))).Hours() / 24)
+}
 
-	monthsToGo = monthsToGo - (yearsToGo * 12)
-	daysToGo = daysToGo - (yearsToGo * daysPerYear)
-	if monthsToGo < 0 {
-		monthsToGo = 0
-	}
+// dateOnly is t's date, as midnight UTC.
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
 
-	daysToGo = daysToGo - int(monthsToGo*daysPerMonth)
-	if daysToGo < 0 {
-		daysToGo = 0
-	}
+func printRemaining(from, to time.Time) string {
+	var buffer bytes.Buffer
+	var err error
 
-	// kludge to avoid "1 month 30 days", which, while correct, sucks.
-	if daysToGo > 29 {
-		daysToGo = daysToGo - 30
-		monthsToGo = monthsToGo + 1
-		if monthsToGo >= 12 {
-			monthsToGo = monthsToGo - 12
-			yearsToGo = yearsToGo + 1
-		}
-	}
+	yearsToGo, monthsToGo, daysToGo := calendarDiff(from, to)
 
 	if yearsToGo > 0 {
 		_, err = buffer.WriteString(fmt.Sprintf(" %d year", yearsToGo))
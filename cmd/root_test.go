@@ -0,0 +1,73 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarDiff(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name                string
+		from, to            time.Time
+		years, months, days int
+	}{
+		{"across the start of DST", time.Date(2026, 3, 7, 9, 0, 0, 0, ny), time.Date(2026, 3, 9, 0, 0, 0, 0, ny), 0, 0, 2},
+		{"across the end of DST", time.Date(2026, 10, 31, 23, 0, 0, 0, ny), time.Date(2026, 11, 2, 0, 0, 0, 0, ny), 0, 0, 2},
+		{"from the end of a month", time.Date(2025, 1, 31, 15, 0, 0, 0, time.UTC), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), 0, 1, 1},
+		{"years, months and days", time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC), 2, 3, 5},
+		{"later in the day", time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC), time.Date(2026, 1, 2, 1, 0, 0, 0, time.UTC), 0, 0, 1},
+		{"in the past", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 0, 0, 0},
+	}
+	for _, tt := range tests {
+		years, months, days := calendarDiff(tt.from, tt.to)
+		if years != tt.years || months != tt.months || days != tt.days {
+			t.Errorf("%s: calendarDiff = %d, %d, %d, want %d, %d, %d", tt.name, years, months, days, tt.years, tt.months, tt.days)
+		}
+	}
+}
+
+func TestCalendarDays(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"across the start of DST", time.Date(2026, 3, 1, 12, 0, 0, 0, ny), time.Date(2026, 3, 18, 0, 0, 0, 0, ny), 17},
+		{"same day", time.Date(2026, 3, 8, 1, 0, 0, 0, ny), time.Date(2026, 3, 8, 23, 0, 0, 0, ny), 0},
+		// from is read in to's time zone, where it is already the 2nd
+		{"in the vest's time zone", time.Date(2026, 1, 2, 1, 0, 0, 0, time.UTC), time.Date(2026, 1, 10, 0, 0, 0, 0, time.FixedZone("UTC+8", 8*3600)), 8},
+	}
+	for _, tt := range tests {
+		if got := calendarDays(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: calendarDays = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var scheduleProjected bool
+var schedulePast bool
+
+// scheduleCmd represents the schedule command
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "List every remaining vest event.",
+	Long: `List every remaining vest event with its date, the number of shares and
+their estimated value at today's price, to help plan around vest dates.
+
+With --past, list the vests so far too, each with the closing price on the
+day it vested and what the shares were worth then, and the closing price
+on each grant's grant date.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		events := v.upcomingVests(now)
+		if scheduleProjected {
+			var projected []grant
+			for _, p := range v.Projected {
+				projected = append(projected, p.grant)
+			}
+			events = append(events, upcomingVests(projected, now)...)
+			sortVests(events)
+		}
+
+		var past []pastVest
+		var granted []grantPrice
+		if schedulePast {
+			past, granted, err = pastVests(v, now)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
+
+		if viper.GetString("output") == "json" {
+			err = writeScheduleJSON(v, events, past, granted)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		ac := v.money()
+		if schedulePast {
+			formatPastVests(v, past, granted)
+		}
+		if len(events) == 0 {
+			fmt.Println("Nothing left to vest.")
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "Date\tGrant\tShares\tValue\tCumulative\t")
+		total := 0.0
+		for _, e := range events {
+			value := e.Shares * (v.Price - e.StrikePrice)
+			total += value
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", e.Date.Format("Jan 2, 2006"), e.Grant, formatShares(e.Shares),
+				ac.FormatMoney(value), ac.FormatMoney(total))
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+
+	scheduleCmd.Flags().BoolVar(&scheduleProjected, "projected", false, "include vests from projected refresher grants")
+	scheduleCmd.Flags().BoolVar(&schedulePast, "past", false, "also list past vests, valued at the closing price on the day")
+}
+
+// pastVest is a vest that has happened, with the closing price that day.
+// Close is 0 when the price history doesn't go back that far.
+type pastVest struct {
+	vestEvent
+	Close float64
+}
+
+// grantPrice is the closing price on a grant's grant date, 0 when unknown.
+type grantPrice struct {
+	Grant string
+	Date  time.Time
+	Close float64
+}
+
+// pastVests finds every vest up to now, and each grant's grant date, with
+// the closing prices on those days.
+func pastVests(v valuation, now time.Time) ([]pastVest, []grantPrice, error) {
+	if v.Private != nil {
+		return nil, nil, fmt.Errorf("schedule: there's no price history for a private company")
+	}
+	var since time.Time
+	for _, gv := range v.Grants {
+		if d := gv.grantDate(); !gv.isESPP() && d.Before(now) && (since.IsZero() || d.Before(since)) {
+			since = d
+		}
+	}
+	prices, err := pricesSince(v.Ticker, since, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	close := func(date time.Time) float64 {
+		if len(prices) == 0 || date.Before(prices[0].Date.AddDate(0, 0, -7)) {
+			return 0
+		}
+		return closeOn(prices, date)
+	}
+
+	var past []pastVest
+	var granted []grantPrice
+	for _, gv := range v.Grants {
+		// ESPP shares are bought, not vested; see worth espp
+		if gv.isESPP() || !gv.grantDate().Before(now) {
+			continue
+		}
+		granted = append(granted, grantPrice{Grant: gv.Name, Date: gv.grantDate(), Close: close(gv.grantDate())})
+		for _, e := range gv.pastVests(now) {
+			past = append(past, pastVest{vestEvent: e, Close: close(e.Date)})
+		}
+	}
+	sort.SliceStable(past, func(i, j int) bool { return past[i].Date.Before(past[j].Date) })
+	return past, granted, nil
+}
+
+// formatPastVests prints the grant-date prices and the vests so far.
+func formatPastVests(v valuation, past []pastVest, granted []grantPrice) {
+	ac := v.money()
+	price := func(p float64) string {
+		if p == 0 {
+			return "-"
+		}
+		return ac.FormatMoney(p)
+	}
+	for _, g := range granted {
+		if g.Close == 0 {
+			fmt.Printf("%s was granted on %s, before the price history starts.\n", g.Grant, g.Date.Format("Jan 2, 2006"))
+			continue
+		}
+		fmt.Printf("%s was granted on %s, when %s closed at %s.\n", g.Grant, g.Date.Format("Jan 2, 2006"), v.Ticker, ac.FormatMoney(g.Close))
+	}
+	if len(past) == 0 {
+		fmt.Println()
+		return
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Vested\tGrant\tShares\tClose\tValue then\tValue now\t")
+	for _, e := range past {
+		then := "-"
+		if e.Close > 0 {
+			then = ac.FormatMoney(e.Shares * (e.Close - e.StrikePrice))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", e.Date.Format("Jan 2, 2006"), e.Grant, formatShares(e.Shares),
+			price(e.Close), then, ac.FormatMoney(e.Shares*(v.Price-e.StrikePrice)))
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+// jsonVest is one vest event in the schedule's JSON output. Past vests
+// also give the closing price on the day and the value then.
+type jsonVest struct {
+	Date      time.Time `json:"date"`
+	Grant     string    `json:"grant"`
+	Shares    float64   `json:"shares"`
+	Value     float64   `json:"value"`
+	Close     float64   `json:"close,omitempty"`
+	ValueThen float64   `json:"value_then,omitempty"`
+}
+
+// jsonGrantPrice is the closing price on a grant's grant date.
+type jsonGrantPrice struct {
+	Grant string    `json:"grant"`
+	Date  time.Time `json:"date"`
+	Close float64   `json:"close,omitempty"`
+}
+
+func writeScheduleJSON(v valuation, events []vestEvent, past []pastVest, granted []grantPrice) error {
+	out := struct {
+		SchemaVersion int              `json:"schema_version"`
+		Ticker        string           `json:"ticker"`
+		Price         float64          `json:"price"`
+		Granted       []jsonGrantPrice `json:"granted,omitempty"`
+		Past          []jsonVest       `json:"past,omitempty"`
+		Vests         []jsonVest       `json:"vests"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Vests: []jsonVest{}}
+	for _, g := range granted {
+		out.Granted = append(out.Granted, jsonGrantPrice{Grant: g.Grant, Date: g.Date, Close: g.Close})
+	}
+	for _, e := range past {
+		j := jsonVest{Date: e.Date, Grant: e.Grant, Shares: e.Shares, Value: e.Shares * (v.Price - e.StrikePrice), Close: e.Close}
+		if e.Close > 0 {
+			j.ValueThen = e.Shares * (e.Close - e.StrikePrice)
+		}
+		out.Past = append(out.Past, j)
+	}
+	for _, e := range events {
+		out.Vests = append(out.Vests, jsonVest{
+			Date:   e.Date,
+			Grant:  e.Grant,
+			Shares: e.Shares,
+			Value:  e.Shares * (v.Price - e.StrikePrice),
+		})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
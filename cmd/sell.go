@@ -0,0 +1,673 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var sellShares float64
+var sellLots string
+var sellCash float64
+
+var recordShares float64
+var recordPrice float64
+var recordFees float64
+var recordDate string
+var recordLot string
+var recordGrant string
+
+// sellCmd represents the sell command
+var sellCmd = &cobra.Command{
+	Use:   "sell",
+	Short: "Show what you'd actually get for selling your shares.",
+	Long: `Show what selling your vested shares today would bring in after the
+brokerage commission, SEC fee and wire fee set under fees in the config.
+With --shares, sell only that many, taking them from your grants in the
+order they're configured. Vested options are exercised and sold together,
+so the proceeds are net of their strike price.
+
+With --lots, sell shares you hold from particular lots (see worth lots):
+fifo takes the oldest first, lifo the newest, hifo those with the highest
+cost basis, mintax those costing the least tax per share (losses first),
+or list lot IDs to sell from those. Each lot's gain is shown, and with tax settings the estimated tax
+under each method is compared.
+
+With --cash, find how many shares each method has to sell to leave that
+much after fees and estimated tax, and which lots the cheapest one sells.
+
+Lots sold at a loss within 30 days of other shares vesting or being
+acquired are flagged as possible wash sales.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		v, err := loadValuation(now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fees, err := loadFees()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if sellCash > 0 {
+			sellForCash(v, fees, now)
+			return
+		}
+		if sellLots != "" {
+			sellFromLots(v, fees, now)
+			return
+		}
+
+		// vested options are exercised and sold at once, so their strike
+		// price comes out of the proceeds
+		shares, gross := 0.0, 0.0
+		for _, g := range v.Grants {
+			n := g.SharesVestedUnsold
+			if sellShares > 0 {
+				n = math.Min(n, sellShares-shares)
+			}
+			if n <= 0 {
+				continue
+			}
+			shares += n
+			gross += n * (v.Price - g.StrikePrice)
+		}
+		items := fees.itemize(shares, v.Price)
+		total := fees.on(shares, v.Price)
+
+		if viper.GetString("output") == "json" {
+			err = writeSellJSON(v, shares, gross, items, total)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if shares <= 0 {
+			fmt.Println("You have no vested shares to sell.")
+			return
+		}
+
+		ac := v.money()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(w, "%s shares at %s\t%s\t\n", formatShares(shares), ac.FormatMoney(v.Price), ac.FormatMoney(gross))
+		for _, item := range items {
+			fmt.Fprintf(w, "%s\t-%s\t\n", item.Name, ac.FormatMoney(item.Amount))
+		}
+		fmt.Fprintf(w, "Net proceeds\t%s\t\n", ac.FormatMoney(gross-total))
+		w.Flush()
+		if sellShares > shares {
+			fmt.Printf("You only have %s vested shares, so that's all of them.\n", formatShares(shares))
+		}
+	},
+}
+
+// sellRecordCmd represents the sell record command
+var sellRecordCmd = &cobra.Command{
+	Use:   "record",
+	Short: "Record a sale in your ledger.",
+	Long: `Record an actual sale of shares in the ledger (ledger.jsonl beside the
+config file, or the ledger setting). Every other command counts the
+ledger's sales as sold, from the lot or grant given or else from the oldest
+shares. Fees default to those set under fees in the config. A sale at a
+loss within 30 days of other shares vesting or being acquired is flagged as
+a possible wash sale.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if recordShares <= 0 || recordPrice <= 0 {
+			fmt.Println("sell record: --shares and --price are required")
+			os.Exit(1)
+		}
+		if recordLot != "" && recordGrant != "" {
+			fmt.Println("sell record: --lot and --grant can't both be given")
+			os.Exit(1)
+		}
+		// today's date, anchored in the vest timezone the same way as one
+		// given with --date
+		err := loadTimezone()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		now := time.Now()
+		r := saleRecord{
+			Date:   vestDay(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)),
+			Shares: recordShares,
+			Price:  recordPrice,
+			Fees:   recordFees,
+			Lot:    recordLot,
+			Grant:  recordGrant,
+		}
+		if recordDate != "" {
+			date, err := parseDate(recordDate)
+			if err != nil {
+				fmt.Printf("sell record: --date: %s\n", err)
+				os.Exit(1)
+			}
+			r.Date = vestDay(date)
+		}
+		if !cmd.Flags().Changed("fees") {
+			fees, err := loadFees()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			r.Fees = fees.on(r.Shares, r.Price)
+		}
+
+		err = checkSaleRecord(&r)
+		if err != nil {
+			fmt.Printf("sell record: %s\n", err)
+			os.Exit(1)
+		}
+		washes, err := recordedWashSales(r, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		path, err := appendLedger(r)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		ac := configuredMoney()
+		fmt.Printf("Recorded selling %s shares at %s on %s in %s.\n", formatShares(r.Shares), ac.FormatMoney(r.Price),
+			r.Date.Format("Jan 2, 2006"), path)
+		formatWashSales(os.Stdout, washes, ac)
+	},
+}
+
+// recordedWashSales checks a sale about to be recorded for possible wash
+// sales, taking its shares from the lot it names or else the oldest held,
+// as every command will once it's in the ledger.
+func recordedWashSales(r saleRecord, now time.Time) ([]washSale, error) {
+	v, err := loadValuation(r.Date)
+	if err != nil {
+		return nil, err
+	}
+	lots, _, err := loadLots(v, r.Date)
+	if err != nil {
+		return nil, err
+	}
+	method := "fifo"
+	if r.Lot != "" {
+		method = r.Lot
+	}
+	sold, err := selectLots(lots, r.Shares, method, v, r.Date)
+	if err != nil {
+		return nil, err
+	}
+	acquired, err := acquisitions(v, now)
+	if err != nil {
+		return nil, err
+	}
+	return findWashSales(sold, r.Price, r.Date, acquired), nil
+}
+
+// checkSaleRecord checks the grant or lot a sale names exists, the lot
+// holding enough shares on the sale date, and records the lot's grant.
+func checkSaleRecord(r *saleRecord) error {
+	if r.Grant != "" {
+		grants, err := loadGrants()
+		if err != nil {
+			return err
+		}
+		for _, g := range grants {
+			if g.Name == r.Grant {
+				return nil
+			}
+		}
+		return fmt.Errorf("no grant named %q", r.Grant)
+	}
+	if r.Lot == "" {
+		return nil
+	}
+
+	v, err := loadValuation(r.Date)
+	if err != nil {
+		return err
+	}
+	lots, _, err := loadLots(v, r.Date)
+	if err != nil {
+		return err
+	}
+	for _, l := range lots {
+		if l.ID == r.Lot {
+			if r.Shares > l.Shares+1e-9 {
+				return fmt.Errorf("lot %s only holds %s shares", l.ID, formatShares(l.Shares))
+			}
+			r.Grant = l.Grant
+			return nil
+		}
+	}
+	return fmt.Errorf("no lot %q held on %s; see worth lots", r.Lot, r.Date.Format("Jan 2, 2006"))
+}
+
+func init() {
+	rootCmd.AddCommand(sellCmd)
+	sellCmd.AddCommand(sellRecordCmd)
+
+	sellCmd.Flags().Float64Var(&sellShares, "shares", 0, "sell only this many vested shares (default all)")
+	sellCmd.Flags().StringVar(&sellLots, "lots", "", "sell held shares by lot: fifo, lifo, hifo, mintax or lot IDs")
+	sellCmd.Flags().Float64Var(&sellCash, "cash", 0, "find the lots to sell to raise this much after fees and tax")
+
+	sellRecordCmd.Flags().Float64Var(&recordShares, "shares", 0, "number of shares sold")
+	sellRecordCmd.Flags().Float64Var(&recordPrice, "price", 0, "sale price per share")
+	sellRecordCmd.Flags().Float64Var(&recordFees, "fees", 0, "total fees paid on the sale (default from the fees settings)")
+	sellRecordCmd.Flags().StringVar(&recordDate, "date", "", "date of the sale (default today)")
+	sellRecordCmd.Flags().StringVar(&recordLot, "lot", "", "lot the shares came from (see worth lots)")
+	sellRecordCmd.Flags().StringVar(&recordGrant, "grant", "", "grant the shares came from")
+}
+
+// lotSale is the outcome of selling shares from lots by one method.
+type lotSale struct {
+	Method    string
+	Lots      []lot
+	Shares    float64
+	ShortTerm float64
+	LongTerm  float64
+	Tax       float64
+}
+
+func newLotSale(lots []lot, shares float64, method string, v valuation, now time.Time) (lotSale, error) {
+	picked, err := selectLots(lots, shares, method, v, now)
+	if err != nil {
+		return lotSale{}, err
+	}
+	s := lotSale{Method: method, Lots: picked}
+	for _, l := range picked {
+		s.Shares += l.Shares
+	}
+	s.ShortTerm, s.LongTerm = termGains(picked, v.Price, now)
+	if v.Tax.Set {
+		s.Tax = v.Tax.gainsTax(s.ShortTerm, s.LongTerm)
+	}
+	return s, nil
+}
+
+// afterTax is what a sale leaves after fees and estimated tax.
+func (s lotSale) afterTax(v valuation, fees saleFees) float64 {
+	return s.Shares*v.Price - fees.on(s.Shares, v.Price) - s.Tax
+}
+
+// saleForCash finds the fewest whole shares (or all of them) to sell from
+// lots by method to leave cash after fees and tax. What a sale leaves grows
+// with every share added, so a binary search finds it. The result is false
+// if even selling everything falls short.
+func saleForCash(lots []lot, method string, cash float64, v valuation, fees saleFees, now time.Time) (lotSale, bool) {
+	held := 0.0
+	for _, l := range lots {
+		held += l.Shares
+	}
+	all, _ := newLotSale(lots, held, method, v, now)
+	if all.afterTax(v, fees) < cash {
+		return all, false
+	}
+	low, high := 0.0, math.Ceil(held)
+	for high-low > 1 {
+		mid := math.Floor((low + high) / 2)
+		s, _ := newLotSale(lots, mid, method, v, now)
+		if s.afterTax(v, fees) >= cash {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	s, _ := newLotSale(lots, math.Min(high, held), method, v, now)
+	return s, true
+}
+
+// sellForCash compares how each lot method raises sellCash, and shows the
+// lots the one costing the least tax would sell.
+func sellForCash(v valuation, fees saleFees, now time.Time) {
+	lots, _, err := loadLots(v, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var sales []lotSale
+	best := -1
+	for _, method := range lotMethods {
+		s, ok := saleForCash(lots, method, sellCash, v, fees, now)
+		if !ok {
+			continue
+		}
+		sales = append(sales, s)
+		// mintax wins ties, being the method that's meant to
+		if best < 0 || s.Tax < sales[best].Tax-0.005 || (method == "mintax" && s.Tax <= sales[best].Tax+0.005) {
+			best = len(sales) - 1
+		}
+	}
+
+	if viper.GetString("output") == "json" {
+		err = writeCashSaleJSON(v, fees, sales, best, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	ac := v.money()
+	if len(sales) == 0 {
+		fmt.Printf("Selling all the shares you hold wouldn't raise %s after fees and tax.\n", ac.FormatMoney(sellCash))
+		return
+	}
+
+	fmt.Printf("To raise %s after fees and estimated tax:\n", ac.FormatMoney(sellCash))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Method\tShares\tProceeds\tFees\tEstimated tax\tAfter tax\t")
+	for _, s := range sales {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", s.Method, formatShares(s.Shares), ac.FormatMoney(s.Shares*v.Price),
+			ac.FormatMoney(fees.on(s.Shares, v.Price)), ac.FormatMoney(s.Tax), ac.FormatMoney(s.afterTax(v, fees)))
+	}
+	w.Flush()
+
+	fmt.Println()
+	fmt.Printf("%s costs the least tax, selling:\n", sales[best].Method)
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Lot\tShares\tBasis\tGain\tTerm\t")
+	for _, l := range sales[best].Lots {
+		term := "short"
+		if l.longTerm(now) {
+			term = "long"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", l.ID, formatShares(l.Shares), ac.FormatMoney(l.Basis), ac.FormatMoney(l.gain(v.Price)), term)
+	}
+	w.Flush()
+	acquired, err := acquisitions(v, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	formatWashSales(os.Stdout, findWashSales(sales[best].Lots, v.Price, now, acquired), v.money())
+	if !v.Tax.Set {
+		fmt.Println("Without tax settings, no tax is estimated; add them to compare methods.")
+	}
+}
+
+func writeCashSaleJSON(v valuation, fees saleFees, sales []lotSale, best int, now time.Time) error {
+	type jsonPick struct {
+		ID     string  `json:"id"`
+		Shares float64 `json:"shares"`
+	}
+	type jsonAlternative struct {
+		Method   string     `json:"method"`
+		Shares   float64    `json:"shares"`
+		Fees     float64    `json:"fees"`
+		Tax      float64    `json:"tax"`
+		AfterTax float64    `json:"after_tax"`
+		Best     bool       `json:"best,omitempty"`
+		Lots     []jsonPick `json:"lots"`
+	}
+	out := struct {
+		SchemaVersion int               `json:"schema_version"`
+		Ticker        string            `json:"ticker"`
+		Price         float64           `json:"price"`
+		Cash          float64           `json:"cash"`
+		Alternatives  []jsonAlternative `json:"alternatives"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Cash: sellCash, Alternatives: []jsonAlternative{}}
+	for i, s := range sales {
+		a := jsonAlternative{Method: s.Method, Shares: s.Shares, Fees: fees.on(s.Shares, v.Price), Tax: s.Tax,
+			AfterTax: s.afterTax(v, fees), Best: i == best, Lots: []jsonPick{}}
+		for _, l := range s.Lots {
+			a.Lots = append(a.Lots, jsonPick{ID: l.ID, Shares: l.Shares})
+		}
+		out.Alternatives = append(out.Alternatives, a)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// sellFromLots models selling held shares from lots chosen by sellLots, and
+// compares it with the standard methods.
+func sellFromLots(v valuation, fees saleFees, now time.Time) {
+	lots, _, err := loadLots(v, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	held := 0.0
+	for _, l := range lots {
+		held += l.Shares
+	}
+	shares := held
+	if sellShares > 0 {
+		shares = math.Min(sellShares, held)
+	}
+
+	chosen, err := newLotSale(lots, shares, sellLots, v, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	sales := []lotSale{chosen}
+	standard := false
+	for _, method := range lotMethods {
+		if method == sellLots {
+			standard = true
+			continue
+		}
+		s, _ := newLotSale(lots, chosen.Shares, method, v, now)
+		sales = append(sales, s)
+	}
+	if !standard {
+		sales[0].Method = "selected"
+	}
+	acquired, err := acquisitions(v, now)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	washes := findWashSales(chosen.Lots, v.Price, now, acquired)
+	items := fees.itemize(chosen.Shares, v.Price)
+	gross := chosen.Shares * v.Price
+	net := gross - fees.on(chosen.Shares, v.Price)
+
+	if viper.GetString("output") == "json" {
+		err = writeLotSaleJSON(v, sales, washes, gross, items, net, now)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	if chosen.Shares <= 0 {
+		fmt.Println("You don't hold any shares to sell.")
+		return
+	}
+
+	ac := v.money()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Lot\tAcquired\tShares\tBasis\tProceeds\tGain\tTerm\t")
+	for _, l := range chosen.Lots {
+		term := "short"
+		if l.longTerm(now) {
+			term = "long"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", l.ID, l.Acquired.Format("Jan 2, 2006"), formatShares(l.Shares),
+			ac.FormatMoney(l.Basis), ac.FormatMoney(l.Shares*v.Price), ac.FormatMoney(l.gain(v.Price)), term)
+	}
+	w.Flush()
+	formatWashSales(os.Stdout, washes, v.money())
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s shares at %s\t%s\t\n", formatShares(chosen.Shares), ac.FormatMoney(v.Price), ac.FormatMoney(gross))
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t-%s\t\n", item.Name, ac.FormatMoney(item.Amount))
+	}
+	fmt.Fprintf(w, "Net proceeds\t%s\t\n", ac.FormatMoney(net))
+	w.Flush()
+	if sellShares > held {
+		fmt.Printf("You only hold %s shares, so that's all of them.\n", formatShares(held))
+	}
+	if !v.Tax.Set {
+		return
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Method\tShort-term gain\tLong-term gain\tEstimated tax\tAfter tax\t")
+	for _, s := range sales {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", s.Method, ac.FormatMoney(s.ShortTerm), ac.FormatMoney(s.LongTerm),
+			ac.FormatMoney(s.Tax), ac.FormatMoney(net-s.Tax))
+	}
+	w.Flush()
+}
+
+// saleFees are the costs of selling shares through a brokerage.
+type saleFees struct {
+	Commission float64
+	PerShare   float64
+	SECRate    float64
+	Wire       float64
+}
+
+// feeItem is one fee charged on a sale.
+type feeItem struct {
+	Name   string
+	Amount float64
+}
+
+// loadFees reads the fee settings: a commission per trade and per share,
+// the SEC fee as a fraction of the proceeds, and a fee to wire the cash out.
+func loadFees() (saleFees, error) {
+	f := saleFees{
+		Commission: viper.GetFloat64("fees.commission"),
+		PerShare:   viper.GetFloat64("fees.commission-per-share"),
+		SECRate:    viper.GetFloat64("fees.sec-fee-rate"),
+		Wire:       viper.GetFloat64("fees.wire"),
+	}
+	if f.Commission < 0 || f.PerShare < 0 || f.SECRate < 0 || f.Wire < 0 {
+		return f, fmt.Errorf("fees can't be negative")
+	}
+	return f, nil
+}
+
+// itemize lists the fees charged on one sale of shares at price. Fees that
+// aren't configured are left out.
+func (f saleFees) itemize(shares, price float64) []feeItem {
+	if shares <= 0 {
+		return nil
+	}
+	var items []feeItem
+	if commission := f.Commission + f.PerShare*shares; commission > 0 {
+		items = append(items, feeItem{"Commission", commission})
+	}
+	if f.SECRate > 0 {
+		// the SEC fee is rounded up to the next cent
+		items = append(items, feeItem{"SEC fee", math.Ceil(shares*price*f.SECRate*100) / 100})
+	}
+	if f.Wire > 0 {
+		items = append(items, feeItem{"Wire fee", f.Wire})
+	}
+	return items
+}
+
+// on is the total of the fees on one sale of shares at price.
+func (f saleFees) on(shares, price float64) float64 {
+	total := 0.0
+	for _, item := range f.itemize(shares, price) {
+		total += item.Amount
+	}
+	return total
+}
+
+func writeLotSaleJSON(v valuation, sales []lotSale, washes []washSale, gross float64, items []feeItem, net float64, now time.Time) error {
+	type jsonSold struct {
+		ID       string  `json:"id"`
+		Shares   float64 `json:"shares"`
+		Basis    float64 `json:"basis"`
+		Gain     float64 `json:"gain"`
+		LongTerm bool    `json:"long_term"`
+		// the loss a wash sale may disallow
+		WashSale float64 `json:"wash_sale_disallowed,omitempty"`
+	}
+	type jsonMethod struct {
+		Method        string   `json:"method"`
+		ShortTermGain float64  `json:"short_term_gain"`
+		LongTermGain  float64  `json:"long_term_gain"`
+		Tax           *float64 `json:"tax,omitempty"`
+	}
+	chosen := sales[0]
+	out := struct {
+		SchemaVersion int          `json:"schema_version"`
+		Ticker        string       `json:"ticker"`
+		Price         float64      `json:"price"`
+		Shares        float64      `json:"shares"`
+		Gross         float64      `json:"gross"`
+		Fees          float64      `json:"fees"`
+		Net           float64      `json:"net"`
+		Lots          []jsonSold   `json:"lots"`
+		Methods       []jsonMethod `json:"methods"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Shares: chosen.Shares, Gross: gross, Fees: gross - net, Net: net,
+		Lots: []jsonSold{}}
+	for _, l := range chosen.Lots {
+		sold := jsonSold{ID: l.ID, Shares: l.Shares, Basis: l.Basis, Gain: l.gain(v.Price), LongTerm: l.longTerm(now)}
+		for _, ws := range washes {
+			if ws.Lot == l.ID {
+				sold.WashSale = ws.Disallowed
+			}
+		}
+		out.Lots = append(out.Lots, sold)
+	}
+	for _, s := range sales {
+		m := jsonMethod{Method: s.Method, ShortTermGain: s.ShortTerm, LongTermGain: s.LongTerm}
+		if v.Tax.Set {
+			tax := s.Tax
+			m.Tax = &tax
+		}
+		out.Methods = append(out.Methods, m)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func writeSellJSON(v valuation, shares, gross float64, items []feeItem, fees float64) error {
+	type jsonFee struct {
+		Name   string  `json:"name"`
+		Amount float64 `json:"amount"`
+	}
+	out := struct {
+		SchemaVersion int       `json:"schema_version"`
+		Ticker        string    `json:"ticker"`
+		Price         float64   `json:"price"`
+		Shares        float64   `json:"shares"`
+		Gross         float64   `json:"gross"`
+		Fees          []jsonFee `json:"fees"`
+		Net           float64   `json:"net"`
+	}{SchemaVersion: schemaVersion, Ticker: v.Ticker, Price: v.Price, Shares: shares, Gross: gross, Fees: []jsonFee{}, Net: gross - fees}
+	for _, item := range items {
+		out.Fees = append(out.Fees, jsonFee(item))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,56 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import "testing"
+
+func TestSaleForCash(t *testing.T) {
+	now := mustDate("2026-06-01")
+	lots := []lot{
+		{ID: "A", Acquired: mustDate("2024-01-15"), Shares: 100, Basis: 50},
+		{ID: "B", Acquired: mustDate("2025-01-15"), Shares: 100, Basis: 120},
+	}
+	v := valuation{Price: 100}
+
+	tests := []struct {
+		name   string
+		cash   float64
+		fees   saleFees
+		shares float64
+		ok     bool
+	}{
+		{"exact", 5000, saleFees{}, 50, true},
+		{"rounds up to a whole share", 5001, saleFees{}, 51, true},
+		{"covers a commission", 5000, saleFees{Commission: 10}, 51, true},
+		{"covers a per-share fee", 990, saleFees{PerShare: 1}, 10, true},
+		{"covers a wire fee", 4975, saleFees{Wire: 25}, 50, true},
+		{"into the second lot", 15000, saleFees{}, 150, true},
+		{"everything", 20000, saleFees{}, 200, true},
+		{"not enough", 20001, saleFees{}, 200, false},
+		{"not enough after fees", 20000, saleFees{Commission: 5}, 200, false},
+	}
+	for _, tt := range tests {
+		s, ok := saleForCash(lots, "fifo", tt.cash, v, tt.fees, now)
+		if ok != tt.ok || s.Shares != tt.shares {
+			t.Errorf("%s: saleForCash(%v) = %v shares, %t, want %v, %t", tt.name, tt.cash, s.Shares, ok, tt.shares, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leekchan/accounting"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var snapshotAt string
+var snapshotDaemonize bool
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Record a snapshot in the history, now or every trading day.",
+	Long: `Record a snapshot of what your shares are worth in the snapshot
+history, whether or not record-snapshots is on.
+
+With --at, keep running and record one each trading day at that local time,
+given as 16:30 or as a cron spec such as "30 16 * * 1-5" (minute, hour, day
+of month, month and day of week); weekends, and NYSE holidays for stocks
+listed in New York, are skipped either way. --daemonize does this in the
+background, writing what it does to a log beside the history. With
+snapshot-retention set, each run also prunes the history as worth history
+prune does.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if snapshotAt == "" {
+			if snapshotDaemonize {
+				fmt.Println("snapshot: --daemonize needs --at")
+				os.Exit(1)
+			}
+			line, err := takeSnapshot(time.Now())
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			fmt.Println(line)
+			return
+		}
+		sched, err := parseSchedule(snapshotAt)
+		if err != nil {
+			fmt.Printf("snapshot: --at: %s\n", err)
+			os.Exit(1)
+		}
+		if snapshotDaemonize {
+			pid, log, err := daemonizeSnapshots()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			when := fmt.Sprintf("on trading days per %q", snapshotAt)
+			if _, err := time.Parse("15:04", strings.TrimSpace(snapshotAt)); err == nil {
+				when = "each trading day at " + strings.TrimSpace(snapshotAt)
+			}
+			fmt.Printf("Recording a snapshot %s in the background (pid %d), logging to %s.\n", when, pid, log)
+			return
+		}
+		if _, ok := sched.next(time.Now()); !ok {
+			fmt.Printf("snapshot: --at %q never falls on a trading day\n", snapshotAt)
+			os.Exit(1)
+		}
+		for {
+			next, _ := sched.next(time.Now())
+			fmt.Printf("%s next snapshot at %s\n", time.Now().Format("2006-01-02 15:04"), next.Format("Mon Jan 2 15:04"))
+			time.Sleep(time.Until(next))
+			// pick up any changes to the config since the last one
+			if viper.ConfigFileUsed() != "" {
+				err = viper.ReadInConfig()
+				if err != nil {
+					fmt.Printf("%s %s\n", time.Now().Format("2006-01-02 15:04"), err)
+					continue
+				}
+			}
+			line, err := takeSnapshot(time.Now())
+			if err != nil {
+				line = err.Error()
+			} else if viper.IsSet("snapshot-retention") {
+				if _, dropped, err := pruneHistory(time.Now(), false); err != nil {
+					line += " " + err.Error()
+				} else if dropped > 0 {
+					line += fmt.Sprintf(" Pruned %d old snapshots.", dropped)
+				}
+			}
+			fmt.Printf("%s %s\n", time.Now().Format("2006-01-02 15:04"), line)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.Flags().StringVar(&snapshotAt, "at", "", "record one every trading day at this time (16:30) or cron spec (\"30 16 * * 1-5\")")
+	snapshotCmd.Flags().BoolVar(&snapshotDaemonize, "daemonize", false, "with --at, run in the background")
+}
+
+// takeSnapshot values the shares, or the whole portfolio, and adds a
+// snapshot to the history, describing what it recorded.
+func takeSnapshot(now time.Time) (string, error) {
+	positions, err := loadPositions()
+	if err != nil {
+		return "", err
+	}
+	var s snapshot
+	if len(positions) > 1 {
+		portfolio, err := loadPortfolio(now)
+		if err != nil {
+			return "", err
+		}
+		s = portfolioSnapshot(portfolio, now)
+	} else {
+		v, err := loadValuation(now)
+		if err != nil {
+			return "", err
+		}
+		s = newSnapshot(v, now)
+	}
+	err = appendSnapshot(s)
+	if err != nil {
+		return "", err
+	}
+	ac := accounting.Accounting{Symbol: currencySymbol(s.Currency), Precision: 2}
+	return fmt.Sprintf("Recorded a snapshot: %s vested, %s in all.", ac.FormatMoney(s.VestedValue), ac.FormatMoney(s.TotalValue)), nil
+}
+
+// daemonizeSnapshots re-runs this command without --daemonize in the
+// background, its output appended to snapshots.log beside the history.
+func daemonizeSnapshots() (int, string, error) {
+	path, err := snapshotsPath()
+	if err != nil {
+		return 0, "", err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return 0, "", err
+	}
+	log := strings.TrimSuffix(path, filepath.Ext(path)) + ".log"
+	f, err := os.OpenFile(log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	self, err := os.Executable()
+	if err != nil {
+		return 0, "", err
+	}
+	var args []string
+	for _, a := range os.Args[1:] {
+		if a != "--daemonize" && !strings.HasPrefix(a, "--daemonize=") {
+			args = append(args, a)
+		}
+	}
+	daemon := exec.Command(self, args...)
+	daemon.Stdout, daemon.Stderr = f, f
+	err = daemon.Start()
+	if err != nil {
+		return 0, "", err
+	}
+	pid := daemon.Process.Pid
+	return pid, log, daemon.Process.Release()
+}
+
+// schedule is when to take snapshots: the minutes, hours, days of the
+// month, months and days of the week of a cron spec, each a set of the
+// values allowed. Only trading days are used, whatever the spec says.
+type schedule struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	// anyDay and anyWeekday record a * for the day of the month or of the
+	// week; when both are restricted, cron runs on days matching either.
+	anyDay, anyWeekday bool
+	trading            *businessCalendar
+}
+
+// parseSchedule reads a time of day, e.g. 16:30, or a five-field cron spec.
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if t, err := time.Parse("15:04", spec); err == nil {
+		spec = fmt.Sprintf("%d %d * * *", t.Minute(), t.Hour())
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return schedule{}, fmt.Errorf("expected a time like 16:30 or a cron spec like \"30 16 * * 1-5\", got %q", spec)
+	}
+	ranges := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	var sets [5]map[int]bool
+	for i, f := range fields {
+		set, err := parseCronField(f, ranges[i][0], ranges[i][1])
+		if err != nil {
+			return schedule{}, fmt.Errorf("%q: %s", f, err)
+		}
+		sets[i] = set
+	}
+	// 7 is Sunday too
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+	s := schedule{minutes: sets[0], hours: sets[1], days: sets[2], months: sets[3], weekdays: sets[4],
+		anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+	s.trading = &businessCalendar{holidays: map[string]bool{}, years: map[int]bool{},
+		nyse: symbolExchange(configuredTicker()).Name == "NYSE"}
+	return s, nil
+}
+
+// parseCronField reads one cron field: *, a value or a range, each
+// optionally with a /step, or a comma-separated list of them.
+func parseCronField(field string, lo, hi int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			step, part = n, part[:i]
+		}
+		from, to := lo, hi
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			to = from
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return nil, fmt.Errorf("out of range %d-%d", lo, hi)
+		}
+		for n := from; n <= to; n += step {
+			set[n] = true
+		}
+	}
+	return set, nil
+}
+
+// matches reports whether a snapshot is due in the minute starting at t.
+func (s schedule) matches(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+	day, weekday := s.days[t.Day()], s.weekdays[int(t.Weekday())]
+	switch {
+	case s.anyDay && s.anyWeekday:
+	case s.anyDay:
+		if !weekday {
+			return false
+		}
+	case s.anyWeekday:
+		if !day {
+			return false
+		}
+	default:
+		if !day && !weekday {
+			return false
+		}
+	}
+	return s.trading.isBusinessDay(t)
+}
+
+// next returns the first minute after t a snapshot is due, looking up to a
+// year ahead; false if there's none that soon.
+func (s schedule) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(1, 0, 0); t.Before(end); t = t.Add(time.Minute) {
+		if s.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
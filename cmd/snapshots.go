@@ -0,0 +1,240 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// snapshot is what one run found, kept in the snapshot history. A
+// portfolio's snapshot is totalled in its reporting currency and lists its
+// positions, without a ticker or price of its own.
+type snapshot struct {
+	Time           time.Time  `json:"time"`
+	Position       string     `json:"position,omitempty"`
+	Ticker         string     `json:"ticker,omitempty"`
+	Currency       string     `json:"currency"`
+	Price          float64    `json:"price,omitempty"`
+	SharesVested   float64    `json:"shares_vested_unsold,omitempty"`
+	SharesUnvested float64    `json:"shares_unvested,omitempty"`
+	VestedValue    float64    `json:"vested_value"`
+	UnvestedValue  float64    `json:"unvested_value"`
+	TotalValue     float64    `json:"total_value"`
+	Positions      []snapshot `json:"positions,omitempty"`
+}
+
+func newSnapshot(v valuation, now time.Time) snapshot {
+	return snapshot{Time: now.UTC(), Position: v.Position, Ticker: v.Ticker, Currency: v.Currency, Price: v.Price,
+		SharesVested: v.SharesVestedUnsold, SharesUnvested: v.SharesUnvested, VestedValue: v.VestedValue,
+		UnvestedValue: v.UnvestedValue, TotalValue: v.TotalValue}
+}
+
+func portfolioSnapshot(portfolio []valuation, now time.Time) snapshot {
+	s := snapshot{Time: now.UTC(), Currency: reportingCurrency(portfolio)}
+	for _, v := range portfolio {
+		s.VestedValue += v.reported(v.VestedValue, s.Currency)
+		s.UnvestedValue += v.reported(v.UnvestedValue, s.Currency)
+		s.Positions = append(s.Positions, newSnapshot(v, now))
+	}
+	s.TotalValue = s.VestedValue + s.UnvestedValue
+	return s
+}
+
+// snapshotsPath returns where a local snapshot history lives: the
+// snapshots setting, or beside the config file, snapshots.jsonl or, in
+// SQLite, snapshots.db.
+func snapshotsPath() (string, error) {
+	if path := viper.GetString("snapshots"); path != "" {
+		return homedir.Expand(path)
+	}
+	name := "snapshots.jsonl"
+	if viper.GetString("snapshot-store") == "sqlite" {
+		name = "snapshots.db"
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return filepath.Join(filepath.Dir(used), name), nil
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "worth", name), nil
+}
+
+// sinceLast is how much the total value has changed since the previous
+// snapshot of the same position, taken At.
+type sinceLast struct {
+	Change float64
+	At     time.Time
+}
+
+// recordSnapshot adds a snapshot to the end of the history, unless
+// record-snapshots is turned off, and returns the change since the last one
+// like it; nil if there's none or since-last-check is turned off. A failure
+// is only reported on stderr, so that it doesn't get in the way of the run's
+// own output.
+func recordSnapshot(s snapshot) *sinceLast {
+	var last *sinceLast
+	if viper.GetBool("since-last-check") {
+		snapshots, err := loadSnapshots()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't read the snapshot history: %s\n", err)
+		}
+		for i := len(snapshots) - 1; i >= 0; i-- {
+			p := snapshots[i]
+			if p.Ticker == s.Ticker && p.Position == s.Position && (p.Positions == nil) == (s.Positions == nil) {
+				if p.Currency == s.Currency {
+					last = &sinceLast{Change: s.TotalValue - p.TotalValue, At: p.Time}
+				}
+				break
+			}
+		}
+	}
+	if viper.GetBool("record-snapshots") {
+		err := appendSnapshot(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "couldn't record a snapshot: %s\n", err)
+		}
+	}
+	return last
+}
+
+// formatSinceLast prints the change since the last check, e.g. "Up $3,420.00
+// since you last checked (3 days ago)."
+func formatSinceLast(last *sinceLast, ac moneyFormat, now time.Time) {
+	if last == nil {
+		return
+	}
+	direction := "Up"
+	if last.Change < 0 {
+		direction = "Down"
+	}
+	if math.Abs(last.Change) < 0.005 {
+		fmt.Printf("Unchanged since you last checked (%s).\n", ago(now.Sub(last.At)))
+		return
+	}
+	fmt.Printf("%s %s since you last checked (%s).\n", direction, ac.FormatMoney(math.Abs(last.Change)), ago(now.Sub(last.At)))
+}
+
+// ago describes how long ago something was, roughly.
+func ago(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d.Minutes()), "minute")
+	case d < 48*time.Hour:
+		return plural(int(d.Hours()), "hour")
+	}
+	return plural(int(d.Hours()/24), "day")
+}
+
+func appendSnapshot(s snapshot) error {
+	store, err := openSnapshotStore()
+	if err != nil {
+		return err
+	}
+	return store.append(s)
+}
+
+// loadSnapshots reads the snapshot history, oldest first, restating the
+// shares and price of each configured position's snapshots taken before a
+// split of its stock in today's shares. A missing history has no
+// snapshots.
+func loadSnapshots() ([]snapshot, error) {
+	store, err := openSnapshotStore()
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := loadPositions()
+	if err != nil {
+		return nil, err
+	}
+	splits := map[string][]split{}
+	for _, p := range positions {
+		if p.Ticker == "" {
+			continue
+		}
+		splits[p.Ticker], err = loadSplits(p.Ticker)
+		if err != nil {
+			return nil, err
+		}
+	}
+	restate := func(s *snapshot, t time.Time) {
+		tickerSplits, ok := splits[s.Ticker]
+		if !ok {
+			return
+		}
+		factor := splitFactor(tickerSplits, t)
+		s.SharesVested *= factor
+		s.SharesUnvested *= factor
+		s.Price /= factor
+	}
+	for i := range snapshots {
+		s := &snapshots[i]
+		restate(s, s.Time)
+		for j := range s.Positions {
+			restate(&s.Positions[j], s.Time)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// snapshotOn finds the last snapshot taken of position on the day of t,
+// whether on its own or as part of a portfolio.
+func snapshotOn(snapshots []snapshot, v valuation, t time.Time) (snapshot, bool) {
+	day := t.Format("2006-01-02")
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		s := snapshots[i]
+		if s.Time.Local().Format("2006-01-02") != day {
+			continue
+		}
+		if s.Ticker == v.Ticker && s.Position == v.Position {
+			return s, true
+		}
+		for _, p := range s.Positions {
+			if p.Ticker == v.Ticker && p.Position == v.Position {
+				p.Time = s.Time
+				return p, true
+			}
+		}
+	}
+	return snapshot{}, false
+}
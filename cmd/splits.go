@@ -0,0 +1,196 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// split is a stock split taking effect on Date, when each share became
+// Ratio shares (a reverse split has a ratio below one).
+type split struct {
+	Date  time.Time
+	Ratio float64
+}
+
+// splitConfig is an entry in the splits list, a split in the configured
+// ticker's stock unless it names another.
+type splitConfig struct {
+	Ticker string      `mapstructure:"ticker"`
+	Date   interface{} `mapstructure:"date"`
+	Ratio  interface{} `mapstructure:"ratio"`
+}
+
+// JsonDailyAdjusted is the part of the TIME_SERIES_DAILY_ADJUSTED response
+// we need to find splits.
+type JsonDailyAdjusted struct {
+	TimeSeries map[string]struct {
+		SplitCoefficient string `json:"8. split coefficient"`
+	} `json:"Time Series (Daily)"`
+}
+
+// detectedSplits caches the splits found in each ticker's adjusted series,
+// which is only fetched once a run.
+var detectedSplits = map[string][]split{}
+
+// loadSplits returns the splits in ticker's stock listed in the config,
+// oldest first. With detect-splits set, splits in the provider's adjusted
+// price series for the ticker are added too, unless one is already listed
+// for that day.
+func loadSplits(ticker string) ([]split, error) {
+	var raw []splitConfig
+	err := viper.UnmarshalKey("splits", &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid splits: %s", err)
+	}
+
+	var splits []split
+	listed := map[string]bool{}
+	primary := normalizeSymbol(viper.GetString("ticker"))
+	for i, r := range raw {
+		date, err := configDate(r.Date)
+		if err != nil {
+			return nil, fmt.Errorf("split %d: %s", i+1, err)
+		}
+		ratio, err := splitRatio(r.Ratio)
+		if err != nil {
+			return nil, fmt.Errorf("split %d: %s", i+1, err)
+		}
+		symbol := normalizeSymbol(r.Ticker)
+		if symbol == "" {
+			symbol = primary
+		}
+		if symbol != ticker {
+			continue
+		}
+		splits = append(splits, split{Date: date, Ratio: ratio})
+		listed[date.Format("2006-01-02")] = true
+	}
+
+	if viper.GetBool("detect-splits") && ticker != "" {
+		detected, ok := detectedSplits[ticker]
+		if !ok {
+			detected, err = detectSplits(ticker)
+			if err != nil {
+				return nil, err
+			}
+			detectedSplits[ticker] = detected
+		}
+		for _, s := range detected {
+			if !listed[s.Date.Format("2006-01-02")] {
+				splits = append(splits, s)
+			}
+		}
+	}
+	sort.Slice(splits, func(i, j int) bool { return splits[i].Date.Before(splits[j].Date) })
+	return splits, nil
+}
+
+// splitRatio reads a split ratio written as a number of new shares per old
+// share (10), or as new:old (10:1, 3:2, or 1:10 for a reverse split).
+func splitRatio(v interface{}) (float64, error) {
+	var ratio float64
+	switch r := v.(type) {
+	case int:
+		ratio = float64(r)
+	case float64:
+		ratio = r
+	case string:
+		parts := strings.SplitN(r, ":", 2)
+		n, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ratio %q: expected e.g. 10:1", r)
+		}
+		ratio = n
+		if len(parts) == 2 {
+			d, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+			if err != nil || d == 0 {
+				return 0, fmt.Errorf("invalid ratio %q: expected e.g. 10:1", r)
+			}
+			ratio = n / d
+		}
+	default:
+		return 0, fmt.Errorf("invalid ratio %v", v)
+	}
+	if ratio <= 0 {
+		return 0, fmt.Errorf("ratio must be positive")
+	}
+	return ratio, nil
+}
+
+// detectSplits finds the splits in symbol's adjusted daily series: the
+// days with a split coefficient other than one.
+func detectSplits(symbol string) ([]split, error) {
+	var daily JsonDailyAdjusted
+	err := query("TIME_SERIES_DAILY_ADJUSTED", symbol, map[string]string{"outputsize": "full"}, &daily)
+	if err != nil {
+		return nil, err
+	}
+	if len(daily.TimeSeries) == 0 {
+		return nil, fmt.Errorf("detect-splits: no adjusted prices returned for %s", symbol)
+	}
+	var splits []split
+	for day, bar := range daily.TimeSeries {
+		ratio, err := strconv.ParseFloat(bar.SplitCoefficient, 64)
+		if err != nil || ratio <= 0 || ratio == 1 {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, err
+		}
+		splits = append(splits, split{Date: vestDay(date), Ratio: ratio})
+	}
+	return splits, nil
+}
+
+// splitFactor is how many of today's shares one share held at t has become.
+func splitFactor(splits []split, t time.Time) float64 {
+	factor := 1.0
+	for _, s := range splits {
+		if s.Date.After(t) {
+			factor *= s.Ratio
+		}
+	}
+	return factor
+}
+
+// applySplits restates a grant in today's shares: the grant's share counts
+// are multiplied by the splits in its stock since it was made, and its
+// prices divided.
+func (g *grant) applySplits(splits []split) {
+	factor := splitFactor(splits, g.grantDate())
+	if factor == 1 {
+		return
+	}
+	g.Shares *= factor
+	g.TargetShares *= factor
+	for i := range g.Tranches {
+		g.Tranches[i].Shares *= factor
+	}
+	g.StrikePrice /= factor
+	g.ExerciseFMV /= factor
+}
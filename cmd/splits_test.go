@@ -0,0 +1,111 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// twoTickerConfig sets up positions in AAA, the configured ticker, and
+// BBB, with the splits given, on top of the default settings.
+func twoTickerConfig(t *testing.T, splits []map[string]interface{}) {
+	defaults := viper.AllSettings()
+	reset := func() {
+		viper.Reset()
+		for key, value := range defaults {
+			viper.SetDefault(key, value)
+		}
+	}
+	reset()
+	t.Cleanup(reset)
+	viper.Set("ticker", "aaa")
+	viper.Set("positions", []map[string]interface{}{
+		{"ticker": "AAA", "grants": []map[string]interface{}{
+			{"name": "aaa rsu", "shares": 100, "vest-start": "2020-01-01", "vest-end": "2024-01-01"},
+		}},
+		{"ticker": "BBB", "grants": []map[string]interface{}{
+			{"name": "bbb options", "type": "nso", "shares": 100, "strike-price": 10, "vest-start": "2020-01-01", "vest-end": "2024-01-01"},
+		}},
+	})
+	viper.Set("splits", splits)
+}
+
+func TestSplitsByTicker(t *testing.T) {
+	tests := []struct {
+		name     string
+		splits   []map[string]interface{}
+		aaa, bbb float64
+	}{
+		{"no splits", nil, 100, 100},
+		{"a split in the configured ticker by default",
+			[]map[string]interface{}{{"date": "2022-06-01", "ratio": "2:1"}}, 200, 100},
+		{"a split naming the configured ticker",
+			[]map[string]interface{}{{"ticker": "aaa", "date": "2022-06-01", "ratio": "2:1"}}, 200, 100},
+		{"a split in the other ticker",
+			[]map[string]interface{}{{"ticker": "BBB", "date": "2022-06-01", "ratio": "3:1"}}, 100, 300},
+		{"a split in each",
+			[]map[string]interface{}{
+				{"date": "2022-06-01", "ratio": "2:1"},
+				{"ticker": "BBB", "date": "2019-06-01", "ratio": "3:1"},
+				{"ticker": "BBB", "date": "2023-06-01", "ratio": "1:4"},
+			}, 200, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			twoTickerConfig(t, tt.splits)
+			positions, err := loadPositions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(positions) != 2 || positions[0].Ticker != "AAA" || positions[1].Ticker != "BBB" {
+				t.Fatalf("loaded %d positions, want AAA and BBB", len(positions))
+			}
+			aaa, bbb := positions[0].Grants[0], positions[1].Grants[0]
+			if aaa.Shares != tt.aaa || bbb.Shares != tt.bbb {
+				t.Errorf("AAA has %v shares and BBB %v, want %v and %v", aaa.Shares, bbb.Shares, tt.aaa, tt.bbb)
+			}
+			if strike := 10 * 100 / tt.bbb; bbb.StrikePrice != strike {
+				t.Errorf("BBB's strike price is %v, want %v", bbb.StrikePrice, strike)
+			}
+		})
+	}
+}
+
+func TestSaleRecordTicker(t *testing.T) {
+	tickers := map[string]string{"aaa rsu": "AAA", "bbb#1 options": "BBB", "": "AAA"}
+	tests := []struct {
+		r    saleRecord
+		want string
+	}{
+		{saleRecord{}, "AAA"},
+		{saleRecord{Grant: "bbb#1 options"}, "BBB"},
+		{saleRecord{Lot: "bbb#1 options#3"}, "BBB"},
+		{saleRecord{Lot: "aaa rsu#2", Grant: "aaa rsu"}, "AAA"},
+		{saleRecord{Grant: "an old grant"}, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := tt.r.ticker(tickers); got != tt.want {
+			t.Errorf("ticker of a sale of grant %q, lot %q = %s, want %s", tt.r.Grant, tt.r.Lot, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var statsDays int
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the stock's volatility, drawdown and moving averages.",
+	Long: `Work out, from the daily closes over the last --days days (kept in the
+local price cache), the stock's realized volatility over the last 30 and 90
+trading days and the whole period, its largest fall from a high and whether
+it has recovered since, and its 30 and 90-day moving averages.
+
+Setting volatility to a span such as 90d or 1y makes worth project and the
+Black-Scholes valuation use the volatility realized over it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ticker := configuredTicker()
+		prices, err := getDailyPrices(ticker, statsDays)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if len(prices) < 2 {
+			fmt.Println("stats: not enough price history")
+			os.Exit(1)
+		}
+		s := newPriceStats(ticker, prices)
+		if viper.GetString("output") == "json" {
+			err = writeStatsJSON(os.Stdout, s)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		formatStats(os.Stdout, s)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().IntVar(&statsDays, "days", 365, "number of days of history to look at")
+}
+
+// priceStats sums up a run of daily closes.
+type priceStats struct {
+	Ticker       string
+	From, To     time.Time
+	Days         int
+	Last         float64
+	Volatility30 float64
+	Volatility90 float64
+	Volatility   float64
+	Drawdown     float64
+	Peak         pricePoint
+	Trough       pricePoint
+	Recovered    time.Time
+	Average30    float64
+	Average90    float64
+}
+
+func newPriceStats(ticker string, prices []pricePoint) priceStats {
+	s := priceStats{
+		Ticker:       ticker,
+		From:         prices[0].Date,
+		To:           prices[len(prices)-1].Date,
+		Days:         len(prices),
+		Last:         prices[len(prices)-1].Close,
+		Volatility30: historicalVolatility(lastCloses(prices, 31)),
+		Volatility90: historicalVolatility(lastCloses(prices, 91)),
+		Volatility:   historicalVolatility(prices),
+		Average30:    movingAverage(prices, 30),
+		Average90:    movingAverage(prices, 90),
+	}
+	s.Drawdown, s.Peak, s.Trough, s.Recovered = maxDrawdown(prices)
+	return s
+}
+
+// lastCloses returns the last n prices, or all of them if there are fewer.
+func lastCloses(prices []pricePoint, n int) []pricePoint {
+	if len(prices) <= n {
+		return prices
+	}
+	return prices[len(prices)-n:]
+}
+
+// movingAverage is the average of the last n closes.
+func movingAverage(prices []pricePoint, n int) float64 {
+	last := lastCloses(prices, n)
+	sum := 0.0
+	for _, p := range last {
+		sum += p.Close
+	}
+	return sum / float64(len(last))
+}
+
+// maxDrawdown finds the largest fall from a high to a later low, as a
+// fraction of the high, and the day the price first got back to the high,
+// if it has.
+func maxDrawdown(prices []pricePoint) (float64, pricePoint, pricePoint, time.Time) {
+	var drawdown float64
+	var peak, trough pricePoint
+	high := prices[0]
+	for _, p := range prices {
+		if p.Close > high.Close {
+			high = p
+		}
+		if high.Close > 0 && (high.Close-p.Close)/high.Close > drawdown {
+			drawdown = (high.Close - p.Close) / high.Close
+			peak, trough = high, p
+		}
+	}
+	var recovered time.Time
+	if drawdown > 0 {
+		for _, p := range prices {
+			if p.Date.After(trough.Date) && p.Close >= peak.Close {
+				recovered = p.Date
+				break
+			}
+		}
+	}
+	return drawdown, peak, trough, recovered
+}
+
+func formatStats(w io.Writer, s priceStats) {
+	ac := configuredMoney()
+	fmt.Fprintf(w, "%s from %s to %s (%d trading days):\n", s.Ticker, s.From.Format("Jan 2, 2006"),
+		s.To.Format("Jan 2, 2006"), s.Days)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Realized volatility\t%.1f%% over 30 days, %.1f%% over 90, %.1f%% over the period\n",
+		s.Volatility30*100, s.Volatility90*100, s.Volatility*100)
+	if s.Drawdown > 0 {
+		recovery := "not yet recovered"
+		if !s.Recovered.IsZero() {
+			recovery = "recovered on " + s.Recovered.Format("Jan 2, 2006")
+		}
+		fmt.Fprintf(tw, "Largest drawdown\t-%.1f%%, from %s on %s to %s on %s; %s\n", s.Drawdown*100,
+			ac.FormatMoney(s.Peak.Close), s.Peak.Date.Format("Jan 2, 2006"), ac.FormatMoney(s.Trough.Close),
+			s.Trough.Date.Format("Jan 2, 2006"), recovery)
+	} else {
+		fmt.Fprintf(tw, "Largest drawdown\tnone\n")
+	}
+	for _, ma := range []struct {
+		days    int
+		average float64
+	}{{30, s.Average30}, {90, s.Average90}} {
+		change := (s.Last - ma.average) / ma.average * 100
+		direction := "above"
+		if change < 0 {
+			direction = "below"
+		}
+		fmt.Fprintf(tw, "%d-day average\t%s; the last close, %s, is %.1f%% %s it\n", ma.days, ac.FormatMoney(ma.average),
+			ac.FormatMoney(s.Last), math.Abs(change), direction)
+	}
+	tw.Flush()
+}
+
+func writeStatsJSON(w io.Writer, s priceStats) error {
+	type jsonDrawdown struct {
+		Drawdown   float64    `json:"drawdown"`
+		PeakDate   time.Time  `json:"peak_date"`
+		Peak       float64    `json:"peak"`
+		TroughDate time.Time  `json:"trough_date"`
+		Trough     float64    `json:"trough"`
+		Recovered  *time.Time `json:"recovered,omitempty"`
+	}
+	out := struct {
+		SchemaVersion int           `json:"schema_version"`
+		Ticker        string        `json:"ticker"`
+		From          time.Time     `json:"from"`
+		To            time.Time     `json:"to"`
+		TradingDays   int           `json:"trading_days"`
+		LastClose     float64       `json:"last_close"`
+		Volatility30  float64       `json:"volatility_30d"`
+		Volatility90  float64       `json:"volatility_90d"`
+		Volatility    float64       `json:"volatility"`
+		MaxDrawdown   *jsonDrawdown `json:"max_drawdown,omitempty"`
+		Average30     float64       `json:"moving_average_30d"`
+		Average90     float64       `json:"moving_average_90d"`
+	}{SchemaVersion: schemaVersion, Ticker: s.Ticker, From: s.From, To: s.To, TradingDays: s.Days,
+		LastClose: s.Last, Volatility30: s.Volatility30, Volatility90: s.Volatility90, Volatility: s.Volatility,
+		Average30: s.Average30, Average90: s.Average90}
+	if s.Drawdown > 0 {
+		out.MaxDrawdown = &jsonDrawdown{Drawdown: s.Drawdown, PeakDate: s.Peak.Date, Peak: s.Peak.Close,
+			TroughDate: s.Trough.Date, Trough: s.Trough.Close}
+		if !s.Recovered.IsZero() {
+			out.MaxDrawdown.Recovered = &s.Recovered
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// volatilitySetting reads a volatility given as a percentage, or as a span
+// such as 90d or 1y to use the volatility symbol realized over it, which is
+// returned as the window.
+func volatilitySetting(symbol string, setting interface{}) (float64, string, error) {
+	if s, ok := setting.(string); ok && spanPattern.MatchString(s) && s != "" {
+		years, months, days, err := parseSpan(s)
+		if err != nil {
+			return 0, "", err
+		}
+		now := time.Now()
+		since := addSpan(now, -years, -months, -days)
+		prices, err := getDailyPrices(symbol, int(now.Sub(since).Hours()/24))
+		if err != nil {
+			return 0, "", err
+		}
+		volatility := historicalVolatility(prices)
+		if volatility <= 0 {
+			return 0, "", fmt.Errorf("not enough price history over %s to estimate it", s)
+		}
+		return volatility, s, nil
+	}
+	volatility, err := configPercent(setting)
+	return volatility, "", err
+}
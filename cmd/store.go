@@ -0,0 +1,558 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+)
+
+// snapshotStore is where the snapshot history is kept, chosen by the
+// snapshot-store setting.
+type snapshotStore interface {
+	// append adds a snapshot to the history.
+	append(s snapshot) error
+	// load returns every snapshot, as recorded.
+	load() ([]snapshot, error)
+	// replace rewrites the history with just these snapshots.
+	replace(snapshots []snapshot) error
+}
+
+// openSnapshotStore returns the configured store: a JSON lines file (the
+// default), a SQLite database, or an object in an S3 or Google Cloud
+// Storage bucket, which can be shared between machines.
+func openSnapshotStore() (snapshotStore, error) {
+	switch kind := viper.GetString("snapshot-store"); kind {
+	case "", "file":
+		path, err := snapshotsPath()
+		return fileStore{path: path}, err
+	case "sqlite":
+		path, err := snapshotsPath()
+		if err == nil && isEncrypted(path) {
+			err = fmt.Errorf("%s: a SQLite history can't be encrypted; use the file store", path)
+		}
+		return sqliteStore{path: path}, err
+	case "s3", "gcs":
+		return loadBucketStore("snapshot-bucket", "snapshots.jsonl", kind)
+	default:
+		return nil, fmt.Errorf("invalid snapshot-store %q: expected file, sqlite, s3 or gcs", kind)
+	}
+}
+
+// fileStore keeps snapshots in a file, one JSON object per line.
+type fileStore struct {
+	path string
+}
+
+func (f fileStore) append(s snapshot) error {
+	if isEncrypted(f.path) {
+		return f.appendEncrypted(s)
+	}
+	err := os.MkdirAll(filepath.Dir(f.path), 0700)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(s)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// appendEncrypted rewrites an encrypted history with the snapshot added.
+func (f fileStore) appendEncrypted(s snapshot) error {
+	data, err := readFileEncrypted(f.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	sealed, err := encryptData(append(data, append(line, '\n')...), false)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path, sealed)
+}
+
+func (f fileStore) replace(snapshots []snapshot) error {
+	data, err := snapshotLines(snapshots)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(f.path) {
+		data, err = encryptData(data, false)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(f.path, data)
+}
+
+func (f fileStore) load() ([]snapshot, error) {
+	if isEncrypted(f.path) {
+		data, err := readFileEncrypted(f.path)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return readSnapshots(bytes.NewReader(data), f.path)
+	}
+	file, err := os.Open(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readSnapshots(file, f.path)
+}
+
+// snapshotLines writes snapshots one JSON object per line.
+func snapshotLines(snapshots []snapshot) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, s := range snapshots {
+		line, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return buf.Bytes(), nil
+}
+
+// readSnapshots reads snapshots written one JSON object per line; name
+// identifies where from in errors.
+func readSnapshots(r io.Reader, name string) ([]snapshot, error) {
+	var snapshots []snapshot
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var s snapshot
+		err := json.Unmarshal([]byte(line), &s)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", name, n, err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, scanner.Err()
+}
+
+// sqliteStore keeps snapshots in a SQLite database, each as JSON in a row
+// of the snapshots table.
+type sqliteStore struct {
+	path string
+}
+
+func (q sqliteStore) open() (*sql.DB, error) {
+	err := os.MkdirAll(filepath.Dir(q.path), 0700)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", q.path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS snapshots (time TEXT NOT NULL, snapshot TEXT NOT NULL)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %s", q.path, err)
+	}
+	return db, nil
+}
+
+func (q sqliteStore) append(s snapshot) error {
+	db, err := q.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO snapshots (time, snapshot) VALUES (?, ?)`, s.Time.UTC().Format(time.RFC3339Nano), string(data))
+	return err
+}
+
+func (q sqliteStore) replace(snapshots []snapshot) error {
+	db, err := q.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM snapshots`)
+	if err != nil {
+		return err
+	}
+	for _, s := range snapshots {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO snapshots (time, snapshot) VALUES (?, ?)`, s.Time.UTC().Format(time.RFC3339Nano), string(data))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (q sqliteStore) load() ([]snapshot, error) {
+	if _, err := os.Stat(q.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	db, err := q.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT snapshot FROM snapshots ORDER BY time`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snapshots []snapshot
+	for rows.Next() {
+		var data string
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+		var s snapshot
+		err = json.Unmarshal([]byte(data), &s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", q.path, err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// bucketStore keeps snapshots as a JSON lines object in an S3 bucket, or a
+// Google Cloud Storage one through its S3-compatible API with an HMAC key.
+// Requests are signed with AWS Signature Version 4. kind is s3 or gcs.
+type bucketStore struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	Key       string `mapstructure:"key"`
+	AccessKey string `mapstructure:"access-key-id"`
+	Secret    string `mapstructure:"secret-access-key"`
+	Token     string `mapstructure:"session-token"`
+	kind      string
+}
+
+// errObjectChanged is returned by a conditional put when someone else
+// changed the object since it was read.
+var errObjectChanged = errors.New("the object was changed by someone else")
+
+// writeAttempts is how many times a change to something shared between
+// machines is tried when another machine changes it first.
+const writeAttempts = 3
+
+// loadBucketStore reads the bucket settings under setting, with key the
+// object's default key. The keys default to the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+func loadBucketStore(setting, key, kind string) (bucketStore, error) {
+	b := bucketStore{Key: key, Region: os.Getenv("AWS_REGION"), kind: kind}
+	err := viper.UnmarshalKey(setting, &b)
+	if err != nil {
+		return b, fmt.Errorf("invalid %s: %s", setting, err)
+	}
+	if b.Bucket == "" {
+		return b, fmt.Errorf("%s has no bucket", setting)
+	}
+	if b.AccessKey == "" {
+		b.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		// temporary credentials, from SSO or an assumed role, come with a
+		// session token
+		if b.Token == "" {
+			b.Token = os.Getenv("AWS_SESSION_TOKEN")
+		}
+	}
+	if b.Secret == "" {
+		b.Secret = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if b.AccessKey == "" || b.Secret == "" {
+		return b, fmt.Errorf("%s has no access-key-id and secret-access-key", setting)
+	}
+	switch {
+	case b.Region == "" && kind == "gcs":
+		b.Region = "auto"
+	case b.Region == "":
+		b.Region = "us-east-1"
+	}
+	if b.Endpoint == "" {
+		b.Endpoint = "https://s3." + b.Region + ".amazonaws.com"
+		if kind == "gcs" {
+			b.Endpoint = "https://storage.googleapis.com"
+		}
+	}
+	b.Endpoint = strings.TrimSuffix(b.Endpoint, "/")
+	return b, nil
+}
+
+// object fetches the history object, decrypting it if need be, and its
+// version; a missing one is empty.
+func (b bucketStore) object() ([]byte, string, error) {
+	data, version, err := b.get()
+	if err != nil || data == nil || !isEncrypted(b.Key) {
+		return data, version, err
+	}
+	data, err = decryptData(data, b.url())
+	return data, version, err
+}
+
+// get fetches the object as it's stored, and its version: the ETag, or
+// Cloud Storage's generation. A missing one is nil, at version "".
+func (b bucketStore) get() ([]byte, string, error) {
+	resp, err := b.request(http.MethodGet, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if resp.IsError() {
+		return nil, "", fmt.Errorf("%s: %s", b.url(), resp.Status())
+	}
+	version := resp.Header().Get("ETag")
+	if b.kind == "gcs" {
+		version = resp.Header().Get("X-Goog-Generation")
+	}
+	return resp.Body(), version, nil
+}
+
+// unchanged is the precondition for replacing the object only if it's
+// still at version, as read by get, or still missing for "".
+func (b bucketStore) unchanged(version string) map[string]string {
+	switch {
+	case b.kind == "gcs" && version == "":
+		return map[string]string{"x-goog-if-generation-match": "0"}
+	case b.kind == "gcs":
+		return map[string]string{"x-goog-if-generation-match": version}
+	case version == "":
+		return map[string]string{"if-none-match": "*"}
+	}
+	return map[string]string{"if-match": version}
+}
+
+// put replaces the object with data, if the preconditions given hold; if
+// they don't, it returns errObjectChanged.
+func (b bucketStore) put(data []byte, preconditions map[string]string) error {
+	resp, err := b.request(http.MethodPut, data, preconditions)
+	if err != nil {
+		return err
+	}
+	// S3 answers 409 when another conditional write to the object is
+	// under way
+	if resp.StatusCode() == http.StatusPreconditionFailed || (preconditions != nil && resp.StatusCode() == http.StatusConflict) {
+		return errObjectChanged
+	}
+	if resp.IsError() {
+		return fmt.Errorf("%s: %s", b.url(), resp.Status())
+	}
+	return nil
+}
+
+// append rewrites the object with the snapshot added, as objects can't be
+// appended to. The object is only replaced if no one else changed it in the
+// meantime; if someone did, it's read again and the snapshot added to that.
+func (b bucketStore) append(s snapshot) error {
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		err = b.appendLine(line)
+		if err != errObjectChanged || attempt == writeAttempts {
+			return err
+		}
+	}
+}
+
+// appendLine adds a line to the object, provided it's unchanged since
+// read.
+func (b bucketStore) appendLine(line []byte) error {
+	data, version, err := b.object()
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	data = append(data, append(line, '\n')...)
+	if isEncrypted(b.Key) {
+		data, err = encryptData(data, false)
+		if err != nil {
+			return err
+		}
+	}
+	return b.put(data, b.unchanged(version))
+}
+
+func (b bucketStore) replace(snapshots []snapshot) error {
+	data, err := snapshotLines(snapshots)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(b.Key) {
+		data, err = encryptData(data, false)
+		if err != nil {
+			return err
+		}
+	}
+	return b.put(data, nil)
+}
+
+func (b bucketStore) load() ([]snapshot, error) {
+	data, _, err := b.object()
+	if err != nil {
+		return nil, err
+	}
+	return readSnapshots(bytes.NewReader(data), b.url())
+}
+
+func (b bucketStore) url() string {
+	return b.Endpoint + b.path()
+}
+
+// path is the object's path-style URL path, with each segment escaped.
+func (b bucketStore) path() string {
+	segments := strings.Split(b.Bucket+"/"+strings.TrimPrefix(b.Key, "/"), "/")
+	for i, s := range segments {
+		segments[i] = uriEncode(s)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// uriEncode escapes everything but RFC 3986's unreserved characters, as
+// Signature Version 4 expects of each segment of the path it signs.
+func uriEncode(s string) string {
+	var escaped strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			escaped.WriteByte(c)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", c)
+		}
+	}
+	return escaped.String()
+}
+
+// request sends a request for the object signed with Signature Version 4,
+// with the extra headers given, which are signed too.
+func (b bucketStore) request(method string, body []byte, extra map[string]string) (*resty.Response, error) {
+	endpoint, err := url.Parse(b.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot-bucket endpoint: %s", err)
+	}
+	now := time.Now().UTC()
+	stamp, day := now.Format("20060102T150405Z"), now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+
+	headers := map[string]string{"host": endpoint.Host, "x-amz-content-sha256": payload, "x-amz-date": stamp}
+	if b.Token != "" {
+		headers["x-amz-security-token"] = b.Token
+	}
+	for name, value := range extra {
+		headers[name] = value
+	}
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	fmt.Fprintf(&canonical, "%s\n%s\n\n", method, b.path())
+	for _, name := range names {
+		fmt.Fprintf(&canonical, "%s:%s\n", name, headers[name])
+	}
+	signed := strings.Join(names, ";")
+	fmt.Fprintf(&canonical, "\n%s\n%s", signed, payload)
+
+	scope := day + "/" + b.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical.String()))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + b.Secret)
+	for _, part := range []string{day, b.Region, "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	authorization := fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.AccessKey, scope, signed, hex.EncodeToString(key))
+
+	r := resty.New().R().SetHeader("Authorization", authorization)
+	for _, name := range names {
+		if name != "host" {
+			r.SetHeader(name, headers[name])
+		}
+	}
+	if body != nil {
+		r.SetBody(body).SetHeader("Content-Type", "application/x-ndjson")
+	}
+	return r.Execute(method, b.url())
+}
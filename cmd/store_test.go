@@ -0,0 +1,60 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import "testing"
+
+func TestBucketPath(t *testing.T) {
+	tests := []struct {
+		bucket, key string
+		want        string
+	}{
+		{"my-bucket", "snapshots.jsonl", "/my-bucket/snapshots.jsonl"},
+		{"my-bucket", "/worth/snapshots.jsonl.age", "/my-bucket/worth/snapshots.jsonl.age"},
+		{"my-bucket", "a b+c~d_e-f.g", "/my-bucket/a%20b%2Bc~d_e-f.g"},
+		{"my-bucket", "$&,:;=@!'()*", "/my-bucket/%24%26%2C%3A%3B%3D%40%21%27%28%29%2A"},
+		{"my-bucket", "ünï/cødé", "/my-bucket/%C3%BCn%C3%AF/c%C3%B8d%C3%A9"},
+	}
+	for _, tt := range tests {
+		b := bucketStore{Bucket: tt.bucket, Key: tt.key}
+		if got := b.path(); got != tt.want {
+			t.Errorf("path of %q = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestBucketPreconditions(t *testing.T) {
+	tests := []struct {
+		kind, version string
+		header, want  string
+	}{
+		{"s3", "", "if-none-match", "*"},
+		{"s3", `"abc"`, "if-match", `"abc"`},
+		{"gcs", "", "x-goog-if-generation-match", "0"},
+		{"gcs", "1712345", "x-goog-if-generation-match", "1712345"},
+	}
+	for _, tt := range tests {
+		got := bucketStore{kind: tt.kind}.unchanged(tt.version)
+		if len(got) != 1 || got[tt.header] != tt.want {
+			t.Errorf("%s precondition for version %q = %v, want %s: %s", tt.kind, tt.version, got, tt.header, tt.want)
+		}
+	}
+}
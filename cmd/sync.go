@@ -0,0 +1,531 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var syncPrefer string
+
+// errPushRejected is returned when someone else pushed first, or changed
+// an object in the bucket since it was read; syncing starts over from what
+// they wrote.
+var errPushRejected = errors.New("the push was rejected")
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Share your config, grants, ledger and history between machines.",
+	Long: `Sync the config file, grants-file, sale ledger and snapshot history with a
+git repository or an S3 or Google Cloud Storage bucket, so each machine
+sees the same ones.
+
+The ledger and history are merged, keeping the records from both sides;
+with snapshot-retention set, the merged history is then pruned as worth
+history prune does.
+When two machines sync at once, the second to write starts over from what
+the first sent, whether through git or a bucket.
+The config and grants file are taken from whichever side changed since the
+last sync; if both did, yours is kept and theirs is written beside it as
+FILE.conflict for you to reconcile, or pick a side with --prefer.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if syncPrefer != "" && syncPrefer != "local" && syncPrefer != "remote" {
+			fmt.Printf("invalid --prefer %q: expected local or remote\n", syncPrefer)
+			os.Exit(1)
+		}
+		remote, err := openSyncRemote()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		files, err := syncedFiles()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for attempt := 1; ; attempt++ {
+			err = syncFiles(remote, files, syncPrefer)
+			if err != errPushRejected || attempt == writeAttempts {
+				break
+			}
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVar(&syncPrefer, "prefer", "", "settle conflicting changes to the config or grants file with the local or remote copy")
+}
+
+// syncedFile is a local file that's synced, and its name on the remote.
+// A log's records are merged; other files are replaced whole. History marks
+// the snapshot history, which is pruned once merged.
+type syncedFile struct {
+	Path    string
+	Name    string
+	Log     bool
+	History bool
+}
+
+// syncedFiles lists what's synced: the config file, the grants-file if
+// there is one, the ledger and, if kept in a file, the snapshot history.
+func syncedFiles() ([]syncedFile, error) {
+	var files []syncedFile
+	add := func(path, role string, log bool) {
+		ext := filepath.Ext(strings.TrimSuffix(path, encryptedSuffix))
+		if isEncrypted(path) {
+			ext += encryptedSuffix
+		}
+		files = append(files, syncedFile{Path: path, Name: role + ext, Log: log, History: role == "snapshots"})
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		add(used, "config", false)
+	}
+	if viper.GetString("grants-file") != "" {
+		path, err := grantsFilePath()
+		if err != nil {
+			return nil, err
+		}
+		add(path, "grants", false)
+	}
+	path, err := ledgerPath()
+	if err != nil {
+		return nil, err
+	}
+	add(path, "ledger", true)
+	if store := viper.GetString("snapshot-store"); store == "" || store == "file" {
+		path, err = snapshotsPath()
+		if err != nil {
+			return nil, err
+		}
+		add(path, "snapshots", true)
+	}
+	return files, nil
+}
+
+// syncRemote is somewhere the synced files are shared. begin readies it for
+// reading, and finish publishes what was written.
+type syncRemote interface {
+	id() string
+	begin() error
+	read(name string) ([]byte, error)
+	write(name string, data []byte) error
+	finish() error
+}
+
+// openSyncRemote returns the remote picked by the sync setting.
+func openSyncRemote() (syncRemote, error) {
+	switch kind := viper.GetString("sync"); kind {
+	case "git":
+		url := viper.GetString("sync-remote")
+		if url == "" {
+			return nil, fmt.Errorf("sync git needs sync-remote, the repository to sync with")
+		}
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256([]byte(url))
+		dir := filepath.Join(base, "worth", "sync", hex.EncodeToString(sum[:6]))
+		return &gitRemote{URL: url, Dir: dir}, nil
+	case "s3", "gcs":
+		b, err := loadBucketStore("sync-bucket", "worth", kind)
+		return &bucketRemote{bucketStore: b}, err
+	case "":
+		return nil, fmt.Errorf("set sync to git, s3 or gcs to say where to sync with")
+	default:
+		return nil, fmt.Errorf("invalid sync %q: expected git, s3 or gcs", kind)
+	}
+}
+
+// syncFiles syncs each file with the remote. Local files are only changed
+// once the remote has taken every change; if someone else changed it since
+// it was read, it refuses them with errPushRejected and nothing local is
+// touched.
+func syncFiles(remote syncRemote, files []syncedFile, prefer string) error {
+	err := remote.begin()
+	if err != nil {
+		return err
+	}
+	state, err := loadSyncState(remote.id())
+	if err != nil {
+		return err
+	}
+
+	type update struct {
+		path string
+		data []byte
+		note string
+	}
+	var updates []update
+	var pushed []string
+	for _, f := range files {
+		local, err := os.ReadFile(f.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		theirs, err := remote.read(f.Name)
+		if err != nil {
+			return err
+		}
+		if local == nil && theirs == nil {
+			continue
+		}
+		mine, err := syncPlain(local, f.Path)
+		if err != nil {
+			return err
+		}
+		other, err := syncPlain(theirs, f.Name)
+		if err != nil {
+			return err
+		}
+
+		merged, conflict := mine, false
+		switch {
+		case f.Log:
+			merged, err = mergeLogs(mine, other)
+			if err == nil && f.History && viper.IsSet("snapshot-retention") {
+				// or the snapshots pruned on one side come back from the other
+				merged, err = pruneLog(merged, time.Now())
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+		case theirs == nil || bytes.Equal(mine, other):
+		case local == nil || syncHash(mine) == state[f.Name] || prefer == "remote":
+			merged = other
+		case syncHash(other) == state[f.Name] || prefer == "local":
+		default:
+			conflict = true
+		}
+		if conflict {
+			path := strings.TrimSuffix(f.Path, encryptedSuffix) + ".conflict"
+			if isEncrypted(f.Path) {
+				path += encryptedSuffix
+			}
+			updates = append(updates, update{path, theirs,
+				fmt.Sprintf("%s changed both here and on the remote; theirs is in %s.", f.Path, path)})
+			continue
+		}
+		state[f.Name] = syncHash(merged)
+		if theirs == nil || !bytes.Equal(merged, other) {
+			sealed, err := syncSeal(merged, f.Name)
+			if err != nil {
+				return err
+			}
+			err = remote.write(f.Name, sealed)
+			if err != nil {
+				return err
+			}
+			pushed = append(pushed, f.Name)
+		}
+		if local == nil || !bytes.Equal(merged, mine) {
+			sealed, err := syncSeal(merged, f.Path)
+			if err != nil {
+				return err
+			}
+			updates = append(updates, update{f.Path, sealed, "Updated " + f.Path + "."})
+		}
+	}
+
+	err = remote.finish()
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		err = writeFileAtomic(u.path, u.data)
+		if err != nil {
+			return err
+		}
+		fmt.Println(u.note)
+	}
+	if len(pushed) > 0 {
+		fmt.Printf("Sent %s.\n", strings.Join(pushed, ", "))
+	}
+	if len(updates) == 0 && len(pushed) == 0 {
+		fmt.Println("Already in sync.")
+	}
+	return saveSyncState(remote.id(), state)
+}
+
+// syncPlain decrypts a synced file if its name says it's encrypted.
+func syncPlain(data []byte, name string) ([]byte, error) {
+	if data == nil || !isEncrypted(name) {
+		return data, nil
+	}
+	return decryptData(data, name)
+}
+
+// syncSeal encrypts a synced file if its name says it should be.
+func syncSeal(data []byte, name string) ([]byte, error) {
+	if !isEncrypted(name) {
+		return data, nil
+	}
+	return encryptData(data, false)
+}
+
+func syncHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// mergeLogs merges two logs of JSON records, one to a line, keeping every
+// record from either in order of its time (or date).
+func mergeLogs(a, b []byte) ([]byte, error) {
+	type record struct {
+		line string
+		at   time.Time
+	}
+	var records []record
+	seen := map[string]bool{}
+	for _, log := range [][]byte{a, b} {
+		scanner := bufio.NewScanner(bytes.NewReader(log))
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || seen[line] {
+				continue
+			}
+			seen[line] = true
+			var stamp struct {
+				Time time.Time `json:"time"`
+				Date time.Time `json:"date"`
+			}
+			err := json.Unmarshal([]byte(line), &stamp)
+			if err != nil {
+				return nil, err
+			}
+			at := stamp.Time
+			if at.IsZero() {
+				at = stamp.Date
+			}
+			records = append(records, record{line, at})
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].at.Before(records[j].at) })
+	var buf bytes.Buffer
+	for _, r := range records {
+		buf.WriteString(r.line + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// syncStatePath is where the hashes of the files as last synced with a
+// remote are kept, to tell which side changed one since.
+func syncStatePath(id string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(id + "\x00" + viper.ConfigFileUsed()))
+	return filepath.Join(base, "worth", "sync", hex.EncodeToString(sum[:6])+".json"), nil
+}
+
+func loadSyncState(id string) (map[string]string, error) {
+	state := map[string]string{}
+	path, err := syncStatePath(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return state, nil
+}
+
+func saveSyncState(id string, state map[string]string) error {
+	path, err := syncStatePath(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// gitRemote syncs through a clone of a git repository kept in the cache
+// directory, committing and pushing the changes.
+type gitRemote struct {
+	URL    string
+	Dir    string
+	branch string
+}
+
+func (g *gitRemote) id() string {
+	return "git " + g.URL
+}
+
+func (g *gitRemote) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.Dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// begin clones the repository, or brings the clone up to date with it,
+// dropping anything left from a push that failed.
+func (g *gitRemote) begin() error {
+	if _, err := os.Stat(filepath.Join(g.Dir, ".git")); os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(g.Dir), 0700)
+		if err != nil {
+			return err
+		}
+		out, err := exec.Command("git", "clone", "--quiet", g.URL, g.Dir).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git clone: %s", strings.TrimSpace(string(out)))
+		}
+	}
+	branch, err := g.git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return err
+	}
+	g.branch = branch
+	_, err = g.git("fetch", "--quiet", "origin")
+	if err != nil {
+		return err
+	}
+	if _, err := g.git("rev-parse", "--verify", "--quiet", "origin/"+branch); err == nil {
+		_, err = g.git("reset", "--quiet", "--hard", "origin/"+branch)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = g.git("clean", "--quiet", "--force")
+	return err
+}
+
+func (g *gitRemote) read(name string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(g.Dir, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (g *gitRemote) write(name string, data []byte) error {
+	return os.WriteFile(filepath.Join(g.Dir, name), data, 0600)
+}
+
+// finish commits what was written and pushes it.
+func (g *gitRemote) finish() error {
+	status, err := g.git("status", "--porcelain")
+	if err != nil || status == "" {
+		return err
+	}
+	_, err = g.git("add", "--all")
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	_, err = g.git("-c", "user.name=worth", "-c", "user.email=worth@"+host, "commit", "--quiet", "-m", "Sync from "+host)
+	if err != nil {
+		return err
+	}
+	_, err = g.git("push", "--quiet", "origin", "HEAD:"+g.branch)
+	if err != nil {
+		if strings.Contains(err.Error(), "rejected") {
+			return errPushRejected
+		}
+		return err
+	}
+	return nil
+}
+
+// bucketRemote syncs through objects under a key prefix in a bucket. Each
+// object is only replaced if it's still at the version read, so two
+// machines syncing at once can't overwrite each other's changes.
+type bucketRemote struct {
+	bucketStore
+	versions map[string]string
+}
+
+func (b *bucketRemote) id() string {
+	return "bucket " + b.url()
+}
+
+// begin forgets the versions read by an earlier attempt.
+func (b *bucketRemote) begin() error {
+	b.versions = map[string]string{}
+	return nil
+}
+
+// at is the object holding name.
+func (b *bucketRemote) at(name string) bucketStore {
+	o := b.bucketStore
+	o.Key = strings.TrimSuffix(o.Key, "/") + "/" + name
+	return o
+}
+
+func (b *bucketRemote) read(name string) ([]byte, error) {
+	data, version, err := b.at(name).get()
+	b.versions[name] = version
+	return data, err
+}
+
+func (b *bucketRemote) write(name string, data []byte) error {
+	o := b.at(name)
+	err := o.put(data, o.unchanged(b.versions[name]))
+	if err == errObjectChanged {
+		return errPushRejected
+	}
+	return err
+}
+
+func (b *bucketRemote) finish() error {
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import "testing"
+
+func TestMergeLogs(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+		ok   bool
+	}{
+		{"union in time order",
+			`{"time":"2026-01-01T00:00:00Z","n":1}` + "\n" + `{"time":"2026-01-03T00:00:00Z","n":3}` + "\n",
+			`{"time":"2026-01-02T00:00:00Z","n":2}` + "\n",
+			`{"time":"2026-01-01T00:00:00Z","n":1}` + "\n" + `{"time":"2026-01-02T00:00:00Z","n":2}` + "\n" + `{"time":"2026-01-03T00:00:00Z","n":3}` + "\n",
+			true},
+		{"lines on both sides once",
+			`{"time":"2026-01-01T00:00:00Z","n":1}` + "\n",
+			"  " + `{"time":"2026-01-01T00:00:00Z","n":1}` + "\n\n",
+			`{"time":"2026-01-01T00:00:00Z","n":1}` + "\n",
+			true},
+		{"ordered by date",
+			`{"date":"2026-02-01T00:00:00Z","id":"b"}` + "\n",
+			`{"date":"2026-01-01T00:00:00Z","id":"a"}` + "\n",
+			`{"date":"2026-01-01T00:00:00Z","id":"a"}` + "\n" + `{"date":"2026-02-01T00:00:00Z","id":"b"}` + "\n",
+			true},
+		{"ties keep their order",
+			`{"time":"2026-01-01T00:00:00Z","n":1}` + "\n",
+			`{"time":"2026-01-01T00:00:00Z","n":2}` + "\n",
+			`{"time":"2026-01-01T00:00:00Z","n":1}` + "\n" + `{"time":"2026-01-01T00:00:00Z","n":2}` + "\n",
+			true},
+		{"both empty", "", "", "", true},
+		{"not JSON", `{"time":"2026-01-01T00:00:00Z"}` + "\n", "not json\n", "", false},
+	}
+	for _, tt := range tests {
+		got, err := mergeLogs([]byte(tt.a), []byte(tt.b))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: mergeLogs error = %v, want ok %t", tt.name, err, tt.ok)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: mergeLogs =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"math"
+)
+
+// priceSearchLimit bounds the prices searched for a break-even or target
+// price; a goal out of reach below it has no answer.
+const priceSearchLimit = 1e7
+
+// searchPrice finds, to the cent, the lowest price at which ok holds, given
+// that it holds at every price above that. It returns false if it doesn't
+// hold even at priceSearchLimit.
+func searchPrice(low float64, ok func(price float64) bool) (float64, bool) {
+	if !ok(priceSearchLimit) {
+		return 0, false
+	}
+	high := priceSearchLimit
+	for high-low > 0.005 {
+		mid := (low + high) / 2
+		if ok(mid) {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return math.Ceil(high*100) / 100, true
+}
+
+// breakEven is the lowest price at which exercising options at strike and
+// selling the shares the same day leaves something after fees and the tax
+// on the spread.
+func breakEven(v valuation, fees saleFees, g grant, options float64) float64 {
+	price, _ := searchPrice(g.StrikePrice, func(price float64) bool {
+		spread := options * (price - g.StrikePrice)
+		tax := 0.0
+		if v.Tax.Set {
+			tax = v.Tax.owed(v.Tax.optionIncome(g, spread))
+		}
+		return spread-tax-fees.on(options, price) > 0
+	})
+	return price
+}
+
+// netValue is what everything held, vested or not, would bring in at price
+// after fees and, with tax settings, estimated taxes.
+func netValue(v valuation, fees saleFees, price float64) float64 {
+	gross := heldValue(v, price)
+	shares := 0.0
+	for _, g := range v.Grants {
+		if !g.isOption() || price > g.StrikePrice {
+			shares += g.SharesVestedUnsold + g.SharesUnvested
+		}
+	}
+	net := gross - fees.on(shares, price)
+	if v.Tax.Set {
+		net -= v.Tax.owed(gross)
+	}
+	return net
+}
+
+// applyTarget works out the share price at which everything held would be
+// worth target after fees and taxes.
+func (v *valuation) applyTarget(fees saleFees, target float64) {
+	v.TargetValue = target
+	v.TargetPrice, v.TargetReachable = searchPrice(0, func(price float64) bool {
+		return netValue(*v, fees, price) >= target
+	})
+}
+
+// formatTarget prints the price needed to reach the --target-value goal.
+func formatTarget(v valuation) {
+	ac := v.money()
+	after := "after fees"
+	if v.Tax.Set {
+		after = "after fees and estimated taxes"
+	}
+	if !v.TargetReachable {
+		fmt.Printf("No share price would make your shares worth %s %s.\n", ac.FormatMoney(v.TargetValue), after)
+		return
+	}
+	change := (v.TargetPrice - v.Price) / v.Price * 100
+	if change > 0 {
+		fmt.Printf("To be worth %s %s, %s would have to reach %s, up %.1f%% from %s.\n",
+			ac.FormatMoney(v.TargetValue), after, v.Ticker, ac.FormatMoney(v.TargetPrice), change, ac.FormatMoney(v.Price))
+		return
+	}
+	fmt.Printf("Your shares are already worth %s %s; %s could fall to %s, down %.1f%% from %s, and still get there.\n",
+		ac.FormatMoney(v.TargetValue), after, v.Ticker, ac.FormatMoney(v.TargetPrice), -change, ac.FormatMoney(v.Price))
+}
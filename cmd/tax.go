@@ -0,0 +1,223 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// taxBracket is one step of a progressive tax schedule: Rate applies to the
+// income above Over.
+type taxBracket struct {
+	Over float64
+	Rate float64
+}
+
+// taxRates are the configured tax settings, used to estimate what's left of
+// equity income after tax. The estimates treat the value of the shares as
+// employment income on top of the rest of the year's income, as it is at
+// vest or on exercising and selling options the same day.
+type taxRates struct {
+	Set          bool
+	Country      string
+	Income       float64
+	Federal      []taxBracket
+	State        []taxBracket
+	Social       []taxBracket
+	Surcharge    float64
+	Supplemental float64
+	module       taxCountry
+}
+
+// loadTax reads the tax settings. The country selects the rules applied and
+// the default national (federal) brackets; federal and state tax can each
+// be given as a flat marginal rate or a list of brackets instead.
+func loadTax() (taxRates, error) {
+	t := taxRates{Set: viper.IsSet("tax")}
+	if !t.Set {
+		return t, nil
+	}
+	t.Income = viper.GetFloat64("tax.income")
+
+	t.Country = viper.GetString("tax.country")
+	if t.Country == "" {
+		t.Country = "us"
+	}
+	var ok bool
+	t.module, ok = taxCountries[t.Country]
+	if !ok {
+		return t, fmt.Errorf("invalid tax country %q: expected us, uk, ca or de", t.Country)
+	}
+	t.Surcharge = t.module.Surcharge
+	if !viper.IsSet("tax.social-insurance") || viper.GetBool("tax.social-insurance") {
+		t.Social = t.module.Social
+	}
+
+	var err error
+	t.Supplemental, err = configPercent(viper.Get("tax.supplemental-withholding"))
+	if err != nil {
+		return t, fmt.Errorf("tax supplemental-withholding: %s", err)
+	}
+	t.Federal, err = loadTaxSchedule("federal")
+	if err != nil {
+		return t, err
+	}
+	if t.Federal == nil {
+		t.Federal = t.module.National
+	}
+	t.State, err = loadTaxSchedule("state")
+	if err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// loadTaxSchedule reads tax.<name>-rate or tax.<name>-brackets.
+func loadTaxSchedule(name string) ([]taxBracket, error) {
+	rateKey, bracketsKey := "tax."+name+"-rate", "tax."+name+"-brackets"
+	if viper.IsSet(rateKey) && viper.IsSet(bracketsKey) {
+		return nil, fmt.Errorf("tax %s-rate and %s-brackets can't both be set", name, name)
+	}
+	if viper.IsSet(rateKey) {
+		rate, err := configPercent(viper.Get(rateKey))
+		if err != nil {
+			return nil, fmt.Errorf("tax %s-rate: %s", name, err)
+		}
+		return []taxBracket{{Rate: rate}}, nil
+	}
+
+	var raw []struct {
+		Over float64     `mapstructure:"over"`
+		Rate interface{} `mapstructure:"rate"`
+	}
+	err := viper.UnmarshalKey(bracketsKey, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tax %s-brackets: %s", name, err)
+	}
+	var brackets []taxBracket
+	for i, r := range raw {
+		rate, err := configPercent(r.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("tax %s-brackets %d: %s", name, i+1, err)
+		}
+		brackets = append(brackets, taxBracket{Over: r.Over, Rate: rate})
+	}
+	sort.Slice(brackets, func(i, j int) bool { return brackets[i].Over < brackets[j].Over })
+	return brackets, nil
+}
+
+// taxOn returns the tax a schedule levies on income.
+func taxOn(brackets []taxBracket, income float64) float64 {
+	tax := 0.0
+	for i, b := range brackets {
+		if income <= b.Over {
+			break
+		}
+		top := income
+		if i+1 < len(brackets) && brackets[i+1].Over < income {
+			top = brackets[i+1].Over
+		}
+		tax += (top - b.Over) * b.Rate
+	}
+	return tax
+}
+
+// owed estimates the tax on amount of extra income: national tax and any
+// surcharge on it, state tax and social insurance.
+func (t taxRates) owed(amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	total := t.Income + amount
+	extra := func(brackets []taxBracket) float64 {
+		return taxOn(brackets, total) - taxOn(brackets, t.Income)
+	}
+	return extra(t.Federal)*(1+t.Surcharge) + extra(t.State) + extra(t.Social)
+}
+
+// incomeTax is the tax on amount of extra income, leaving out social
+// insurance, which isn't due on investment income.
+func (t taxRates) incomeTax(amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	total := t.Income + amount
+	return (taxOn(t.Federal, total)-taxOn(t.Federal, t.Income))*(1+t.Surcharge) +
+		taxOn(t.State, total) - taxOn(t.State, t.Income)
+}
+
+// gainsTax estimates the tax on selling shares with short-term and
+// long-term capital gains, netting the two together. State tax applies to
+// gains as to income.
+func (t taxRates) gainsTax(short, long float64) float64 {
+	ordinary, gains := 0.0, short+long
+	if t.module.ShortTermAsIncome {
+		// a loss of either term offsets a gain of the other
+		switch {
+		case short >= 0 && long >= 0:
+			ordinary, gains = short, long
+		case short > 0:
+			ordinary, gains = short+long, 0
+		default:
+			ordinary, gains = 0, short+long
+		}
+	}
+	ordinary = math.Max(ordinary, 0) * t.module.GainsInclusion
+	gains = math.Max(gains, 0) * t.module.GainsInclusion
+	if t.module.Gains == nil {
+		return t.incomeTax(ordinary + gains)
+	}
+
+	tax := t.incomeTax(ordinary)
+	base := t.Income + ordinary
+	tax += taxOn(t.module.Gains, base+gains) - taxOn(t.module.Gains, base)
+	tax += taxOn(t.State, base+gains) - taxOn(t.State, base)
+	return tax
+}
+
+// optionIncome is the part of spread, made exercising options in g, that's
+// taxed as income.
+func (t taxRates) optionIncome(g grant, spread float64) float64 {
+	return spread * t.module.OptionInclusion(g)
+}
+
+// withheld is what supplemental withholding takes from amount.
+func (t taxRates) withheld(amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	return amount * t.Supplemental
+}
+
+// applyTax estimates the tax on the vested, unsold shares' value and what's
+// left after it.
+func (v *valuation) applyTax(t taxRates) {
+	v.Tax = t
+	if !t.Set {
+		return
+	}
+	v.VestedTax = t.owed(v.VestedValue)
+	v.VestedWithheld = t.withheld(v.VestedValue)
+	v.VestedAfterTax = v.VestedValue - v.VestedTax
+}
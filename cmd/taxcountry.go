@@ -0,0 +1,110 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+// taxCountry holds how one country taxes equity compensation: its default
+// income tax brackets (for a single filer, 2025), any surcharge levied on
+// the income tax itself, the employee's social insurance contributions, and
+// how much of an option's spread counts as income on exercise. For capital
+// gains, it holds the brackets applied to gains stacked on top of income
+// (none meaning they're taxed as income), how much of a gain is taxable,
+// and whether short-term gains are taxed as income instead. Settings in the
+// config override the income tax brackets. These are rough figures, meant
+// for estimates only.
+type taxCountry struct {
+	Name              string
+	National          []taxBracket
+	Surcharge         float64
+	Social            []taxBracket
+	OptionInclusion   func(g grant) float64
+	Gains             []taxBracket
+	GainsInclusion    float64
+	ShortTermAsIncome bool
+}
+
+// taxCountries are the countries the tax estimates know about, selected by
+// the tax country setting.
+var taxCountries = map[string]taxCountry{
+	"us": {
+		Name: "United States",
+		National: []taxBracket{
+			{0, 0.10}, {11925, 0.12}, {48475, 0.22}, {103350, 0.24},
+			{197300, 0.32}, {250525, 0.35}, {626350, 0.37},
+		},
+		// social security up to its wage base, then medicare and the
+		// additional medicare tax
+		Social: []taxBracket{{0, 0.0765}, {176100, 0.0145}, {200000, 0.0235}},
+		// ISOs aren't taxed as income on exercise (but see worth amt)
+		OptionInclusion: func(g grant) float64 {
+			if g.Type == "iso" {
+				return 0
+			}
+			return 1
+		},
+		// long-term rates, with the net investment income tax above $200,000
+		Gains:             []taxBracket{{0, 0}, {48350, 0.15}, {200000, 0.188}, {533400, 0.238}},
+		GainsInclusion:    1,
+		ShortTermAsIncome: true,
+	},
+	"uk": {
+		Name: "United Kingdom",
+		// the personal allowance tapers away between £100,000 and
+		// £125,140, taxing that band at an effective 60%
+		National: []taxBracket{
+			{0, 0}, {12570, 0.20}, {50270, 0.40}, {100000, 0.60}, {125140, 0.45},
+		},
+		// class 1 national insurance
+		Social:          []taxBracket{{0, 0}, {12570, 0.08}, {50270, 0.02}},
+		OptionInclusion: func(grant) float64 { return 1 },
+		// capital gains tax at the basic and higher rates
+		Gains:          []taxBracket{{0, 0.18}, {50270, 0.24}},
+		GainsInclusion: 1,
+	},
+	"ca": {
+		Name: "Canada",
+		// federal rates, with the basic personal amount as a zero band
+		National: []taxBracket{
+			{0, 0}, {16129, 0.15}, {57375, 0.205}, {114750, 0.26},
+			{177882, 0.29}, {253414, 0.33},
+		},
+		// CPP, CPP2 and EI up to their maximums
+		Social: []taxBracket{{0, 0.0759}, {65700, 0.0595}, {71300, 0.04}, {81200, 0}},
+		// the stock option deduction halves the taxable spread
+		OptionInclusion: func(grant) float64 { return 0.5 },
+		// half of a capital gain is taxed as income
+		GainsInclusion: 0.5,
+	},
+	"de": {
+		Name: "Germany",
+		// the progressive zones are approximated by their average rates
+		National: []taxBracket{
+			{0, 0}, {12096, 0.19}, {17443, 0.33}, {68481, 0.42}, {277826, 0.45},
+		},
+		// solidarity surcharge
+		Surcharge: 0.055,
+		// pension, unemployment, health and care insurance up to their
+		// contribution ceilings
+		Social:          []taxBracket{{0, 0.2105}, {66150, 0.106}, {96600, 0}},
+		OptionInclusion: func(grant) float64 { return 1 },
+		// the flat tax on investment income, with solidarity surcharge
+		Gains:          []taxBracket{{0, 0.26375}},
+		GainsInclusion: 1,
+	},
+}
@@ -0,0 +1,88 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+)
+
+// tender is selling into a company tender offer: up to Cap of each grant's
+// vested shares at Price, with vested options exercised and sold together.
+type tender struct {
+	Price          float64
+	Cap            float64
+	Shares         float64
+	Proceeds       float64
+	Tax            float64
+	Remaining      float64
+	RemainingValue float64
+}
+
+// applyTender works out what selling into a tender offer at price, capped
+// at cap of the vested shares, would bring in, and what's left afterwards.
+// The proceeds are taxed as income, as the other estimates are.
+func (v *valuation) applyTender(price, cap float64) {
+	t := &tender{Price: price, Cap: cap}
+	for _, g := range v.Grants {
+		held := g.SharesVestedUnsold
+		n := held * cap
+		if g.isOption() && price <= g.StrikePrice {
+			n = 0
+		}
+		t.Shares += n
+		t.Proceeds += n * (price - g.StrikePrice)
+		t.Remaining += held - n + g.SharesUnvested
+		if !g.isOption() || v.Price > g.StrikePrice {
+			t.RemainingValue += (held - n + g.SharesUnvested) * (v.Price - g.StrikePrice)
+		}
+	}
+	if v.Tax.Set {
+		t.Tax = v.Tax.owed(t.Proceeds)
+	}
+	v.Tender = t
+}
+
+// formatTender prints the --tender-price scenario.
+func formatTender(v valuation) {
+	ac := v.money()
+	t := v.Tender
+	if t.Shares <= 0 {
+		fmt.Printf("None of your vested shares can be sold at %s.\n", ac.FormatMoney(t.Price))
+		return
+	}
+	premium := (t.Price - v.Price) / v.Price * 100
+	direction := "above"
+	if premium < 0 {
+		direction = "below"
+	}
+	fmt.Printf("The tender offer at %s is %.1f%% %s %s's %s; you can sell up to %.0f%% of your vested shares.\n",
+		ac.FormatMoney(t.Price), math.Abs(premium), direction, v.Ticker, ac.FormatMoney(v.Price), t.Cap*100)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s shares at %s\t%s\t\n", formatShares(t.Shares), ac.FormatMoney(t.Price), ac.FormatMoney(t.Proceeds))
+	if v.Tax.Set {
+		fmt.Fprintf(w, "Estimated tax\t-%s\t\n", ac.FormatMoney(t.Tax))
+	}
+	fmt.Fprintf(w, "Net proceeds\t%s\t\n", ac.FormatMoney(t.Proceeds-t.Tax))
+	w.Flush()
+	fmt.Printf("Afterwards you'd still hold %s shares, vested or not, worth %s.\n", formatShares(t.Remaining), ac.FormatMoney(t.RemainingValue))
+}
@@ -0,0 +1,166 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// trendReferences are the dates the trends setting can measure change
+// since, described for the output.
+var trendReferences = map[string]string{
+	"last-vest": "your last vest",
+	"jan-1":     "Jan 1",
+	"grant":     "your grant",
+}
+
+// trend is how the price, and the vested value if a snapshot was taken
+// within a week of it, have changed since a reference date. Price is the close that day,
+// zero if the price history doesn't go back that far.
+type trend struct {
+	Since       string
+	Date        time.Time
+	Price       float64
+	VestedValue *float64
+}
+
+// loadTrends measures the change since each date named by the trends
+// setting, from the local price history and the snapshot history.
+func loadTrends(v valuation, now time.Time) ([]trend, error) {
+	var trends []trend
+	for _, since := range viper.GetStringSlice("trends") {
+		if _, ok := trendReferences[since]; !ok {
+			return nil, fmt.Errorf("invalid trends %q: expected last-vest, jan-1 or grant", since)
+		}
+		t := trend{Since: since}
+		switch since {
+		case "last-vest":
+			for _, g := range v.Grants {
+				if g.isESPP() {
+					continue
+				}
+				for _, e := range g.pastVests(now) {
+					if e.Date.After(t.Date) {
+						t.Date = e.Date
+					}
+				}
+			}
+		case "jan-1":
+			t.Date = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		case "grant":
+			for _, g := range v.Grants {
+				if !g.isESPP() && (t.Date.IsZero() || g.grantDate().Before(t.Date)) {
+					t.Date = g.grantDate()
+				}
+			}
+		}
+		if !t.Date.IsZero() && t.Date.Before(now) {
+			trends = append(trends, t)
+		}
+	}
+	if len(trends) == 0 {
+		return nil, nil
+	}
+
+	earliest := now
+	for _, t := range trends {
+		if t.Date.Before(earliest) {
+			earliest = t.Date
+		}
+	}
+	var prices []pricePoint
+	if v.Private == nil {
+		var err error
+		prices, err = pricesSince(v.Ticker, earliest, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+	snapshots, err := loadSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for i, t := range trends {
+		if len(prices) > 0 && !prices[0].Date.After(t.Date) {
+			trends[i].Price = closeOn(prices, t.Date)
+		}
+		if s, ok := snapshotSince(snapshots, v, t.Date); ok {
+			trends[i].VestedValue = &s.VestedValue
+		}
+	}
+	return trends, nil
+}
+
+// snapshotSince finds the first snapshot taken of position in the week from
+// t, whether on its own or as part of a portfolio.
+func snapshotSince(snapshots []snapshot, v valuation, t time.Time) (snapshot, bool) {
+	for _, s := range snapshots {
+		if s.Time.Before(t) || s.Time.After(t.AddDate(0, 0, 7)) {
+			continue
+		}
+		for _, p := range append([]snapshot{s}, s.Positions...) {
+			if p.Ticker == v.Ticker && p.Position == v.Position {
+				return p, true
+			}
+		}
+	}
+	return snapshot{}, false
+}
+
+// formatTrends prints a line for each trend, e.g. "Since Jan 1, IBM is up
+// 12.0% from $93.30, and your vested value is up $2,300.00 (4.1%)."
+func formatTrends(v valuation, ac moneyFormat) {
+	change := func(then, now float64) string {
+		direction := "up"
+		if now < then {
+			direction = "down"
+		}
+		return fmt.Sprintf("%s %.1f%%", direction, math.Abs(now-then)/then*100)
+	}
+	for _, t := range v.Trends {
+		since := "Since " + trendReferences[t.Since]
+		if t.Since != "jan-1" {
+			since += " on " + t.Date.Format("Jan 2, 2006")
+		}
+		var parts []string
+		if t.Price > 0 {
+			parts = append(parts, fmt.Sprintf("%s is %s from %s", v.Ticker, change(t.Price, v.Price), ac.FormatMoney(t.Price)))
+		}
+		if t.VestedValue != nil && *t.VestedValue > 0 {
+			moved := v.VestedValue - *t.VestedValue
+			direction := "up"
+			if moved < 0 {
+				direction = "down"
+			}
+			parts = append(parts, fmt.Sprintf("your vested value is %s %s (%.1f%%)", direction, ac.FormatMoney(math.Abs(moved)),
+				math.Abs(moved) / *t.VestedValue * 100))
+		}
+		switch len(parts) {
+		case 1:
+			fmt.Printf("%s, %s.\n", since, parts[0])
+		case 2:
+			fmt.Printf("%s, %s, and %s.\n", since, parts[0], parts[1])
+		}
+	}
+}
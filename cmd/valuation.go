@@ -0,0 +1,521 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// valuation holds everything computed for a single run, shared by the text
+// and JSON output formats.
+type valuation struct {
+	Position           string
+	Holding            bool
+	Class              string
+	Currency           string
+	HomeCurrency       string
+	ExchangeRate       float64
+	Exchange           string
+	MarketOpen         bool
+	TradingDay         time.Time
+	Concentration      float64
+	ConcentrationLimit float64
+	Private            *privatePrice
+	PreferredValue     float64
+	Ownership          float64
+	DilutedPrice       float64
+	DilutedValue       float64
+	AsOf               time.Time
+	SinceLast          *sinceLast
+	Trends             []trend
+	QuitOn             time.Time
+	Ticker             string
+	Price              float64
+	// QuotedPrice is the real price when Price is a hypothetical one, given
+	// with --at-price.
+	QuotedPrice        float64
+	StrikePrice        float64
+	Shares             float64
+	SharesSold         float64
+	PortionDone        float64
+	SharesVested       float64
+	SharesUnvested     float64
+	SharesVestedUnsold float64
+	TotalValue         float64
+	VestedValue        float64
+	UnvestedValue      float64
+	VestStart          time.Time
+	VestEnd            time.Time
+	Cliff              time.Time
+	CliffShares        float64
+	Remaining          time.Duration
+	Change             float64
+	ChangePercent      float64
+	VestedChange       float64
+	YearHigh           float64
+	YearLow            float64
+	RangePosition      float64
+	Grants             []grantValuation
+	Projected          []grantValuation
+	ProjectedShares    float64
+	ProjectedValue     float64
+	AccelSingle        float64
+	AccelDouble        float64
+	AccelSingleValue   float64
+	AccelDoubleValue   float64
+	SharesSellable     float64
+	SellableValue      float64
+	WindowOpens        time.Time
+	NextBlackout       time.Time
+	TerminatedOn       time.Time
+	ExerciseCost       float64
+	Tax                taxRates
+	// VestingPerDay is the value vesting each day on average over the
+	// coming year, at today's price.
+	VestingPerDay    float64
+	VestedTax        float64
+	VestedWithheld   float64
+	VestedAfterTax   float64
+	DividendYield    float64
+	DividendIncome   float64
+	DividendsPaid    float64
+	DividendsAccrued float64
+	ReinvestedShares float64
+	ReinvestedValue  float64
+	// Model is set when options are valued with Black-Scholes, which
+	// values them at OptionValue rather than OptionSpreadValue. Delta,
+	// Theta and Vega are the greeks of all the options together.
+	Model             *optionModel
+	OptionValue       float64
+	OptionSpreadValue float64
+	Delta             float64
+	Theta             float64
+	Vega              float64
+	// Salary is the configured annual salary, for comparing with what
+	// leaving would forfeit.
+	Salary float64
+	// TargetPrice is the price needed for everything held to be worth
+	// TargetValue after fees and taxes, with --target-value.
+	TargetValue     float64
+	TargetPrice     float64
+	TargetReachable bool
+	// Tender is selling into a tender offer, with --tender-price.
+	Tender *tender
+}
+
+// grantValuation is the share of a valuation contributed by one grant.
+type grantValuation struct {
+	grant
+	SharesVested       float64
+	SharesUnvested     float64
+	SharesVestedUnsold float64
+	VestedValue        float64
+	UnvestedValue      float64
+	NextVest           vestEvent
+	AccelSingle        float64
+	AccelDouble        float64
+	ExerciseDeadline   time.Time
+	ExerciseCost       float64
+	ExpiryWarning      bool
+}
+
+// loadValuation parses the vesting dates, fetches today's quote and computes
+// the valuation as of now, of the grants in the configured ticker (see
+// loadPositions). Without a configured ticker the first position's is used.
+func loadValuation(now time.Time) (valuation, error) {
+	positions, err := loadPositions()
+	if err != nil {
+		return valuation{}, err
+	}
+	return positions[0].valuation(now)
+}
+
+// valuation values the position's grants as of now, with the quote and the
+// other data fetched for the position's ticker.
+func (p position) valuation(now time.Time) (valuation, error) {
+	grants := p.Grants
+
+	// a private company's shares are valued at its 409A price, without
+	// asking for a quote
+	var quote JsonQuote
+	var overview JsonOverview
+	var price, change, changePercent float64
+	var err error
+	switch {
+	case p.FixedPrice > 0:
+		price = p.FixedPrice
+	case p.Private != nil:
+		price = p.Private.value()
+	default:
+		quote, err = getQuote(p.Ticker)
+		if err != nil {
+			return valuation{}, err
+		}
+		price, err = strconv.ParseFloat(quote.GlobalQuote.Price, 64)
+		if err != nil {
+			return valuation{}, err
+		}
+		// the day's change is only shown alongside, so a quote that comes
+		// without one (as they sometimes do) just has none
+		change, changePercent, _ = quote.change()
+		overview = cachedOverview(p.Ticker)
+	}
+	if p.Multiplier > 0 {
+		price *= p.Multiplier
+		change *= p.Multiplier
+	}
+	quoted := price
+	if at := viper.GetFloat64("at-price"); at > 0 {
+		price = at
+	}
+	windows, err := loadBlackouts()
+	if err != nil {
+		return valuation{}, err
+	}
+	tax, err := loadTax()
+	if err != nil {
+		return valuation{}, err
+	}
+	risk, err := loadConcentration()
+	if err != nil {
+		return valuation{}, err
+	}
+	years, months, days, err := parseSpan(viper.GetString("expiration-warning"))
+	if err != nil {
+		return valuation{}, fmt.Errorf("expiration-warning: %s", err)
+	}
+
+	sales, err := loadLedger()
+	if err != nil {
+		return valuation{}, err
+	}
+
+	v := valuate(grants, price, now, sales)
+	v.Position, v.Ticker, v.Currency, v.Holding, v.Class = p.Name, p.Ticker, stockCurrency(p, overview), p.Holding, p.Class
+	if price != quoted {
+		v.QuotedPrice = quoted
+	}
+	if v.Ticker == "" {
+		v.Ticker = p.Name
+	}
+	if p.Private != nil && p.FixedPrice == 0 {
+		v.applyPrivate(p.Private, grants, sales)
+	} else if p.quoted() {
+		market := symbolExchange(p.Ticker)
+		v.Exchange, v.MarketOpen = market.Name, market.isOpen(now)
+		v.TradingDay, _ = time.Parse("2006-01-02", quote.GlobalQuote.LatestTradingDay)
+	}
+	if home := homeCurrency(); home != "" {
+		err = v.convertTo(home)
+		if err != nil {
+			return valuation{}, err
+		}
+	}
+	v.Salary = viper.GetFloat64("salary")
+	v.applyChange(change, changePercent)
+	v.applyRange(overview.weekRange())
+	// the dividend stays the same at a hypothetical price, so its yield doesn't
+	yield := overview.dividendYield()
+	if price != quoted {
+		yield *= quoted / price
+	}
+	v.applyYield(yield)
+	if viper.GetBool("dividends") && p.quoted() {
+		dividends, err := getDividends(p.Ticker)
+		if err != nil {
+			return valuation{}, err
+		}
+		var prices []pricePoint
+		if viper.GetBool("drip") && len(dividends) > 0 {
+			prices, err = pricesSince(p.Ticker, v.VestStart, now)
+			if err != nil {
+				return valuation{}, err
+			}
+		}
+		v.applyDividends(dividends, sales, prices, now)
+	}
+	if viper.GetBool("black-scholes") {
+		m, err := loadOptionModel(p.Ticker, v.DividendYield)
+		if err != nil {
+			return valuation{}, err
+		}
+		v.applyBlackScholes(m)
+	}
+	v.applyBlackouts(windows)
+	v.applyExpirations(addSpan(now, years, months, days))
+	v.applyTax(tax)
+	v.applyConcentration(risk)
+	v.applyProjections(projectRefreshers(grants, now, viper.GetInt("projection-years")))
+	return v, nil
+}
+
+// valuate values the grants at price as of now. Shares sold, those in the
+// ledger up to now and any given with --shares sold, are taken from the
+// grants' vested shares: sales recorded against a grant from that grant,
+// and the rest in the order the grants are configured.
+func valuate(grants []grant, price float64, now time.Time, sales []saleRecord) valuation {
+	v := valuation{
+		AsOf:       now,
+		Price:      price,
+		SharesSold: sharesSold,
+	}
+	if len(grants) > 0 {
+		v.Ticker = grants[0].Ticker
+	}
+
+	espp := map[string]bool{}
+	for _, g := range grants {
+		espp[g.Name] = g.isESPP()
+	}
+	byGrant := map[string]float64{}
+	for _, s := range sales {
+		// shares bought through an ESPP were never vested by a grant
+		if !s.Date.After(now) && !espp[s.Grant] {
+			v.SharesSold += s.Shares
+			byGrant[s.Grant] += s.Shares
+		}
+	}
+	specific := make([]float64, len(grants))
+	unallocated := v.SharesSold
+	for i, g := range grants {
+		specific[i] = math.Min(byGrant[g.Name], g.vestedShares(now))
+		unallocated -= specific[i]
+	}
+
+	strikeTotal := 0.0
+	yearAhead := 0.0
+	for i, g := range grants {
+		gv := grantValuation{grant: g}
+		gv.SharesVested = g.vestedShares(now)
+		gv.SharesUnvested = g.Shares - gv.SharesVested
+
+		sold := specific[i] + math.Min(unallocated, gv.SharesVested-specific[i])
+		if i == len(grants)-1 {
+			sold = specific[i] + unallocated
+		}
+		unallocated -= sold - specific[i]
+		gv.SharesVestedUnsold = gv.SharesVested - sold
+
+		// subtract the strike price to get the take away value for your shares...
+		value := price - g.StrikePrice
+		gv.VestedValue = gv.SharesVestedUnsold * value
+		gv.UnvestedValue = gv.SharesUnvested * value
+		yearAhead += (g.vestedShares(now.AddDate(1, 0, 0)) - gv.SharesVested) * value
+		if next := g.upcomingVests(now); len(next) > 0 {
+			gv.NextVest = next[0]
+		}
+
+		// double trigger acceleration applies to whatever single trigger leaves unvested
+		gv.AccelSingle = gv.SharesUnvested * g.SingleTrigger
+		gv.AccelDouble = (gv.SharesUnvested - gv.AccelSingle) * g.DoubleTrigger
+		v.AccelSingle += gv.AccelSingle
+		v.AccelDouble += gv.AccelDouble
+		v.AccelSingleValue += gv.AccelSingle * value
+		v.AccelDoubleValue += gv.AccelDouble * value
+
+		v.Shares += g.Shares
+		v.SharesVested += gv.SharesVested
+		v.SharesUnvested += gv.SharesUnvested
+		v.SharesVestedUnsold += gv.SharesVestedUnsold
+		v.TotalValue += g.Shares * value
+		v.VestedValue += gv.VestedValue
+		v.UnvestedValue += gv.UnvestedValue
+		strikeTotal += g.Shares * g.StrikePrice
+		if i == 0 || g.Start.Before(v.VestStart) {
+			v.VestStart = g.Start
+		}
+		if g.vestEnd().After(v.VestEnd) {
+			v.VestEnd = g.vestEnd()
+		}
+		v.Grants = append(v.Grants, gv)
+	}
+
+	v.VestingPerDay = yearAhead / daysPerYear
+	if v.Shares > 0 {
+		v.PortionDone = v.SharesVested / v.Shares
+		v.StrikePrice = strikeTotal / v.Shares
+	}
+	if len(grants) == 1 {
+		if !grants[0].Cliff.IsZero() {
+			v.Cliff = grants[0].actual(grants[0].Cliff)
+			v.CliffShares = grants[0].vestedShares(v.Cliff)
+		}
+	}
+	v.Remaining = v.VestEnd.Sub(now)
+	if v.Remaining < 0 {
+		v.Remaining = 0
+	}
+	return v
+}
+
+// daysPerYear averages out leap years.
+const daysPerYear = 365.25
+
+// salaryMonths is how many months of salary the unvested shares are worth.
+func (v valuation) salaryMonths() float64 {
+	if v.Salary <= 0 {
+		return 0
+	}
+	return v.UnvestedValue / (v.Salary / 12)
+}
+
+// upcomingVests returns the vest events still to come across all grants.
+func (v valuation) upcomingVests(now time.Time) []vestEvent {
+	var grants []grant
+	for _, gv := range v.Grants {
+		grants = append(grants, gv.grant)
+	}
+	return upcomingVests(grants, now)
+}
+
+// applyChange records the day's price movement and how much it moved the
+// value of the vested, unsold shares.
+func (v *valuation) applyChange(change, percent float64) {
+	v.Change = change
+	v.ChangePercent = percent
+	v.VestedChange = v.SharesVestedUnsold * change
+}
+
+// priceName says which price the valuation is at, for the report's prose.
+func (v valuation) priceName() string {
+	if v.QuotedPrice > 0 {
+		return "that price"
+	}
+	return "today's price"
+}
+
+// applyRange records the 52-week range and where today's price falls within
+// it, from 0 at the low to 1 at the high. A zero range is left unset.
+func (v *valuation) applyRange(low, high float64) {
+	if high <= low {
+		return
+	}
+	v.YearLow = low
+	v.YearHigh = high
+	v.RangePosition = math.Min(math.Max((v.Price-low)/(high-low), 0), 1)
+}
+
+// applyYield records the dividend yield and the dividends it would pay over
+// a year on the vested, unsold shares. Options get no dividends.
+func (v *valuation) applyYield(yield float64) {
+	v.DividendYield = yield
+	for _, g := range v.Grants {
+		if !g.isOption() {
+			v.DividendIncome += g.SharesVestedUnsold * v.Price * yield
+		}
+	}
+}
+
+// applyDividends adds up the dividends on the vested shares held on each
+// ex-dividend date up to now: paid once their payment date has passed, and
+// accrued until then. Shares sold before an ex-dividend date don't count,
+// nor do options or shares bought through an ESPP. Given closing prices, paid
+// dividends are reinvested at the close on their payment date, and the
+// shares they buy earn dividends in turn.
+func (v *valuation) applyDividends(dividends []dividend, sales []saleRecord, prices []pricePoint, now time.Time) {
+	espp := map[string]bool{}
+	for _, g := range v.Grants {
+		espp[g.Name] = g.isESPP()
+	}
+	for _, d := range dividends {
+		if d.ExDate.After(now) {
+			continue
+		}
+		held := 0.0
+		for _, g := range v.Grants {
+			if !g.isOption() {
+				held += g.vestedShares(d.ExDate)
+			}
+		}
+		for _, s := range sales {
+			if s.Date.Before(d.ExDate) && !espp[s.Grant] {
+				held -= s.Shares
+			}
+		}
+		if held <= 0 {
+			continue
+		}
+		held += v.ReinvestedShares
+		if d.PayDate.After(now) {
+			v.DividendsAccrued += held * d.Amount
+			continue
+		}
+		v.DividendsPaid += held * d.Amount
+		if price := closeOn(prices, d.PayDate); price > 0 {
+			v.ReinvestedShares += held * d.Amount / price
+		}
+	}
+	v.ReinvestedValue = v.ReinvestedShares * v.Price
+}
+
+// totalReturn is the value of the vested, unsold shares with the dividends
+// they've earned, reinvested or not.
+func (v valuation) totalReturn() float64 {
+	if v.ReinvestedShares > 0 {
+		return v.VestedValue + v.ReinvestedValue + v.DividendsAccrued
+	}
+	return v.VestedValue + v.DividendsPaid + v.DividendsAccrued
+}
+
+// applyExpirations flags option grants with vested, in-the-money options
+// still unexercised that expire before horizon, or have already expired.
+func (v *valuation) applyExpirations(horizon time.Time) {
+	for i := range v.Grants {
+		g := &v.Grants[i]
+		if !g.Expires.IsZero() && g.Expires.Before(horizon) && g.SharesVestedUnsold > 0 && v.Price > g.StrikePrice {
+			g.ExpiryWarning = true
+		}
+	}
+}
+
+// applyTermination works out, for each option grant, when the
+// post-termination exercise window closes after leaving on terminated, and
+// the cash needed to exercise the vested options before then.
+func (v *valuation) applyTermination(terminated time.Time) {
+	v.TerminatedOn = terminated
+	for i := range v.Grants {
+		g := &v.Grants[i]
+		if !g.isOption() {
+			continue
+		}
+		g.ExerciseDeadline = g.exerciseDeadline(terminated)
+		g.ExerciseCost = g.SharesVestedUnsold * g.StrikePrice
+		v.ExerciseCost += g.ExerciseCost
+	}
+}
+
+// applyProjections values the projected refresher grants at today's price.
+// They are kept apart from the real grants and never counted in the totals.
+func (v *valuation) applyProjections(projected []grant) {
+	for _, g := range projected {
+		gv := grantValuation{grant: g}
+		gv.SharesUnvested = g.Shares
+		gv.UnvestedValue = g.Shares * (v.Price - g.StrikePrice)
+		v.Projected = append(v.Projected, gv)
+		v.ProjectedShares += g.Shares
+		v.ProjectedValue += gv.UnvestedValue
+	}
+}
@@ -0,0 +1,478 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// vestEvent is a date on which a block of shares vests.
+type vestEvent struct {
+	Date        time.Time
+	Shares      float64
+	Grant       string
+	StrikePrice float64
+}
+
+// leave is a leave of absence, during which the vesting clock is paused.
+type leave struct {
+	Start time.Time
+	End   time.Time
+}
+
+// tranche is an explicitly configured vest event, for grants whose schedule
+// doesn't follow a regular pattern.
+type tranche struct {
+	Date   time.Time
+	Shares float64
+}
+
+// frequencyMonths maps vest-frequency settings to the months between vests.
+// Continuous vesting (the default) accrues second by second.
+var frequencyMonths = map[string]int{
+	"":           0,
+	"continuous": 0,
+	"monthly":    1,
+	"quarterly":  3,
+	"annual":     12,
+	"annually":   12,
+	"yearly":     12,
+}
+
+// dateLayouts are the date formats accepted in the config and on the command
+// line, tried in order.
+var dateLayouts = []string{
+	"2006-01-02",
+	time.RFC3339,
+	time.RFC1123,
+	time.RFC1123Z,
+	"2006/01/02",
+	"Jan 2 2006",
+	"Jan 2, 2006",
+	"January 2 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Mon Jan 2 2006",
+	"Mon, Jan 2, 2006",
+}
+
+// parseDate parses a date written in any of the accepted formats, such as
+// 2020-03-01, Mar 1 2020 or an RFC 1123 timestamp.
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q; accepted formats include 2020-03-01, Mar 1 2020, "+
+		"March 1, 2020, 1 Mar 2020, 2020-03-01T00:00:00Z and Sun, 01 Mar 2020 00:00:00 UTC", s)
+}
+
+// configDate converts a date read from the config, which YAML may already
+// have decoded into a time.Time, or left as a string.
+func configDate(v interface{}) (time.Time, error) {
+	switch d := v.(type) {
+	case time.Time:
+		return vestDay(d), nil
+	case string:
+		t, err := parseDate(d)
+		return vestDay(t), err
+	}
+	return time.Time{}, fmt.Errorf("invalid date %v", v)
+}
+
+// loadTimezone reads the timezone setting. Vest dates are calendar dates,
+// not instants, so when a timezone is configured every vest boundary falls
+// at midnight there.
+func loadTimezone() error {
+	vestLocation = nil
+	name := viper.GetString("timezone")
+	if name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %s", name, err)
+	}
+	vestLocation = loc
+	return nil
+}
+
+// vestDay anchors a configured date at midnight in the configured timezone,
+// keeping the calendar date as written. Without a timezone it is unchanged.
+func vestDay(t time.Time) time.Time {
+	if vestLocation == nil || t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, vestLocation)
+}
+
+// addMonths adds n calendar months to t. Where the day doesn't exist in the
+// target month it is clamped to the month's last day, the way vesting
+// agreements treat e.g. a grant on the 31st, rather than overflowing into
+// the following month as time.AddDate does.
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := first.AddDate(0, n, 0)
+	last := target.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > last {
+		day = last
+	}
+	return target.AddDate(0, 0, day-1)
+}
+
+// addSpan adds a calendar span of years, months and days to t.
+func addSpan(t time.Time, years, months, days int) time.Time {
+	return addMonths(t, years*12+months).AddDate(0, 0, days)
+}
+
+var spanPattern = regexp.MustCompile(`^(?:(\d+)y)?(?:(\d+)m)?(?:(\d+)d)?$`)
+
+// parseSpan parses a calendar span such as "1y", "18m", "90d" or "1y6m" into
+// years, months and days.
+func parseSpan(s string) (int, int, int, error) {
+	m := spanPattern.FindStringSubmatch(s)
+	if m == nil || s == "" {
+		return 0, 0, 0, fmt.Errorf("invalid span %q (expected e.g. 1y, 6m, 90d or 1y6m)", s)
+	}
+	var parts [3]int
+	for i, p := range m[1:] {
+		if p != "" {
+			parts[i], _ = strconv.Atoi(p)
+		}
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// linearPortion is the fraction of the grant accrued by t, ignoring the cliff
+// and vest-frequency steps. Without year-weights this is simply the fraction
+// of the vesting period elapsed.
+func (g grant) linearPortion(t time.Time) float64 {
+	if len(g.YearWeights) > 0 {
+		return g.weightedPortion(t)
+	}
+	portion := float64(t.Unix()-g.Start.Unix()) / float64(g.End.Unix()-g.Start.Unix())
+	return math.Min(math.Max(portion, 0), 1)
+}
+
+// weightedPortion accrues each year's weight over the vest events in that
+// year: in proportion to time elapsed for continuous vesting, or equally per
+// scheduled vest date otherwise.
+func (g grant) weightedPortion(t time.Time) float64 {
+	total := 0.0
+	for _, w := range g.YearWeights {
+		total += w
+	}
+
+	done := 0.0
+	for i, w := range g.YearWeights {
+		from, to := addMonths(g.Start, i*12), addMonths(g.Start, (i+1)*12)
+		if !t.Before(to) {
+			done += w
+			continue
+		}
+		if t.After(from) {
+			done += w * g.yearFraction(t, from, to)
+		}
+		break
+	}
+	return done / total
+}
+
+// yearFraction is how much of the vesting year [from, to) has vested by t.
+func (g grant) yearFraction(t, from, to time.Time) float64 {
+	if g.Months == 0 {
+		return float64(t.Unix()-from.Unix()) / float64(to.Unix()-from.Unix())
+	}
+	n := 12 / g.Months
+	k := 0
+	for i := 1; i <= n; i++ {
+		if !addMonths(from, i*g.Months).After(t) {
+			k++
+		}
+	}
+	return float64(k) / float64(n)
+}
+
+// portionVested returns the fraction of the grant vested at t, between 0 and
+// 1, taking leaves of absence and business days into account.
+func (g grant) portionVested(t time.Time) float64 {
+	c := g.clock(t)
+	if g.Calendar != nil {
+		// a vest already due on the vesting clock may still be waiting for
+		// the next business day
+		for _, d := range g.deliveryDates() {
+			if !d.After(c) && g.actual(d).After(t) {
+				c = d.Add(-time.Nanosecond)
+				break
+			}
+		}
+	}
+	return g.nominalPortion(c)
+}
+
+// deliveryDates returns the dates as granted on which shares are actually
+// delivered. Continuous vesting only delivers at the cliff and vest-end.
+func (g grant) deliveryDates() []time.Time {
+	if g.Months > 0 || len(g.Tranches) > 0 {
+		return g.nominalDates()
+	}
+	if !g.Cliff.IsZero() && g.Cliff.Before(g.End) {
+		return []time.Time{g.Cliff, g.End}
+	}
+	return []time.Time{g.End}
+}
+
+// nominalPortion returns the fraction of the grant vested at t on the
+// schedule as granted. Nothing vests before the cliff; at the cliff
+// everything accrued since vest-start vests at once. With a vest-frequency,
+// shares only vest on the scheduled dates.
+func (g grant) nominalPortion(t time.Time) float64 {
+	if len(g.Tranches) > 0 {
+		vested, total := 0.0, 0.0
+		for _, tr := range g.Tranches {
+			if !tr.Date.After(t) {
+				vested += tr.Shares
+			}
+			total += tr.Shares
+		}
+		if total == 0 {
+			return 0
+		}
+		return vested / total
+	}
+	if t.Before(g.Cliff) {
+		return 0
+	}
+	if g.Months == 0 {
+		return g.linearPortion(t)
+	}
+	if len(g.YearWeights) == 0 {
+		// each scheduled vest delivers an equal share of the grant
+		dates := g.periodicDates()
+		k := 0
+		for _, date := range dates {
+			if !date.After(t) {
+				k++
+			}
+		}
+		return float64(k) / float64(len(dates))
+	}
+	portion := 0.0
+	for _, date := range g.nominalDates() {
+		if date.After(t) {
+			break
+		}
+		portion = g.linearPortion(date)
+	}
+	return portion
+}
+
+// leaveDays is how many whole days of a leave fall after the grant's
+// vest-start, and when that part of the leave begins.
+func (g grant) leaveDays(l leave) (time.Time, int) {
+	from := l.Start
+	if from.Before(g.Start) {
+		from = g.Start
+	}
+	if !l.End.After(from) {
+		return from, 0
+	}
+	return from, int(math.Round(l.End.Sub(from).Hours() / 24))
+}
+
+// clock converts a real time into time on the vesting clock, which stands
+// still during leaves of absence.
+func (g grant) clock(t time.Time) time.Time {
+	c := t
+	for _, l := range g.Leaves {
+		from, days := g.leaveDays(l)
+		switch {
+		case days == 0 || !t.After(from):
+		case !t.Before(l.End):
+			c = c.AddDate(0, 0, -days)
+		default:
+			c = c.Add(-t.Sub(from))
+		}
+	}
+	return c
+}
+
+// actual converts a date on the schedule as granted into the real date it
+// falls on once every earlier leave of absence has pushed it out, rolled to
+// a business day if configured.
+func (g grant) actual(d time.Time) time.Time {
+	shift := 0
+	for _, l := range g.Leaves {
+		from, days := g.leaveDays(l)
+		if days > 0 && !d.AddDate(0, 0, shift).Before(from) {
+			shift += days
+		}
+	}
+	d = d.AddDate(0, 0, shift)
+	if g.Calendar != nil {
+		d = g.Calendar.roll(d)
+	}
+	return d
+}
+
+// vestEnd is the real date the grant is fully vested.
+func (g grant) vestEnd() time.Time {
+	return g.actual(g.End)
+}
+
+// vestedShares returns the number of shares vested at t. Brokerages deliver
+// whole shares on each vest date and roll the fractional remainder forward to
+// the next, so with discrete vesting and whole-shares enabled the cumulative
+// count is rounded down. Any fraction left in a grant of fractional shares
+// is delivered with the final vest.
+func (g grant) vestedShares(t time.Time) float64 {
+	portion := g.portionVested(t)
+	shares := g.Shares * portion
+	if g.Months > 0 && g.WholeShares && portion < 1 {
+		return math.Floor(shares + 1e-9)
+	}
+	return shares
+}
+
+// vestDates returns the real dates on which shares vest, after any leaves
+// of absence.
+func (g grant) vestDates() []time.Time {
+	var dates []time.Time
+	for _, d := range g.nominalDates() {
+		dates = append(dates, g.actual(d))
+	}
+	return dates
+}
+
+// nominalDates returns the dates on which shares vest as granted: the
+// configured tranches, or every vest-frequency period after vest-start, the
+// cliff and vest-end itself. With continuous vesting the anniversaries of
+// vest-start serve as checkpoints instead.
+func (g grant) nominalDates() []time.Time {
+	if len(g.Tranches) > 0 {
+		var dates []time.Time
+		for _, tr := range g.Tranches {
+			dates = append(dates, tr.Date)
+		}
+		return dates
+	}
+
+	dates := g.periodicDates()
+	if !g.Cliff.IsZero() && g.Cliff.Before(g.End) {
+		found := false
+		for _, date := range dates {
+			found = found || date.Equal(g.Cliff)
+		}
+		if !found {
+			dates = append(dates, g.Cliff)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
+}
+
+// periodicDates returns every vest-frequency period after vest-start up to
+// and including vest-end, or the anniversaries for continuous vesting.
+func (g grant) periodicDates() []time.Time {
+	step := g.Months
+	if step == 0 {
+		step = 12
+	}
+	var dates []time.Time
+	for i := 1; addMonths(g.Start, i*step).Before(g.End); i++ {
+		dates = append(dates, addMonths(g.Start, i*step))
+	}
+	return append(dates, g.End)
+}
+
+// upcomingVests returns the checkpoints still to come after now, along with
+// the shares that vest between each one and the previous checkpoint (or now,
+// for the first).
+func (g grant) upcomingVests(now time.Time) []vestEvent {
+	var events []vestEvent
+	prev := g.vestedShares(now)
+	for _, date := range g.vestDates() {
+		if !date.After(now) {
+			continue
+		}
+		vested := g.vestedShares(date)
+		if vested > prev {
+			events = append(events, vestEvent{
+				Date:        date,
+				Shares:      vested - prev,
+				Grant:       g.Name,
+				StrikePrice: g.StrikePrice,
+			})
+		}
+		prev = vested
+	}
+	return events
+}
+
+// pastVests returns the vest events up to now, each with the shares it
+// delivered. With continuous vesting, whatever has vested since the last
+// checkpoint is counted as delivered now.
+func (g grant) pastVests(now time.Time) []vestEvent {
+	var events []vestEvent
+	prev := 0.0
+	for _, date := range g.vestDates() {
+		if date.After(now) {
+			break
+		}
+		vested := g.vestedShares(date)
+		if vested > prev {
+			events = append(events, vestEvent{Date: date, Shares: vested - prev, Grant: g.Name, StrikePrice: g.StrikePrice})
+		}
+		prev = vested
+	}
+	if vested := g.vestedShares(now); vested > prev {
+		events = append(events, vestEvent{Date: now, Shares: vested - prev, Grant: g.Name, StrikePrice: g.StrikePrice})
+	}
+	return events
+}
+
+// upcomingVests merges the upcoming vest events of all grants by date.
+func upcomingVests(grants []grant, now time.Time) []vestEvent {
+	var events []vestEvent
+	for _, g := range grants {
+		events = append(events, g.upcomingVests(now)...)
+	}
+	sortVests(events)
+	return events
+}
+
+// sortVests orders vest events by date, keeping grant order for ties.
+func sortVests(events []vestEvent) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+}
@@ -0,0 +1,123 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// mustDate parses a YYYY-MM-DD date for a test table.
+func mustDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		from string
+		n    int
+		want string
+	}{
+		{"2025-12-15", 1, "2026-01-15"},
+		{"2025-01-31", 1, "2025-02-28"},
+		{"2024-01-31", 1, "2024-02-29"},
+		{"2025-08-31", 6, "2026-02-28"},
+		{"2025-03-31", -1, "2025-02-28"},
+		{"2025-05-31", 1, "2025-06-30"},
+		{"2025-01-15", 0, "2025-01-15"},
+		{"2024-02-29", 12, "2025-02-28"},
+	}
+	for _, tt := range tests {
+		got := addMonths(mustDate(tt.from), tt.n)
+		if !got.Equal(mustDate(tt.want)) {
+			t.Errorf("addMonths(%s, %d) = %s, want %s", tt.from, tt.n, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestParseSpan(t *testing.T) {
+	tests := []struct {
+		span                string
+		years, months, days int
+		ok                  bool
+	}{
+		{"1y", 1, 0, 0, true},
+		{"6m", 0, 6, 0, true},
+		{"90d", 0, 0, 90, true},
+		{"1y6m", 1, 6, 0, true},
+		{"2y3m10d", 2, 3, 10, true},
+		{"", 0, 0, 0, false},
+		{"1w", 0, 0, 0, false},
+		{"1d1y", 0, 0, 0, false},
+		{"y", 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		years, months, days, err := parseSpan(tt.span)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseSpan(%q) error = %v, want ok %t", tt.span, err, tt.ok)
+			continue
+		}
+		if years != tt.years || months != tt.months || days != tt.days {
+			t.Errorf("parseSpan(%q) = %d, %d, %d, want %d, %d, %d", tt.span, years, months, days, tt.years, tt.months, tt.days)
+		}
+	}
+}
+
+func TestVestedShares(t *testing.T) {
+	quarterly := grant{Shares: 4800, Start: mustDate("2024-01-15"), End: mustDate("2028-01-15"),
+		Cliff: mustDate("2025-01-15"), Months: 3}
+	linear := grant{Shares: 1000, Start: mustDate("2025-01-01"), End: mustDate("2026-01-01")}
+	whole := grant{Shares: 1000, Start: mustDate("2025-01-01"), End: mustDate("2025-04-01"), Months: 1, WholeShares: true}
+	tranches := grant{Shares: 300, Start: mustDate("2025-01-01"), End: mustDate("2027-01-01"), Months: 12,
+		Tranches: []tranche{{Date: mustDate("2025-06-01"), Shares: 100}, {Date: mustDate("2026-06-01"), Shares: 200}}}
+
+	tests := []struct {
+		name string
+		g    grant
+		at   time.Time
+		want float64
+	}{
+		{"before the cliff", quarterly, mustDate("2024-12-31"), 0},
+		{"at the cliff", quarterly, mustDate("2025-01-15"), 1200},
+		{"between vests", quarterly, mustDate("2025-03-01"), 1200},
+		{"next quarter", quarterly, mustDate("2025-04-15"), 1500},
+		{"at the end", quarterly, mustDate("2028-01-15"), 4800},
+		{"after the end", quarterly, mustDate("2030-01-01"), 4800},
+		{"before the start", linear, mustDate("2024-06-01"), 0},
+		{"continuous halfway", linear, linear.Start.Add(linear.End.Sub(linear.Start) / 2), 500},
+		{"whole shares rounded down", whole, mustDate("2025-02-01"), 333},
+		{"whole shares at the end", whole, mustDate("2025-04-01"), 1000},
+		{"before the first tranche", tranches, mustDate("2025-05-31"), 0},
+		{"first tranche", tranches, mustDate("2025-06-01"), 100},
+		{"both tranches", tranches, mustDate("2026-06-01"), 300},
+	}
+	for _, tt := range tests {
+		got := tt.g.vestedShares(tt.at)
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s: vestedShares(%s) = %v, want %v", tt.name, tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
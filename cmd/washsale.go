@@ -0,0 +1,129 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// washSaleDays is how many days before or after a sale at a loss acquiring
+// more of the stock makes it a wash sale, disallowing the loss.
+const washSaleDays = 30
+
+// acquisition is shares of the stock acquired, or to be acquired, on a
+// date: a lot, or a vest still to come.
+type acquisition struct {
+	Date   time.Time
+	Shares float64
+	Lot    string
+	Grant  string
+}
+
+// washSale is a lot sold at a loss with shares acquired within
+// washSaleDays of the sale, which may disallow up to Disallowed of it.
+type washSale struct {
+	Lot          string
+	Loss         float64
+	Disallowed   float64
+	Acquisitions []acquisition
+}
+
+// acquisitions lists every lot acquired up to now, and every vest to come,
+// oldest first.
+func acquisitions(v valuation, now time.Time) ([]acquisition, error) {
+	var grants []grant
+	for _, g := range v.Grants {
+		grants = append(grants, g.grant)
+	}
+	exercises, err := loadExercises(grants)
+	if err != nil {
+		return nil, err
+	}
+	var acquired []acquisition
+	for _, l := range buildLots(grants, exercises, nil, now) {
+		acquired = append(acquired, acquisition{Date: l.Acquired, Shares: l.Shares, Lot: l.ID, Grant: l.Grant})
+	}
+	for _, g := range grants {
+		if g.isOption() || g.isESPP() {
+			continue
+		}
+		for _, e := range g.upcomingVests(now) {
+			acquired = append(acquired, acquisition{Date: e.Date, Shares: e.Shares, Grant: e.Grant})
+		}
+	}
+	sort.SliceStable(acquired, func(i, j int) bool { return acquired[i].Date.Before(acquired[j].Date) })
+	return acquired, nil
+}
+
+// findWashSales checks a sale on date at price of shares from sold for lots
+// sold at a loss with other shares acquired within washSaleDays either side.
+// As many of the loss's shares as were acquired again are disallowed.
+func findWashSales(sold []lot, price float64, date time.Time, acquired []acquisition) []washSale {
+	from, to := date.AddDate(0, 0, -washSaleDays), date.AddDate(0, 0, washSaleDays)
+	selling := map[string]bool{}
+	for _, l := range sold {
+		selling[l.ID] = true
+	}
+	var window []acquisition
+	replaced := 0.0
+	for _, a := range acquired {
+		if a.Date.Before(from) || a.Date.After(to) || (a.Lot != "" && selling[a.Lot]) {
+			continue
+		}
+		window = append(window, a)
+		replaced += a.Shares
+	}
+	if len(window) == 0 {
+		return nil
+	}
+
+	var washes []washSale
+	for _, l := range sold {
+		loss := -l.gain(price)
+		if loss <= 0.005 || replaced <= 0 {
+			continue
+		}
+		shares := math.Min(l.Shares, replaced)
+		replaced -= shares
+		washes = append(washes, washSale{Lot: l.ID, Loss: loss, Disallowed: loss * shares / l.Shares, Acquisitions: window})
+	}
+	return washes
+}
+
+// formatWashSales warns of each possible wash sale.
+func formatWashSales(w io.Writer, washes []washSale, ac moneyFormat) {
+	for _, ws := range washes {
+		var bought []string
+		for _, a := range ws.Acquisitions {
+			what := a.Lot
+			if what == "" {
+				what = "vest of " + a.Grant
+			}
+			bought = append(bought, fmt.Sprintf("%s shares on %s (%s)", formatShares(a.Shares), a.Date.Format("Jan 2, 2006"), what))
+		}
+		fmt.Fprintf(w, "Possible wash sale: lot %s is sold at a %s loss within %d days of acquiring %s; up to %s of the loss would be disallowed and added to the basis of those shares instead.\n",
+			ws.Lot, ac.FormatMoney(ws.Loss), washSaleDays, strings.Join(bought, ", "), ac.FormatMoney(ws.Disallowed))
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright © 2018 Ed Silva <ed@edlitmus.info>.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFindWashSales(t *testing.T) {
+	sold := mustDate("2026-06-01")
+	loss := lot{ID: "A", Acquired: mustDate("2025-01-15"), Shares: 100, Basis: 120}
+	gain := lot{ID: "B", Acquired: mustDate("2025-01-15"), Shares: 100, Basis: 80}
+
+	tests := []struct {
+		name     string
+		sold     []lot
+		acquired []acquisition
+		want     []washSale
+	}{
+		{"vest within the window", []lot{loss},
+			[]acquisition{{Date: mustDate("2026-06-15"), Shares: 100, Grant: "rsu"}},
+			[]washSale{{Lot: "A", Loss: 2000, Disallowed: 2000}}},
+		{"fewer shares bought than sold", []lot{loss},
+			[]acquisition{{Date: mustDate("2026-05-20"), Shares: 25, Grant: "rsu"}},
+			[]washSale{{Lot: "A", Loss: 2000, Disallowed: 500}}},
+		{"window ends 30 days out", []lot{loss},
+			[]acquisition{{Date: mustDate("2026-07-01"), Shares: 100, Grant: "rsu"}},
+			[]washSale{{Lot: "A", Loss: 2000, Disallowed: 2000}}},
+		{"after the window", []lot{loss},
+			[]acquisition{{Date: mustDate("2026-07-02"), Shares: 100, Grant: "rsu"}},
+			nil},
+		{"before the window", []lot{loss},
+			[]acquisition{{Date: mustDate("2026-05-01"), Shares: 100, Grant: "rsu"}},
+			nil},
+		{"sold at a gain", []lot{gain},
+			[]acquisition{{Date: mustDate("2026-06-15"), Shares: 100, Grant: "rsu"}},
+			nil},
+		{"the lot being sold", []lot{loss},
+			[]acquisition{{Date: mustDate("2026-05-20"), Shares: 100, Lot: "A"}},
+			nil},
+		{"shares bought cover the first loss only", []lot{loss, loss},
+			[]acquisition{{Date: mustDate("2026-06-15"), Shares: 100, Grant: "rsu"}},
+			[]washSale{{Lot: "A", Loss: 2000, Disallowed: 2000}}},
+	}
+	for _, tt := range tests {
+		got := findWashSales(tt.sold, 100, sold, tt.acquired)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: findWashSales found %d wash sales, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			if got[i].Lot != w.Lot || math.Abs(got[i].Loss-w.Loss) > 1e-9 || math.Abs(got[i].Disallowed-w.Disallowed) > 1e-9 {
+				t.Errorf("%s: wash sale %d = %s loss %v disallowed %v, want %s loss %v disallowed %v",
+					tt.name, i, got[i].Lot, got[i].Loss, got[i].Disallowed, w.Lot, w.Loss, w.Disallowed)
+			}
+		}
+	}
+}